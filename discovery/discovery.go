@@ -0,0 +1,338 @@
+package discovery
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//   DNS-Based Service Discovery (RFC 6763) of IPP printers over Multicast DNS (RFC 6762)
+//
+//   A printer advertises a service instance, e.g. "Office Printer._ipp._tcp.local.", with:
+//
+//      PTR  _ipp._tcp.local.                    -> Office Printer._ipp._tcp.local.
+//      PTR  _print._sub._ipp._tcp.local.        -> Office Printer._ipp._tcp.local.   (IPP Everywhere)
+//      SRV  Office Printer._ipp._tcp.local.     -> priority, weight, port 631, host office.local.
+//      TXT  Office Printer._ipp._tcp.local.     -> "rp=ipp/print" "ty=..." "pdl=..." ...
+//      A    office.local.                       -> 192.168.1.8
+//
+//   _ipps._tcp is the same service over TLS. Browsing queries the PTR records of the service
+//   types, then the SRV, TXT and address records the responders did not add to their answers.
+//
+//   TXT keys (Bonjour Printing 1.2, IPP Everywhere section 4.2.2), keys are case-insensitive:
+//
+//      rp          resource path of the printer-uri, e.g. "ipp/print"
+//      ty          printer-make-and-model
+//      pdl         document-format-supported, comma separated
+//      Color       'T' if the printer prints in color
+//      Duplex      'T' if the printer prints on both sides
+//      UUID        printer-uuid without the "urn:uuid:" prefix
+//      adminurl    printer-more-info, the web page of the printer
+//      note        printer-location
+//      kind        printer-kind, comma separated, e.g. "document,envelope,photo"
+//      PaperMax    the largest media, "<legal-A4", "legal-A4", "tabloid-A3", "isoC-A2" or ">isoC-A2"
+//      URF         the Apple Raster capabilities, comma separated, e.g. "CP1,IS1-5,MT1-2,RS300"
+
+//	Service types of IPP printers, without the ".local." domain
+const (
+	IPP           = "_ipp._tcp"
+	IPPS          = "_ipps._tcp"
+	IPPPrint      = "_print._sub._ipp._tcp"  // IPP Everywhere printers
+	IPPSPrint     = "_print._sub._ipps._tcp" // IPP Everywhere printers over TLS
+	defaultDomain = "local."
+)
+
+//	Printer is a printer service instance found by Browse
+type Printer struct {
+	Instance string            // the instance name, e.g. "Office Printer"
+	Service  string            // the service type, IPP or IPPS
+	Host     string            // the target host of the SRV record, e.g. "office.local."
+	Port     int               // the port of the SRV record
+	Addrs    []net.IP          // the addresses of Host, IPv4 first
+	Txt      map[string]string // every TXT key, in lower case, and its value
+
+	ResourcePath string   // rp
+	MakeAndModel string   // ty
+	Formats      []string // pdl
+	Color        bool     // Color
+	Duplex       bool     // Duplex
+	UUID         string   // UUID
+	AdminURL     string   // adminurl
+	Note         string   // note
+	Kinds        []string // kind
+	PaperMax     string   // PaperMax
+	URF          []string // URF
+}
+
+//	Returns the printer-uri of the printer, e.g. "ipp://192.168.1.8:631/ipp/print" or
+//	"ipps://[2001:db8::8]:631/ipp/print". The first address is used if there is one, otherwise
+//	the host name. Link-local IPv6 addresses are skipped, they need the zone of the interface
+//	they were found on, which Addrs does not carry.
+func (p Printer) PrinterUri() string {
+	scheme := "ipp"
+	if strings.HasSuffix(p.Service, IPPS) {
+		scheme = "ipps"
+	}
+	host := strings.TrimSuffix(p.Host, ".")
+	for _, ip := range p.Addrs {
+		if ip.IsLinkLocalUnicast() && ip.To4() == nil {
+			continue
+		}
+		host = ip.String()
+		if ip.To4() == nil {
+			host = "[" + host + "]"
+		}
+		break
+	}
+	port := p.Port
+	if port == 0 {
+		port = 631
+	}
+	return scheme + "://" + host + ":" + strconv.Itoa(port) + "/" + strings.TrimPrefix(p.ResourcePath, "/")
+}
+
+//	Decodes the TXT strings of a printer, "key=value" or just "key" for an attribute without value
+func decodeTxt(p *Printer, txt []string) {
+	p.Txt = make(map[string]string)
+	for _, s := range txt {
+		key, value := s, ""
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			key, value = s[:i], s[i+1:]
+		}
+		key = strings.ToLower(key)
+		if _, ok := p.Txt[key]; ok || key == "" {
+			// only the first occurrence of a key counts (RFC 6763 section 6.4)
+			continue
+		}
+		p.Txt[key] = value
+	}
+	p.ResourcePath = p.Txt["rp"]
+	p.MakeAndModel = p.Txt["ty"]
+	p.Formats = list(p.Txt["pdl"])
+	p.Color = p.Txt["color"] == "T" || p.Txt["color"] == "t"
+	p.Duplex = p.Txt["duplex"] == "T" || p.Txt["duplex"] == "t"
+	p.UUID = p.Txt["uuid"]
+	p.AdminURL = p.Txt["adminurl"]
+	p.Note = p.Txt["note"]
+	p.Kinds = list(p.Txt["kind"])
+	p.PaperMax = p.Txt["papermax"]
+	p.URF = list(p.Txt["urf"])
+}
+
+//	Splits a comma separated TXT value, "" and "none" are empty
+func list(value string) []string {
+	if value == "" || value == "none" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+//	Browser finds printers with mDNS queries
+type Browser struct {
+	Transport Transport     // nil opens a MulticastTransport for every Browse
+	Timeout   time.Duration // how long to wait for responses to each round of queries, 2s if 0
+}
+
+//	Browses IPP and IPPS printers on the local network with the default Browser
+func Browse(services ...string) ([]Printer, error) {
+	var b Browser
+	return b.Browse(services...)
+}
+
+//	Cached records of one Browse
+type browse struct {
+	instances map[string]string   // instance name -> service type
+	srv       map[string]record   // instance name -> SRV
+	txt       map[string][]string // instance name -> TXT strings
+	addrs     map[string][]net.IP // host name -> addresses
+}
+
+//	Returns the printers that answer for the service types, IPP and IPPS when none is given.
+//	A printer advertised with several service types is returned once for each of them.
+func (b *Browser) Browse(services ...string) ([]Printer, error) {
+	if len(services) == 0 {
+		services = []string{IPP, IPPS}
+	}
+	t := b.Transport
+	if t == nil {
+		mt, err := NewMulticastTransport()
+		if err != nil {
+			return nil, err
+		}
+		defer mt.Close()
+		t = mt
+	}
+	timeout := b.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	c := &browse{
+		instances: make(map[string]string),
+		srv:       make(map[string]record),
+		txt:       make(map[string][]string),
+		addrs:     make(map[string][]net.IP),
+	}
+	var questions []question
+	for _, s := range services {
+		questions = append(questions, question{name: fqdn(s), qtype: typePTR})
+	}
+	// the first round waits the whole timeout for every printer to answer, the following
+	// ones ask for the records that are still missing and end as soon as they are complete
+	for round := 0; round < 3 && len(questions) > 0; round++ {
+		if err := query(t, questions); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		for {
+			msg, err := t.Receive(deadline)
+			if err == ErrTimeout {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			m, err := unpack(msg)
+			if err != nil || m.flags&flagResp == 0 {
+				// not a response, or not a DNS message at all
+				continue
+			}
+			c.add(m.records(), services)
+			if round > 0 && len(c.missing()) == 0 {
+				break
+			}
+		}
+		questions = c.missing()
+	}
+	return c.printers(), nil
+}
+
+//	Sends the questions in one query
+func query(t Transport, questions []question) error {
+	m := message{id: uint16(rand.Intn(1 << 16)), questions: questions}
+	b, err := m.pack()
+	if err != nil {
+		return err
+	}
+	return t.Send(b)
+}
+
+//	Adds the records of a response, PTR records of other service types are ignored
+func (c *browse) add(rrs []record, services []string) {
+	for _, rr := range rrs {
+		switch rr.rtype {
+		case typePTR:
+			for _, s := range services {
+				if sameName(rr.name, fqdn(s)) {
+					c.instances[rr.target] = serviceOf(rr.target)
+				}
+			}
+		case typeSRV:
+			c.srv[strings.ToLower(rr.name)] = rr
+		case typeTXT:
+			c.txt[strings.ToLower(rr.name)] = rr.txt
+		case typeA, typeAAAA:
+			host := strings.ToLower(rr.name)
+			for _, ip := range c.addrs[host] {
+				if ip.Equal(rr.ip) {
+					rr.ip = nil
+				}
+			}
+			if rr.ip != nil {
+				c.addrs[host] = append(c.addrs[host], rr.ip)
+			}
+		}
+	}
+}
+
+//	Returns the questions for the SRV, TXT and address records that are still missing
+func (c *browse) missing() []question {
+	var questions []question
+	for name := range c.instances {
+		key := strings.ToLower(name)
+		if _, ok := c.srv[key]; !ok {
+			questions = append(questions, question{name: name, qtype: typeSRV})
+		} else if host := c.srv[key].target; len(c.addrs[strings.ToLower(host)]) == 0 {
+			questions = append(questions, question{name: host, qtype: typeA}, question{name: host, qtype: typeAAAA})
+		}
+		if _, ok := c.txt[key]; !ok {
+			questions = append(questions, question{name: name, qtype: typeTXT})
+		}
+	}
+	return questions
+}
+
+//	Returns the printers whose SRV record is known, ordered by instance name and service type
+func (c *browse) printers() []Printer {
+	var printers []Printer
+	for name, service := range c.instances {
+		srv, ok := c.srv[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		p := Printer{
+			Instance: instanceOf(name),
+			Service:  service,
+			Host:     srv.target,
+			Port:     int(srv.port),
+		}
+		decodeTxt(&p, c.txt[strings.ToLower(name)])
+		addrs := append([]net.IP(nil), c.addrs[strings.ToLower(srv.target)]...)
+		sort.SliceStable(addrs, func(i, j int) bool { return addrs[i].To4() != nil && addrs[j].To4() == nil })
+		p.Addrs = addrs
+		printers = append(printers, p)
+	}
+	sort.Slice(printers, func(i, j int) bool {
+		if printers[i].Instance != printers[j].Instance {
+			return printers[i].Instance < printers[j].Instance
+		}
+		return printers[i].Service < printers[j].Service
+	})
+	return printers
+}
+
+//	Returns the fully qualified name of a service type, e.g. "_ipp._tcp.local."
+func fqdn(service string) string {
+	if strings.HasSuffix(service, ".") {
+		return service
+	}
+	if strings.HasSuffix(service, ".local") {
+		return service + "."
+	}
+	return service + "." + defaultDomain
+}
+
+//	Returns the instance name of a service instance, its unescaped first label
+func instanceOf(name string) string {
+	labels := splitName(name)
+	if len(labels) == 0 {
+		return ""
+	}
+	return labels[0]
+}
+
+//	Returns the service type of a service instance without the domain, e.g. "_ipps._tcp"
+func serviceOf(name string) string {
+	labels := splitName(name)
+	if len(labels) < 3 {
+		return ""
+	}
+	return labels[1] + "." + labels[2]
+}
+
+//	Resolves the printer-uri of the instance (e.g. "Office Printer") of a service type
+func Resolve(instance, service string) (string, error) {
+	printers, err := Browse(service)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range printers {
+		if p.Instance == instance {
+			return p.PrinterUri(), nil
+		}
+	}
+	return "", errors.New("discovery: " + instance + " not found")
+}
@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBrowseResponder(t *testing.T) {
+	transport, conn := Pipe()
+	defer transport.Close()
+	r, err := NewResponder(conn, Service{
+		Instance: "Office Printer",
+		Type:     IPP,
+		Subtypes: []string{"_print"},
+		Host:     "office.local.",
+		Port:     8631,
+		Addrs:    []net.IP{net.ParseIP("fe80::1"), net.ParseIP("2001:db8::8")},
+		Txt: []string{
+			"rp=ipp/print",
+			"ty=Example Laser 1000",
+			"pdl=application/pdf,image/urf",
+			"Color=T",
+			"Duplex=F",
+			"UUID=4e8a3f4c-2b1d-4f0e-9c5a-6d7e8f901234",
+			"URF=W8,SRGB24,CP1,RS300-600,DM1",
+			"rp=ignored",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	b := Browser{Transport: transport, Timeout: 200 * time.Millisecond}
+	for _, service := range []string{IPPPrint, IPP} {
+		printers, err := b.Browse(service)
+		if err != nil {
+			t.Fatal(service, err)
+		}
+		if len(printers) != 1 {
+			t.Fatalf("%s: %d printers, want 1: %+v", service, len(printers), printers)
+		}
+		p := printers[0]
+		if p.Instance != "Office Printer" || p.Service != IPP || p.Host != "office.local." || p.Port != 8631 {
+			t.Errorf("%s: instance %q service %q host %q port %d", service, p.Instance, p.Service, p.Host, p.Port)
+		}
+		if p.ResourcePath != "ipp/print" || p.MakeAndModel != "Example Laser 1000" ||
+			!reflect.DeepEqual(p.Formats, []string{"application/pdf", "image/urf"}) {
+			t.Errorf("%s: rp %q ty %q pdl %q", service, p.ResourcePath, p.MakeAndModel, p.Formats)
+		}
+		if !p.Color || p.Duplex || p.UUID != "4e8a3f4c-2b1d-4f0e-9c5a-6d7e8f901234" {
+			t.Errorf("%s: Color %v Duplex %v UUID %q", service, p.Color, p.Duplex, p.UUID)
+		}
+		if !reflect.DeepEqual(p.URF, []string{"W8", "SRGB24", "CP1", "RS300-600", "DM1"}) {
+			t.Errorf("%s: URF %q", service, p.URF)
+		}
+		if uri := p.PrinterUri(); uri != "ipp://[2001:db8::8]:8631/ipp/print" {
+			t.Errorf("%s: printer-uri %s", service, uri)
+		}
+	}
+
+	if printers, err := b.Browse(IPPS); err != nil || len(printers) != 0 {
+		t.Errorf("IPPS: %+v %v, want none", printers, err)
+	}
+}
+
+func TestPrinterUri(t *testing.T) {
+	tests := []struct {
+		p    Printer
+		want string
+	}{
+		{Printer{Service: IPP, Host: "office.local.", Addrs: []net.IP{net.ParseIP("192.168.1.8")}, ResourcePath: "ipp/print"},
+			"ipp://192.168.1.8:631/ipp/print"},
+		{Printer{Service: IPPS, Host: "office.local.", Port: 443, Addrs: []net.IP{net.ParseIP("2001:db8::8")}, ResourcePath: "/ipp/print"},
+			"ipps://[2001:db8::8]:443/ipp/print"},
+		// a link-local address without its zone is useless, the host name is used
+		{Printer{Service: IPPS, Host: "office.local.", Addrs: []net.IP{net.ParseIP("fe80::1")}, ResourcePath: "ipp/print"},
+			"ipps://office.local:631/ipp/print"},
+		{Printer{Service: IPP, Host: "office.local."}, "ipp://office.local:631/"},
+	}
+	for _, test := range tests {
+		if uri := test.p.PrinterUri(); uri != test.want {
+			t.Errorf("%+v: %s, want %s", test.p, uri, test.want)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package discovery
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+//   DNS message format (RFC 1035 section 4.1), as used by Multicast DNS (RFC 6762)
+//
+//   -----------------------------------------------
+//   |            header (12 bytes)                |  id, flags, qdcount, ancount, nscount, arcount
+//   -----------------------------------------------
+//   |            question                         |  qdcount times: name, type, class
+//   -----------------------------------------------
+//   |            answer                           |  ancount resource records
+//   -----------------------------------------------
+//   |            authority                        |  nscount resource records
+//   -----------------------------------------------
+//   |            additional                       |  arcount resource records
+//   -----------------------------------------------
+//
+//   Each resource record is: name, type (2), class (2), ttl (4), rdlength (2), rdata.
+//   mDNS uses the top bit of the question class for "unicast response requested" (QU) and the
+//   top bit of the record class for "cache-flush". A name is a sequence of labels, each one
+//   prefixed by its length, ending with the root label 0; a length with the two top bits set is
+//   a pointer (compression) to a name elsewhere in the message.
+
+//	Resource record types
+const (
+	typeA    = 1
+	typePTR  = 12
+	typeTXT  = 16
+	typeAAAA = 28
+	typeSRV  = 33
+	typeANY  = 255
+
+	classIN     = 1
+	classTop    = 0x8000 // QU in a question, cache-flush in a record
+	flagResp    = 0x8000 // QR: the message is a response
+	flagAuth    = 0x0400 // AA: authoritative answer
+	maxPointers = 16     // compression pointers followed for one name
+)
+
+var errMalformed = errors.New("discovery: malformed DNS message")
+
+type message struct {
+	id          uint16
+	flags       uint16
+	questions   []question
+	answers     []record
+	authorities []record
+	additionals []record
+}
+
+type question struct {
+	name    string
+	qtype   uint16
+	unicast bool // QU, the response is sent to the querier instead of the multicast group
+}
+
+//	A resource record, only the rdata fields of its type are used
+type record struct {
+	name  string
+	rtype uint16
+	flush bool // cache-flush, the record replaces the cached records of the same name and type
+	ttl   uint32
+
+	target   string   // PTR, SRV
+	priority uint16   // SRV
+	weight   uint16   // SRV
+	port     uint16   // SRV
+	txt      []string // TXT
+	ip       net.IP   // A, AAAA
+	data     []byte   // any other type
+}
+
+//	Returns every record of the answer, authority and additional sections
+func (m *message) records() []record {
+	var rrs []record
+	rrs = append(rrs, m.answers...)
+	rrs = append(rrs, m.authorities...)
+	return append(rrs, m.additionals...)
+}
+
+//	Encodes the message, names are not compressed
+func (m *message) pack() ([]byte, error) {
+	b := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(b[0:], m.id)
+	binary.BigEndian.PutUint16(b[2:], m.flags)
+	binary.BigEndian.PutUint16(b[4:], uint16(len(m.questions)))
+	binary.BigEndian.PutUint16(b[6:], uint16(len(m.answers)))
+	binary.BigEndian.PutUint16(b[8:], uint16(len(m.authorities)))
+	binary.BigEndian.PutUint16(b[10:], uint16(len(m.additionals)))
+	var err error
+	for _, q := range m.questions {
+		if b, err = packName(b, q.name); err != nil {
+			return nil, err
+		}
+		class := uint16(classIN)
+		if q.unicast {
+			class |= classTop
+		}
+		b = appendUint16(b, q.qtype)
+		b = appendUint16(b, class)
+	}
+	for _, rrs := range [][]record{m.answers, m.authorities, m.additionals} {
+		for _, rr := range rrs {
+			if b, err = rr.pack(b); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func (rr *record) pack(b []byte) ([]byte, error) {
+	b, err := packName(b, rr.name)
+	if err != nil {
+		return nil, err
+	}
+	class := uint16(classIN)
+	if rr.flush {
+		class |= classTop
+	}
+	b = appendUint16(b, rr.rtype)
+	b = appendUint16(b, class)
+	b = append(b, byte(rr.ttl>>24), byte(rr.ttl>>16), byte(rr.ttl>>8), byte(rr.ttl))
+	lenAt := len(b)
+	b = appendUint16(b, 0)
+	switch rr.rtype {
+	case typePTR:
+		b, err = packName(b, rr.target)
+	case typeSRV:
+		b = appendUint16(b, rr.priority)
+		b = appendUint16(b, rr.weight)
+		b = appendUint16(b, rr.port)
+		b, err = packName(b, rr.target)
+	case typeTXT:
+		if len(rr.txt) == 0 {
+			// an empty TXT record holds a single empty string (RFC 6763 section 6.1)
+			b = append(b, 0)
+		}
+		for _, s := range rr.txt {
+			if len(s) > 255 {
+				return nil, errors.New("discovery: TXT string longer than 255 bytes")
+			}
+			b = append(b, byte(len(s)))
+			b = append(b, s...)
+		}
+	case typeA:
+		b = append(b, rr.ip.To4()...)
+	case typeAAAA:
+		b = append(b, rr.ip.To16()...)
+	default:
+		b = append(b, rr.data...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	binary.BigEndian.PutUint16(b[lenAt:], uint16(len(b)-lenAt-2))
+	return b, nil
+}
+
+//	Decodes a DNS message
+func unpack(b []byte) (*message, error) {
+	if len(b) < 12 {
+		return nil, errMalformed
+	}
+	m := &message{
+		id:    binary.BigEndian.Uint16(b[0:]),
+		flags: binary.BigEndian.Uint16(b[2:]),
+	}
+	qd := int(binary.BigEndian.Uint16(b[4:]))
+	counts := []int{
+		int(binary.BigEndian.Uint16(b[6:])),
+		int(binary.BigEndian.Uint16(b[8:])),
+		int(binary.BigEndian.Uint16(b[10:])),
+	}
+	off := 12
+	for i := 0; i < qd; i++ {
+		name, n, err := readName(b, off)
+		if err != nil {
+			return nil, err
+		}
+		off = n
+		if off+4 > len(b) {
+			return nil, errMalformed
+		}
+		class := binary.BigEndian.Uint16(b[off+2:])
+		m.questions = append(m.questions, question{
+			name:    name,
+			qtype:   binary.BigEndian.Uint16(b[off:]),
+			unicast: class&classTop != 0,
+		})
+		off += 4
+	}
+	sections := []*[]record{&m.answers, &m.authorities, &m.additionals}
+	for s, count := range counts {
+		for i := 0; i < count; i++ {
+			rr, n, err := readRecord(b, off)
+			if err != nil {
+				return nil, err
+			}
+			off = n
+			*sections[s] = append(*sections[s], rr)
+		}
+	}
+	return m, nil
+}
+
+func readRecord(b []byte, off int) (record, int, error) {
+	var rr record
+	name, off, err := readName(b, off)
+	if err != nil {
+		return rr, 0, err
+	}
+	if off+10 > len(b) {
+		return rr, 0, errMalformed
+	}
+	rr.name = name
+	rr.rtype = binary.BigEndian.Uint16(b[off:])
+	rr.flush = binary.BigEndian.Uint16(b[off+2:])&classTop != 0
+	rr.ttl = binary.BigEndian.Uint32(b[off+4:])
+	length := int(binary.BigEndian.Uint16(b[off+8:]))
+	off += 10
+	end := off + length
+	if end > len(b) {
+		return rr, 0, errMalformed
+	}
+	rdata := b[off:end]
+	switch rr.rtype {
+	case typePTR:
+		rr.target, _, err = readName(b, off)
+	case typeSRV:
+		if length < 7 {
+			return rr, 0, errMalformed
+		}
+		rr.priority = binary.BigEndian.Uint16(rdata[0:])
+		rr.weight = binary.BigEndian.Uint16(rdata[2:])
+		rr.port = binary.BigEndian.Uint16(rdata[4:])
+		rr.target, _, err = readName(b, off+6)
+	case typeTXT:
+		for i := 0; i < len(rdata); {
+			l := int(rdata[i])
+			if i+1+l > len(rdata) {
+				return rr, 0, errMalformed
+			}
+			if l > 0 {
+				rr.txt = append(rr.txt, string(rdata[i+1:i+1+l]))
+			}
+			i += 1 + l
+		}
+	case typeA, typeAAAA:
+		if length != net.IPv4len && length != net.IPv6len {
+			return rr, 0, errMalformed
+		}
+		rr.ip = append(net.IP(nil), rdata...)
+	default:
+		rr.data = append([]byte(nil), rdata...)
+	}
+	if err != nil {
+		return rr, 0, err
+	}
+	return rr, end, nil
+}
+
+//	Reads the name at off and returns it in its dotted form, e.g. "HP\.1._ipp._tcp.local.", with
+//	the offset following it. Dots and backslashes inside a label are escaped with a backslash.
+func readName(b []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for jumps := 0; ; {
+		if off >= len(b) {
+			return "", 0, errMalformed
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, ".") + ".", next, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) || jumps >= maxPointers {
+				return "", 0, errMalformed
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(b[off:]) & 0x3fff)
+			jumps++
+		case l&0xc0 != 0:
+			return "", 0, errMalformed
+		default:
+			if off+1+l > len(b) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, escapeLabel(string(b[off+1:off+1+l])))
+			off += 1 + l
+		}
+	}
+}
+
+//	Appends the name in its wire format, the inverse of readName
+func packName(b []byte, name string) ([]byte, error) {
+	for _, label := range splitName(name) {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, errors.New("discovery: bad label in " + name)
+		}
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0), nil
+}
+
+//	Splits a dotted name into its unescaped labels
+func splitName(name string) []string {
+	var labels []string
+	var label []byte
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; {
+		case c == '\\' && i+1 < len(name):
+			i++
+			label = append(label, name[i])
+		case c == '.':
+			labels = append(labels, string(label))
+			label = label[:0]
+		default:
+			label = append(label, c)
+		}
+	}
+	if len(label) > 0 {
+		labels = append(labels, string(label))
+	}
+	return labels
+}
+
+func escapeLabel(label string) string {
+	if !strings.ContainsAny(label, `.\`) {
+		return label
+	}
+	return strings.NewReplacer(`\`, `\\`, `.`, `\.`).Replace(label)
+}
+
+//	Compares two names, DNS names are case-insensitive
+func sameName(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
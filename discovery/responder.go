@@ -0,0 +1,253 @@
+package discovery
+
+import (
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+//   Advertising a service instance (RFC 6762 section 8.3, RFC 6763 section 12)
+//
+//   The Responder announces the records of its service when it starts, again one second later
+//   and whenever the TXT record changes. It answers queries for the service type, its subtypes,
+//   the instance and the host name, and the service type enumeration
+//   "_services._dns-sd._udp.local.". PTR answers carry the SRV, TXT and address records as
+//   additional records so browsers need no further queries. Queries from a port other than
+//   5353 (one-shot queries) are answered by unicast to the querier, others to the multicast
+//   group. Close sends the records again with a TTL of 0 ("goodbye"). Probing and conflict
+//   resolution are left out: the instance name must be unique on the link.
+
+//	Service is a service instance advertised by a Responder
+type Service struct {
+	Instance string   // the instance name, e.g. "Office Printer"
+	Type     string   // the service type, e.g. IPP or IPPS
+	Subtypes []string // e.g. "_print" for IPP Everywhere printers
+	Host     string   // the host name, e.g. "office.local.", the local host name if empty
+	Port     int
+	Addrs    []net.IP // the addresses of Host, those of the network interfaces if empty
+	Txt      []string // "key=value" strings
+}
+
+//	Responder answers mDNS queries for a Service
+type Responder struct {
+	mu     sync.Mutex
+	conn   net.PacketConn
+	svc    Service
+	closed bool
+}
+
+const (
+	hostTTL  = 120  // A, AAAA and SRV records (RFC 6762 section 10)
+	otherTTL = 4500 // PTR and TXT records
+)
+
+//	Joins the mDNS group 224.0.0.251:5353 on the interface the system uses for multicast
+func ListenMulticast() (net.PacketConn, error) {
+	return net.ListenMulticastUDP("udp4", nil, mdnsGroup)
+}
+
+//	Starts advertising svc on conn, a nil conn joins the mDNS group (see ListenMulticast).
+//	The Responder owns conn and closes it on Close.
+func NewResponder(conn net.PacketConn, svc Service) (*Responder, error) {
+	if conn == nil {
+		c, err := ListenMulticast()
+		if err != nil {
+			return nil, err
+		}
+		conn = c
+	}
+	if svc.Host == "" {
+		name, err := os.Hostname()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		svc.Host = strings.SplitN(name, ".", 2)[0] + "." + defaultDomain
+	}
+	svc.Host = fqdn(svc.Host)
+	if len(svc.Addrs) == 0 {
+		svc.Addrs = interfaceAddrs()
+	}
+	r := &Responder{conn: conn, svc: svc}
+	if err := r.announce(otherTTL); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go r.serve()
+	go func() {
+		time.Sleep(time.Second)
+		r.announce(otherTTL)
+	}()
+	return r, nil
+}
+
+//	Replaces the TXT record and announces it, e.g. after the printer attributes changed
+func (r *Responder) SetTxt(txt []string) error {
+	r.mu.Lock()
+	r.svc.Txt = append([]string(nil), txt...)
+	r.mu.Unlock()
+	return r.announce(otherTTL)
+}
+
+//	Returns the advertised Service
+func (r *Responder) Service() Service {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.svc
+}
+
+//	Sends the goodbye announcement and stops answering queries
+func (r *Responder) Close() error {
+	r.announce(0)
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	return r.conn.Close()
+}
+
+//	Sends every record of the service to the multicast group, a ttl of 0 withdraws them
+func (r *Responder) announce(ttl uint32) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	rrs := r.records()
+	r.mu.Unlock()
+	m := message{flags: flagResp | flagAuth}
+	for _, rr := range rrs {
+		if ttl == 0 {
+			rr.ttl = 0
+		}
+		m.answers = append(m.answers, rr)
+	}
+	b, err := m.pack()
+	if err != nil {
+		return err
+	}
+	_, err = r.conn.WriteTo(b, mdnsGroup)
+	return err
+}
+
+func (r *Responder) serve() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			r.mu.Lock()
+			closed := r.closed
+			r.mu.Unlock()
+			if closed {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		m, err := unpack(buf[:n])
+		if err != nil || m.flags&flagResp != 0 || len(m.questions) == 0 {
+			continue
+		}
+		r.respond(m, from)
+	}
+}
+
+//	Answers the questions of a query that are about the service
+func (r *Responder) respond(q *message, from net.Addr) {
+	r.mu.Lock()
+	rrs := r.records()
+	r.mu.Unlock()
+	var resp message
+	resp.flags = flagResp | flagAuth
+	answered := make(map[int]bool)
+	unicast := true
+	for _, question := range q.questions {
+		unicast = unicast && question.unicast
+		for i, rr := range rrs {
+			if !answered[i] && sameName(rr.name, question.name) && (question.qtype == typeANY || question.qtype == rr.rtype) {
+				answered[i] = true
+				resp.answers = append(resp.answers, rr)
+			}
+		}
+	}
+	if len(resp.answers) == 0 {
+		return
+	}
+	// a PTR answer brings the records needed to use the instance along
+	for _, a := range resp.answers {
+		if a.rtype != typePTR || !sameName(a.target, r.instanceName()) {
+			continue
+		}
+		for i, rr := range rrs {
+			if !answered[i] && rr.rtype != typePTR {
+				answered[i] = true
+				resp.additionals = append(resp.additionals, rr)
+			}
+		}
+	}
+	to := net.Addr(mdnsGroup)
+	if u, ok := from.(*net.UDPAddr); ok && u.Port != mdnsGroup.Port {
+		// a one-shot query, answered like a unicast DNS query (RFC 6762 section 6.7)
+		resp.id = q.id
+		resp.questions = q.questions
+		to = from
+	} else if unicast {
+		to = from
+	}
+	b, err := resp.pack()
+	if err != nil {
+		return
+	}
+	r.conn.WriteTo(b, to)
+}
+
+//	Returns the fully qualified name of the instance, e.g. "Office Printer._ipp._tcp.local."
+func (r *Responder) instanceName() string {
+	return escapeLabel(r.svc.Instance) + "." + fqdn(r.svc.Type)
+}
+
+//	Returns the records of the service, the caller holds r.mu
+func (r *Responder) records() []record {
+	svc := r.svc
+	instance := r.instanceName()
+	rrs := []record{
+		{name: "_services._dns-sd._udp." + defaultDomain, rtype: typePTR, ttl: otherTTL, target: fqdn(svc.Type)},
+		{name: fqdn(svc.Type), rtype: typePTR, ttl: otherTTL, target: instance},
+	}
+	for _, sub := range svc.Subtypes {
+		rrs = append(rrs, record{name: sub + "._sub." + fqdn(svc.Type), rtype: typePTR, ttl: otherTTL, target: instance})
+	}
+	rrs = append(rrs,
+		record{name: instance, rtype: typeSRV, flush: true, ttl: hostTTL, port: uint16(svc.Port), target: svc.Host},
+		record{name: instance, rtype: typeTXT, flush: true, ttl: otherTTL, txt: svc.Txt},
+	)
+	for _, ip := range svc.Addrs {
+		rtype := uint16(typeAAAA)
+		if ip.To4() != nil {
+			rtype = typeA
+		}
+		rrs = append(rrs, record{name: svc.Host, rtype: rtype, flush: true, ttl: hostTTL, ip: ip})
+	}
+	return rrs
+}
+
+//	Returns the addresses of the network interfaces that are up, loopback addresses left out
+func interfaceAddrs() []net.IP {
+	var ips []net.IP
+	ifaces, _ := net.Interfaces()
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok {
+				ips = append(ips, n.IP)
+			}
+		}
+	}
+	return ips
+}
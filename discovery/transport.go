@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+//	Transport carries the mDNS messages of a Browser. The default MulticastTransport sends
+//	one-shot queries (RFC 6762 section 5.1) to 224.0.0.251:5353; tests can browse an in-process
+//	Responder over a Pipe instead.
+type Transport interface {
+	Send(msg []byte) error                      // sends a DNS message to the multicast group
+	Receive(deadline time.Time) ([]byte, error) // returns the next DNS message, ErrTimeout once deadline has passed
+	Close() error
+}
+
+//	Returned by Transport.Receive when no message arrived before the deadline
+var ErrTimeout = errors.New("discovery: timeout")
+
+//	The mDNS IPv4 multicast group and port
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+//	MulticastTransport sends queries from an ephemeral UDP port, responders answer one-shot
+//	queries with unicast responses to that port
+type MulticastTransport struct {
+	conn *net.UDPConn
+	buf  []byte
+}
+
+//	Opens a MulticastTransport on one ephemeral IPv4 port, the queries are sent out of the
+//	interface the system routes 224.0.0.251 through. Printers on other interfaces are not found.
+func NewMulticastTransport() (*MulticastTransport, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	return &MulticastTransport{conn: conn, buf: make([]byte, 9000)}, nil
+}
+
+func (t *MulticastTransport) Send(msg []byte) error {
+	_, err := t.conn.WriteToUDP(msg, mdnsGroup)
+	return err
+}
+
+func (t *MulticastTransport) Receive(deadline time.Time) ([]byte, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+	n, _, err := t.conn.ReadFromUDP(t.buf)
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return nil, ErrTimeout
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), t.buf[:n]...), nil
+}
+
+func (t *MulticastTransport) Close() error {
+	return t.conn.Close()
+}
+
+// ========== in-memory link ==========
+
+//	Returns the two ends of an in-memory mDNS link: the queries sent on the Transport are read
+//	from the PacketConn and everything written to the PacketConn is received on the Transport,
+//	whatever its address. Pass the PacketConn to NewResponder and the Transport to a Browser
+//	to browse the Responder without a network. The queries come from a port other than 5353,
+//	the Responder answers them like one-shot queries. Messages nobody reads are dropped once
+//	64 are queued. Closing either end closes both.
+func Pipe() (Transport, net.PacketConn) {
+	p := &pipe{
+		queries:   make(chan []byte, 64),
+		responses: make(chan []byte, 64),
+		done:      make(chan struct{}),
+	}
+	return &pipeTransport{p}, &pipeConn{p}
+}
+
+//	The querier's address on a Pipe
+var pipeAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 49152}
+
+type pipe struct {
+	queries   chan []byte // Transport to PacketConn
+	responses chan []byte // PacketConn to Transport
+	done      chan struct{}
+	once      sync.Once
+}
+
+func (p *pipe) close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+//	Queues a message unless the pipe is closed, a full queue drops it like a busy network would
+func (p *pipe) send(c chan []byte, msg []byte) error {
+	select {
+	case <-p.done:
+		return net.ErrClosed
+	default:
+	}
+	select {
+	case c <- append([]byte(nil), msg...):
+	default:
+	}
+	return nil
+}
+
+type pipeTransport struct{ p *pipe }
+
+func (t *pipeTransport) Send(msg []byte) error {
+	return t.p.send(t.p.queries, msg)
+}
+
+func (t *pipeTransport) Receive(deadline time.Time) ([]byte, error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case msg := <-t.p.responses:
+		return msg, nil
+	case <-timer.C:
+		return nil, ErrTimeout
+	case <-t.p.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (t *pipeTransport) Close() error {
+	return t.p.close()
+}
+
+type pipeConn struct{ p *pipe }
+
+func (c *pipeConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case msg := <-c.p.queries:
+		return copy(b, msg), pipeAddr, nil
+	case <-c.p.done:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (c *pipeConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if err := c.p.send(c.p.responses, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *pipeConn) Close() error                       { return c.p.close() }
+func (c *pipeConn) LocalAddr() net.Addr                { return mdnsGroup }
+func (c *pipeConn) SetDeadline(t time.Time) error      { return nil }
+func (c *pipeConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *pipeConn) SetWriteDeadline(t time.Time) error { return nil }
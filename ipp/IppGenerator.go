@@ -3,6 +3,8 @@ package ipp
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"math"
 	// "utils"
 	// "log"
 )
@@ -23,10 +25,15 @@ import (
 //   |                     data                    |   q bytes  - optional
 //   -----------------------------------------------
 
-func (im *Message) marshallMsg() *bytes.Buffer {
+//	Returns the encoded message. A value that cannot be encoded, or is longer than the 65535 bytes
+//	its value-length can express, is an error rather than a corrupted request.
+func (im *Message) marshallMsg() ([]byte, error) {
 
 	b := new(bytes.Buffer)
-	x := im.marshallAtrib()
+	x, err := im.marshallAtrib()
+	if err != nil {
+		return nil, err
+	}
 	binary.Write(b, binary.BigEndian, im.majorVer)
 	binary.Write(b, binary.BigEndian, im.minorVer)
 	binary.Write(b, binary.BigEndian, im.operationIdStatusCode)
@@ -34,7 +41,7 @@ func (im *Message) marshallMsg() *bytes.Buffer {
 	binary.Write(b, binary.BigEndian, x.Bytes())
 	binary.Write(b, binary.BigEndian, uint8(3))
 	binary.Write(b, binary.BigEndian, im.Data)
-	return b
+	return b.Bytes(), nil
 }
 
 //   Each "attribute-group" field is encoded as follows:
@@ -81,10 +88,13 @@ func (im *Message) marshallMsg() *bytes.Buffer {
 //   |                     value                   |   w bytes
 //   -----------------------------------------------
 
-func (im *Message) marshallAtrib() *bytes.Buffer {
+func (im *Message) marshallAtrib() (*bytes.Buffer, error) {
 	b := new(bytes.Buffer)
-	binary.Write(b, binary.BigEndian, uint8(TAG_OPERATION))
+	if len(im.attributeGroups) == 0 {
+		binary.Write(b, binary.BigEndian, uint8(TAG_OPERATION))
+	}
 	for _, ag := range im.attributeGroups {
+		binary.Write(b, binary.BigEndian, ag.beginAttributeGroupTag)
 		for _, a := range ag.attributes {
 			//  The "value-tag" field specifies the attribute syntax, e.g. 0x44 for the attribute syntax 'keyword'.
 			//		valueTag    byte
@@ -99,30 +109,45 @@ func (im *Message) marshallAtrib() *bytes.Buffer {
 			//		valueLength int16
 			//	The "value" field contains the value of the attribute, e.g. the textual value 'one-sided'.
 			//		value       []byte
-			
-			// cnt := utils.Start(1)
 			for iii, v := range a.values {
-				// cnt.Plus()
-				// log.Println(cnt.Get())
+				binary.Write(b, binary.BigEndian, v.valueTag)
 				if iii == 0 {
-					binary.Write(b, binary.BigEndian, v.valueTag)
 					binary.Write(b, binary.BigEndian, v.nameLength)
 					binary.Write(b, binary.BigEndian, []byte(v.name))
-					binary.Write(b, binary.BigEndian, v.valueLength)
-					if v.value == nil {
-						binary.Write(b, binary.BigEndian, 0x0000)
-					} else {
-						binary.Write(b, binary.BigEndian, v.value)
-					}
 				} else {
-					binary.Write(b, binary.BigEndian, v.valueTag)
 					binary.Write(b, binary.BigEndian, uint16(0))
-					binary.Write(b, binary.BigEndian, v.valueLength)
-					binary.Write(b, binary.BigEndian, v.value)
+				}
+				value, err := v.marshalValue()
+				if err != nil {
+					return nil, fmt.Errorf("ipp: attribute %s: %v", a.Name(), err)
+				}
+				binary.Write(b, binary.BigEndian, uint16(len(value)))
+				binary.Write(b, binary.BigEndian, value)
+				if members, ok := v.value.(collection); ok {
+					mb, err := members.marshalMembers()
+					if err != nil {
+						return nil, fmt.Errorf("ipp: attribute %s: %v", a.Name(), err)
+					}
+					b.Write(mb)
 				}
 			}
 		}
 	}
 
-	return b
+	return b, nil
+}
+
+//	Returns the encoded "value" field, out-of-band values have none
+func (v *attributeValue) marshalValue() ([]byte, error) {
+	if v.value == nil || v.Marshal == nil {
+		return nil, nil
+	}
+	x, err := v.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if len(x) > math.MaxUint16 {
+		return nil, fmt.Errorf("value of %d bytes is longer than %d", len(x), math.MaxUint16)
+	}
+	return x, nil
 }
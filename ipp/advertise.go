@@ -0,0 +1,190 @@
+package ipp
+
+import (
+	"discovery"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//   IPP Everywhere DNS-SD advertisement (PWG 5100.14 section 4.2)
+//
+//   A Printer is advertised as an _ipp._tcp (or _ipps._tcp for an ipps printer-uri) service
+//   instance with the _print subtype. The instance name is printer-dns-sd-name, printer-info or
+//   printer-name, the port and the "rp" key come from the first printer-uri-supported. The TXT
+//   record is derived from the printer description attributes:
+//
+//      txtvers=1, qtotal=1
+//      rp          path of printer-uri-supported, e.g. "ipp/print"
+//      ty          printer-make-and-model
+//      adminurl    printer-more-info
+//      note        printer-location
+//      pdl         document-format-supported
+//      kind        printer-kind
+//      Color       'T' if color-supported is true
+//      Duplex      'T' if sides-supported has more than 'one-sided'
+//      UUID        printer-uuid without "urn:uuid:"
+//      URF         urf-supported
+//      TLS         '1.2' for ipps
+
+//	WatchedPrinter is implemented by Printers that report changes of their printer description
+//	attributes, e.g. MemoryPrinter. The Advertisement of such a Printer keeps its TXT record in sync.
+type WatchedPrinter interface {
+	Watch(fn func())
+}
+
+//	Advertisement announces a Printer on the local network over mDNS
+type Advertisement struct {
+	mu        sync.Mutex
+	printer   Printer
+	responder *discovery.Responder
+}
+
+//	Starts advertising p, conn is the mDNS socket as for discovery.NewResponder (nil joins the
+//	mDNS group). A WatchedPrinter is followed, otherwise call Update after the printer attributes
+//	changed.
+func Advertise(p Printer, conn net.PacketConn) (*Advertisement, error) {
+	attrs, err := describe(p)
+	if err != nil {
+		return nil, err
+	}
+	svc := discovery.Service{Type: discovery.IPP, Subtypes: []string{"_print"}, Port: 631}
+	for _, name := range []string{"printer-dns-sd-name", "printer-info", "printer-name"} {
+		if s := attrs[name].String(); s != "" {
+			svc.Instance = s
+			break
+		}
+	}
+	if u, err := url.Parse(attrs["printer-uri-supported"].String()); err == nil {
+		if u.Scheme == "ipps" {
+			svc.Type = discovery.IPPS
+		}
+		if port, err := strconv.Atoi(u.Port()); err == nil {
+			svc.Port = port
+		}
+	}
+	svc.Txt = printerTxt(attrs)
+	r, err := discovery.NewResponder(conn, svc)
+	if err != nil {
+		return nil, err
+	}
+	a := &Advertisement{printer: p, responder: r}
+	if w, ok := p.(WatchedPrinter); ok {
+		w.Watch(func() { a.Update() })
+	}
+	return a, nil
+}
+
+//	Derives the TXT record from the current printer attributes and announces it if it changed
+func (a *Advertisement) Update() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	attrs, err := describe(a.printer)
+	if err != nil {
+		return err
+	}
+	txt := printerTxt(attrs)
+	if strings.Join(txt, "\x00") == strings.Join(a.responder.Service().Txt, "\x00") {
+		return nil
+	}
+	return a.responder.SetTxt(txt)
+}
+
+//	Returns the advertised TXT record
+func (a *Advertisement) Txt() []string {
+	return a.responder.Service().Txt
+}
+
+//	Withdraws the advertisement
+func (a *Advertisement) Close() error {
+	return a.responder.Close()
+}
+
+//	Returns the printer description attributes of p by name, as answered to Get-Printer-Attributes
+func describe(p Printer) (map[string]attribute, error) {
+	var r Request
+	r.Message = NewRequest(GET_PRINTER_ATTRIBUTES)
+	r.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	r.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en"))
+	resp := newResponseTo(&r.Message)
+	if err := p.GetPrinterAttributes(&r, &resp); err != nil {
+		return nil, err
+	}
+	ag, _ := resp.Group(TAG_PRINTER)
+	return ag.Map(), nil
+}
+
+//	Returns the IPP Everywhere TXT record of the printer attributes
+func printerTxt(attrs map[string]attribute) []string {
+	txt := []string{"txtvers=1", "qtotal=1"}
+	add := func(key, value string) {
+		if value != "" {
+			txt = append(txt, truncateTxt(key+"="+value))
+		}
+	}
+	printerUri := attrs["printer-uri-supported"].String()
+	if u, err := url.Parse(printerUri); err == nil {
+		add("rp", strings.TrimPrefix(u.Path, "/"))
+	}
+	add("ty", attrs["printer-make-and-model"].String())
+	add("adminurl", attrs["printer-more-info"].String())
+	add("note", attrs["printer-location"].String())
+	add("pdl", joinTxt("pdl", attrs["document-format-supported"].Strings()))
+	add("kind", joinTxt("kind", attrs["printer-kind"].Strings()))
+	if a, ok := attrs["color-supported"]; ok {
+		add("Color", txtBool(a.Bool()))
+	}
+	if a, ok := attrs["sides-supported"]; ok {
+		duplex := false
+		for _, s := range a.Strings() {
+			duplex = duplex || s != "one-sided"
+		}
+		add("Duplex", txtBool(duplex))
+	}
+	add("UUID", strings.TrimPrefix(attrs["printer-uuid"].String(), "urn:uuid:"))
+	if a, ok := attrs["urf-supported"]; ok {
+		add("URF", joinTxt("URF", a.Strings()))
+	}
+	if strings.HasPrefix(printerUri, "ipps:") {
+		add("TLS", "1.2")
+	}
+	return txt
+}
+
+//	Cuts a TXT string to the 255 bytes it may hold, e.g. a long ty or note, at a character boundary
+func truncateTxt(s string) string {
+	if len(s) <= 255 {
+		return s
+	}
+	s = s[:255]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+//	Joins values with commas, leaving out the values that do not fit into the 255 bytes of a
+//	TXT string
+func joinTxt(key string, values []string) string {
+	s := ""
+	for _, v := range values {
+		if len(key)+1+len(s)+1+len(v) > 255 {
+			continue
+		}
+		if s != "" {
+			s += ","
+		}
+		s += v
+	}
+	return s
+}
+
+func txtBool(b bool) string {
+	if b {
+		return "T"
+	}
+	return "F"
+}
@@ -0,0 +1,305 @@
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//   An OutputBackend receives the documents of a job once the job is processing, one call per
+//   document in document-number order. A backend error aborts the job; the job-state-reasons
+//   tell why:
+//
+//      'resources-are-not-ready'   the spool directory or command could not be used
+//      'service-off-line'          the output device could not be reached
+//      'aborted-by-system'         anything else, e.g. the connection broke during the transfer
+//
+//   A command that exits with one of the CUPS backend status codes gets the CUPS treatment:
+//
+//      2 (auth required)   the job is 'pending-held' with 'auth-info-required'
+//      3 (hold)            the job is 'pending-held' with 'job-hold-until-specified'
+//      4 (stop)            the printer is stopped, the job is 'pending' with 'printer-stopped'
+//      5 (cancel)          the job is 'canceled' with 'job-canceled-at-device'
+//      6 (retry)           the job is 'pending' with 'job-queued' and printed again later
+//      7 (retry current)   the job is 'pending' with 'job-restartable' and printed again at once
+//
+//   A held job is printed again after Release-Job. A job that is retried more than
+//   backendRetryLimit times is aborted.
+//
+//   A backend returns a *BackendError to pick the job-state-reasons keyword itself. The text of
+//   the error becomes the job-state-message.
+
+//	OutputBackend delivers the documents of processing jobs, see MemoryPrinter.SetBackend
+type OutputBackend interface {
+	Output(j Job, d JobDocument) error
+}
+
+//	BackendError is an OutputBackend error with the job-state-reasons keyword of the aborted job
+type BackendError struct {
+	Reason string // job-state-reasons, e.g. 'document-format-error'
+	Err    error
+}
+
+func (e *BackendError) Error() string {
+	return e.Reason + ": " + e.Err.Error()
+}
+
+//	How often a job is retried after CUPS_BACKEND_RETRY, CUPS_BACKEND_RETRY_CURRENT or
+//	CUPS_BACKEND_STOP before it is aborted, and how long a job waits after CUPS_BACKEND_RETRY
+const (
+	backendRetryLimit    = 5
+	backendRetryInterval = 30 * time.Second
+)
+
+//	Returns the job-state and job-state-reasons keyword of a job whose output failed with err.
+//	The job-state is 'pending' or 'pending-held' when the job is printed again later.
+func backendState(err error) (int, string) {
+	var be *BackendError
+	if errors.As(err, &be) {
+		switch be.Reason {
+		case "job-canceled-at-device":
+			return JOB_CANCELLED, be.Reason
+		case "auth-info-required", "job-hold-until-specified":
+			return JOB_HELD, be.Reason
+		}
+		return JOB_ABORTED, be.Reason
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		return JOB_ABORTED, "service-off-line"
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		switch ee.ExitCode() {
+		case CUPS_BACKEND_AUTH_REQUIRED:
+			return JOB_HELD, "auth-info-required"
+		case CUPS_BACKEND_HOLD:
+			return JOB_HELD, "job-hold-until-specified"
+		case CUPS_BACKEND_STOP:
+			return JOB_PENDING, "printer-stopped"
+		case CUPS_BACKEND_CANCEL:
+			return JOB_CANCELLED, "job-canceled-at-device"
+		case CUPS_BACKEND_RETRY:
+			return JOB_PENDING, "job-queued"
+		case CUPS_BACKEND_RETRY_CURRENT:
+			return JOB_PENDING, "job-restartable"
+		}
+	}
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return JOB_ABORTED, "resources-are-not-ready"
+	}
+	return JOB_ABORTED, "aborted-by-system"
+}
+
+// ========== spool directory ==========
+
+//	SpoolBackend writes every document to Dir as <job-id>-<document-number>.<ext> next to a
+//	<job-id>-<document-number>.json sidecar holding the job attributes. The sidecar is written
+//	last, a consumer watching the directory can take it as the sign that the document is complete.
+type SpoolBackend struct {
+	Dir string
+}
+
+//	The JSON sidecar of a spooled document
+type spoolRecord struct {
+	JobId          int                 `json:"job-id"`
+	JobUri         string              `json:"job-uri"`
+	JobName        string              `json:"job-name"`
+	User           string              `json:"job-originating-user-name"`
+	DocumentNumber int                 `json:"document-number"`
+	DocumentName   string              `json:"document-name,omitempty"`
+	DocumentFormat string              `json:"document-format"`
+	DocumentFile   string              `json:"document-file"`
+	Created        time.Time           `json:"date-time-at-creation"`
+	Attributes     map[string][]string `json:"attributes,omitempty"`
+}
+
+func (b SpoolBackend) Output(j Job, d JobDocument) error {
+	base := filepath.Join(b.Dir, strconv.Itoa(j.Id)+"-"+strconv.Itoa(d.Number))
+	file := base + formatExtension(d.DataFormat())
+	if err := ioutil.WriteFile(file, d.Data, 0644); err != nil {
+		return err
+	}
+	rec := spoolRecord{
+		JobId:          j.Id,
+		JobUri:         j.Uri,
+		JobName:        j.Name,
+		User:           j.User,
+		DocumentNumber: d.Number,
+		DocumentName:   d.Name,
+		DocumentFormat: d.Format,
+		DocumentFile:   filepath.Base(file),
+		Created:        j.Created,
+		Attributes:     make(map[string][]string),
+	}
+	for _, a := range j.Attributes {
+		rec.Attributes[a.Name()] = a.Strings()
+	}
+	js, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(base+".json", js, 0644)
+}
+
+//	Returns the file name extension of a document-format
+func formatExtension(format string) string {
+	switch format {
+	case "application/pdf":
+		return ".pdf"
+	case "application/postscript":
+		return ".ps"
+	case "image/pwg-raster":
+		return ".pwg"
+	case "image/urf":
+		return ".urf"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "text/plain":
+		return ".txt"
+	}
+	return ".prn"
+}
+
+// ========== command pipe ==========
+
+//	CommandBackend pipes every document into a command, like a CUPS filter: the document is
+//	the standard input and the command gets the arguments
+//
+//	    job-id user title copies options
+//
+//	after Args. The job attributes are passed in the environment as IPP_<NAME>, e.g.
+//	IPP_JOB_NAME, IPP_COPIES=2 or IPP_MEDIA=iso_a4_210x297mm (multiple values comma separated),
+//	together with CONTENT_TYPE (the document-format), DOCUMENT_NAME and PRINTER.
+//	A non-zero exit status fails the job, the last line the command wrote to standard error
+//	becomes the job-state-message. The CUPS backend exit status codes hold, cancel or retry the
+//	job or stop the printer, see OutputBackend.
+type CommandBackend struct {
+	Path    string        // the command to run
+	Args    []string      // arguments before the CUPS filter arguments
+	Env     []string      // additional environment, "NAME=value"
+	Printer string        // PRINTER
+	Timeout time.Duration // kills the command after Timeout, 0 for no limit
+}
+
+func (b CommandBackend) Output(j Job, d JobDocument) error {
+	ctx := context.Background()
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	copies := "1"
+	var options []string
+	for _, a := range j.Attributes {
+		if a.Name() == "copies" {
+			copies = a.String()
+			continue
+		}
+		options = append(options, a.Name()+"="+strings.Join(a.Strings(), ","))
+	}
+	args := append(append([]string{}, b.Args...), strconv.Itoa(j.Id), j.User, j.Name, copies, strings.Join(options, " "))
+	cmd := exec.CommandContext(ctx, b.Path, args...)
+	cmd.Env = append(os.Environ(), b.Env...)
+	cmd.Env = append(cmd.Env, "CONTENT_TYPE="+d.DataFormat(), "DOCUMENT_NAME="+d.Name, "PRINTER="+b.Printer)
+	cmd.Env = append(cmd.Env, jobEnvironment(j)...)
+	cmd.Stdin = bytes.NewReader(d.Data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+	if msg := lastLine(stderr.String()); msg != "" {
+		return &commandError{msg: msg, err: err}
+	}
+	return err
+}
+
+//	Returns the IPP_<NAME>=<values> environment of a job
+func jobEnvironment(j Job) []string {
+	env := []string{
+		"IPP_JOB_ID=" + strconv.Itoa(j.Id),
+		"IPP_JOB_URI=" + j.Uri,
+		"IPP_JOB_NAME=" + j.Name,
+		"IPP_JOB_ORIGINATING_USER_NAME=" + j.User,
+	}
+	for _, a := range j.Attributes {
+		name := "IPP_" + strings.ToUpper(strings.Replace(a.Name(), "-", "_", -1))
+		env = append(env, name+"="+strings.Join(a.Strings(), ","))
+	}
+	return env
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	return s[strings.LastIndex(s, "\n")+1:]
+}
+
+//	A failed command with the message it wrote to standard error
+type commandError struct {
+	msg string
+	err error
+}
+
+func (e *commandError) Error() string { return e.msg + " (" + e.err.Error() + ")" }
+func (e *commandError) Unwrap() error { return e.err }
+
+// ========== raw socket ==========
+
+//	SocketBackend sends every document to an AppSocket (JetDirect) printer, the raw TCP
+//	protocol on port 9100
+type SocketBackend struct {
+	Address string        // host:port, the port defaults to 9100
+	Timeout time.Duration // connect and transfer timeout of each document, 0 for no limit
+}
+
+//	How long SocketBackend waits for the printer to close the connection after the document
+//	was sent when it has no Timeout
+const socketDrainTimeout = 30 * time.Second
+
+func (b SocketBackend) Output(j Job, d JobDocument) error {
+	addr := b.Address
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "9100")
+	}
+	conn, err := net.DialTimeout("tcp", addr, b.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if b.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(b.Timeout))
+	}
+	if _, err = conn.Write(d.Data); err != nil {
+		return err
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		// wait for the printer to close its side so the data is known to have arrived, a printer
+		// that keeps it open past the deadline has received the data all the same
+		if err = tc.CloseWrite(); err != nil {
+			return err
+		}
+		if b.Timeout <= 0 {
+			conn.SetReadDeadline(time.Now().Add(socketDrainTimeout))
+		}
+		if _, err = io.Copy(ioutil.Discard, conn); err != nil {
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package ipp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSocketBackend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- b
+	}()
+	b := SocketBackend{Address: l.Addr().String(), Timeout: 5 * time.Second}
+	if err := b.Output(Job{Id: 1}, JobDocument{Number: 1, Data: []byte("%!PS\nshowpage\n")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(<-received); got != "%!PS\nshowpage\n" {
+		t.Errorf("printer received %q", got)
+	}
+
+	// nothing listens on the port any more
+	l.Close()
+	err = b.Output(Job{Id: 2}, JobDocument{Number: 1, Data: []byte("x")})
+	if state, reason := backendState(err); state != JOB_ABORTED || reason != "service-off-line" {
+		t.Errorf("closed port: %v %s %s, want aborted service-off-line", err, jobStateString(state), reason)
+	}
+}
+
+func TestBackendExitStatus(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		status int
+		state  int
+		reason string
+	}{
+		{CUPS_BACKEND_FAILED, JOB_ABORTED, "aborted-by-system"},
+		{CUPS_BACKEND_AUTH_REQUIRED, JOB_HELD, "auth-info-required"},
+		{CUPS_BACKEND_HOLD, JOB_HELD, "job-hold-until-specified"},
+		{CUPS_BACKEND_STOP, JOB_PENDING, "printer-stopped"},
+		{CUPS_BACKEND_CANCEL, JOB_CANCELLED, "job-canceled-at-device"},
+		{CUPS_BACKEND_RETRY, JOB_PENDING, "job-queued"},
+		{CUPS_BACKEND_RETRY_CURRENT, JOB_PENDING, "job-restartable"},
+	}
+	for _, test := range tests {
+		b := CommandBackend{Path: "sh", Args: []string{"-c", "exit " + strconv.Itoa(test.status)}}
+		err := b.Output(Job{Id: 1}, JobDocument{Number: 1})
+		if state, reason := backendState(err); state != test.state || reason != test.reason {
+			t.Errorf("exit %d: %s %s, want %s %s", test.status, jobStateString(state), reason, jobStateString(test.state), test.reason)
+		}
+	}
+}
+
+func TestFinishAfterBackendStatus(t *testing.T) {
+	finish := func(status, retries int) (*MemoryPrinter, *Job) {
+		p := NewMemoryPrinter("test", "ipp://localhost/ipp/print")
+		j := &Job{Id: 1, State: JOB_PROCESSING, retries: retries}
+		p.jobs[j.Id] = j
+		p.state = PRINTER_PROCESSING
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.finish(j, &exec.ExitError{ProcessState: exitState(t, status)})
+		return p, j
+	}
+
+	p, j := finish(CUPS_BACKEND_HOLD, 0)
+	if j.State != JOB_HELD || j.HoldUntil != "indefinite" || !j.Completed.IsZero() {
+		t.Errorf("hold: job %s until %q completed %v", jobStateString(j.State), j.HoldUntil, j.Completed)
+	}
+	if p.state != PRINTER_IDLE {
+		t.Errorf("hold: printer %d, want idle", p.state)
+	}
+
+	p, j = finish(CUPS_BACKEND_STOP, 0)
+	if j.State != JOB_PENDING || p.state != PRINTER_STOPPED {
+		t.Errorf("stop: job %s printer %d, want pending and stopped", jobStateString(j.State), p.state)
+	}
+
+	_, j = finish(CUPS_BACKEND_RETRY, 0)
+	if j.State != JOB_PENDING || !j.retryAt.After(time.Now()) || j.retries != 1 {
+		t.Errorf("retry: job %s retry at %v after %d retries", jobStateString(j.State), j.retryAt, j.retries)
+	}
+
+	_, j = finish(CUPS_BACKEND_RETRY, backendRetryLimit)
+	if j.State != JOB_ABORTED || j.StateReasons[0] != "aborted-by-system" {
+		t.Errorf("retry limit: job %s %v, want aborted", jobStateString(j.State), j.StateReasons)
+	}
+}
+
+//	Returns the process state of a command that exited with status
+func exitState(t *testing.T, status int) *os.ProcessState {
+	err := exec.Command("sh", "-c", "exit "+strconv.Itoa(status)).Run()
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Skip("sh:", err)
+	}
+	return ee.ProcessState
+}
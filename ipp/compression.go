@@ -0,0 +1,206 @@
+package ipp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+//   compression (RFC 8011 section 5.4.32)
+//
+//   The "compression" operation attribute of Print-Job, Send-Document, Print-URI and Send-URI
+//   names the algorithm the document data is compressed with, the Printer lists the algorithms
+//   it accepts in "compression-supported":
+//
+//      'none'      not compressed
+//      'deflate'   RFC 1951 (zlib without its header)
+//      'gzip'      RFC 1952
+//      'compress'  UNIX compress (LZW), not supported here
+//
+//   An algorithm the Printer does not support is rejected with client-error-compression-not-
+//   supported, data that cannot be decompressed with client-error-compression-error.
+//   Independently of IPP the whole HTTP body may be compressed, named by its Content-Encoding
+//   header ('gzip' or 'deflate').
+//
+//   A few kilobytes of compressed data can expand to gigabytes, the decompressed data is cut
+//   off at a limit (see Server.SetMaxRequestSize and DocumentFetcher.MaxSize) and reading
+//   past it fails with client-error-request-entity-too-large.
+
+//	The compression algorithms the Server decompresses
+var compressionSupported = []string{"none", "deflate", "gzip"}
+
+//	Returns a reader of the document r compressed with a compression-supported keyword, it
+//	is compressed as it is read. Close stops the compression when not all of it is read.
+func compressor(r io.Reader, compression string) (io.ReadCloser, error) {
+	var newWriter func(io.Writer) io.WriteCloser
+	switch compression {
+	case "", "none":
+		return ioutil.NopCloser(r), nil
+	case "gzip":
+		newWriter = func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	case "deflate":
+		newWriter = func(w io.Writer) io.WriteCloser {
+			z, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return z
+		}
+	default:
+		return nil, &StatusError{Code: COMPRESSION_NOT_SUPPORTED, Message: "compression " + compression + " is not supported"}
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		z := newWriter(pw)
+		_, err := io.Copy(z, r)
+		if err == nil {
+			err = z.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+//	Returns a reader of the decompressed document r, errors reading it are COMPRESSION_ERROR
+//	and more than limit decompressed bytes are REQUEST_ENTITY
+func decompressor(r io.Reader, compression string, limit int64) (io.Reader, error) {
+	switch compression {
+	case "", "none":
+		return r, nil
+	case "gzip":
+		z, err := gzip.NewReader(r)
+		if err != nil {
+			if _, ok := err.(*StatusError); !ok {
+				err = &StatusError{Code: COMPRESSION_ERROR, Message: err.Error()}
+			}
+			return nil, err
+		}
+		return sizeLimited(compressionErrors{z}, limit, "the decompressed document"), nil
+	case "deflate":
+		return sizeLimited(compressionErrors{flate.NewReader(r)}, limit, "the decompressed document"), nil
+	}
+	return nil, &StatusError{Code: COMPRESSION_NOT_SUPPORTED, Message: "compression " + compression + " is not supported"}
+}
+
+//	Reports the errors of a decompressing reader as COMPRESSION_ERROR, the status errors of the
+//	compressed stream (e.g. REQUEST_ENTITY) pass unchanged
+type compressionErrors struct {
+	r io.Reader
+}
+
+func (c compressionErrors) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	if _, ok := err.(*StatusError); err != nil && err != io.EOF && !ok {
+		err = &StatusError{Code: COMPRESSION_ERROR, Message: err.Error()}
+	}
+	return n, err
+}
+
+//	Returns a reader of r that fails with REQUEST_ENTITY once r has more than limit bytes,
+//	what names r in the message
+func sizeLimited(r io.Reader, limit int64, what string) io.Reader {
+	return &sizeLimit{r: r, left: limit, err: &StatusError{Code: REQUEST_ENTITY, Message: what + " is larger than " + strconv.FormatInt(limit, 10) + " bytes"}}
+}
+
+type sizeLimit struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (l *sizeLimit) Read(b []byte) (int, error) {
+	if l.left <= 0 {
+		// one more byte tells a stream of exactly limit bytes from a longer one
+		var one [1]byte
+		n, err := l.r.Read(one[:])
+		if n > 0 {
+			return 0, l.err
+		}
+		return 0, err
+	}
+	if int64(len(b)) > l.left {
+		b = b[:l.left]
+	}
+	n, err := l.r.Read(b)
+	l.left -= int64(n)
+	return n, err
+}
+
+//	Returns a reader of the decoded HTTP body of a Content-Encoding. 'deflate' is zlib
+//	(RFC 1950), raw deflate as sent by some clients is accepted too. More than limit decoded
+//	bytes are REQUEST_ENTITY.
+func contentDecoder(body io.Reader, encoding string, limit int64) (io.Reader, error) {
+	var decoded io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		z, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		decoded = z
+	case "deflate":
+		br := bufio.NewReader(body)
+		if h, err := br.Peek(2); err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+			z, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			decoded = z
+		} else {
+			decoded = flate.NewReader(br)
+		}
+	default:
+		return nil, &StatusError{Code: COMPRESSION_NOT_SUPPORTED, Message: "Content-Encoding " + encoding + " is not supported"}
+	}
+	return sizeLimited(decoded, limit, "the decoded request"), nil
+}
+
+//	Returns the compression-supported of a printer, looked up once per printer-uri; a printer
+//	that does not answer is taken to support 'none' only
+func (c *CupsServer) compressionSupported(printerUri string) []string {
+	c.mu.Lock()
+	supported, ok := c.compressions[printerUri]
+	c.mu.Unlock()
+	if ok {
+		return supported
+	}
+	m := c.newRequest(GET_PRINTER_ATTRIBUTES, printerUri, 0)
+	m.AppendAttribute(requestedAttributes([]string{"compression-supported"}))
+	supported = []string{"none"}
+	if r, err := c.doPrinterRequest(m, printerUri); err == nil {
+		if a, ok := r.Attribute(TAG_PRINTER, "compression-supported"); ok {
+			supported = a.Strings()
+		}
+	}
+	c.mu.Lock()
+	if c.compressions == nil {
+		c.compressions = make(map[string][]string)
+	}
+	c.compressions[printerUri] = supported
+	c.mu.Unlock()
+	return supported
+}
+
+//	Returns the compression PrintJob uses for printerUri: the one set with SetCompression if
+//	the printer supports it, otherwise 'none'
+func (c *CupsServer) documentCompression(printerUri string) string {
+	if c.compression == "none" {
+		return "none"
+	}
+	for _, s := range c.compressionSupported(printerUri) {
+		if s == c.compression || c.compression == "" && s == "gzip" {
+			return s
+		}
+	}
+	return "none"
+}
+
+//	Sets the compression of the documents PrintJob sends to printers that support it, 'gzip'
+//	(the default), 'deflate' or 'none'
+func (c *CupsServer) SetCompression(compression string) {
+	c.compression = compression
+}
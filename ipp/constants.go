@@ -43,7 +43,8 @@ const (
 	TAG_EVENT_NOTIFICATION = 0x07
 	//	0x08	Reserved (ipp-get-resources)	
 	TAG_DOCUMENT_ATTRIBUTES = 0x09 //	document-attributes-tag	[PWG5100.5]
-	//	0x0A-0x0F	Unassigned
+	TAG_SYSTEM              = 0x0a //	system-attributes-tag	[PWG5100.22]
+	//	0x0B-0x0F	Unassigned
 	/*
 	   	The remaining tables show values for the "value-tag" field, which is the first octet of an 
 	   	attribute. The "value-tag" field specifies the type of the value of the attribute.
@@ -152,11 +153,19 @@ const (
 	JOB_ABORTED    = 8
 	JOB_COMPLETE   = 9
 
-	// document-state
+	// printer-state
 	PRINTER_IDLE       = 3
 	PRINTER_PROCESSING = 4
 	PRINTER_STOPPED    = 5
 
+	// document-state	[PWG5100.5]
+	DOCUMENT_PENDING    = 3
+	DOCUMENT_PROCESSING = 5
+	DOCUMENT_STOPPED    = 6
+	DOCUMENT_CANCELLED  = 7
+	DOCUMENT_ABORTED    = 8
+	DOCUMENT_COMPLETE   = 9
+
 	ERROR     = -1
 	IDLE      = 0
 	HEADER    = 1
@@ -209,7 +218,58 @@ const (
 	RESUME_JOB                      = 0x002f
 	PROMOTE_JOB                     = 0x0030
 	SCHEDULE_JOB_AFTER              = 0x0031
-	PRIVATE                         = 0x4000
+	//	PWG 5100.5 Document Object
+	CANCEL_DOCUMENT         = 0x0033
+	GET_DOCUMENT_ATTRIBUTES = 0x0034
+	GET_DOCUMENTS           = 0x0035
+	DELETE_DOCUMENT         = 0x0036
+	SET_DOCUMENT_ATTRIBUTES = 0x0037
+	//	PWG 5100.11 Job and Printer Extensions - Set 2
+	CANCEL_JOBS      = 0x0038
+	CANCEL_MY_JOBS   = 0x0039
+	RESUBMIT_JOB     = 0x003a
+	CLOSE_JOB        = 0x003b
+	IDENTIFY_PRINTER = 0x003c
+	//	PWG 5100.18 Shared Infrastructure Extensions (INFRA)
+	ACKNOWLEDGE_DOCUMENT            = 0x003f
+	ACKNOWLEDGE_IDENTIFY_PRINTER    = 0x0040
+	ACKNOWLEDGE_JOB                 = 0x0041
+	FETCH_DOCUMENT                  = 0x0042
+	FETCH_JOB                       = 0x0043
+	GET_OUTPUT_DEVICE_ATTRIBUTES    = 0x0044
+	UPDATE_ACTIVE_JOBS              = 0x0045
+	DEREGISTER_OUTPUT_DEVICE        = 0x0046
+	UPDATE_DOCUMENT_STATUS          = 0x0047
+	UPDATE_JOB_STATUS               = 0x0048
+	UPDATE_OUTPUT_DEVICE_ATTRIBUTES = 0x0049
+	//	PWG 5100.22 System Service
+	ALLOCATE_PRINTER_RESOURCES           = 0x004b
+	CREATE_PRINTER                       = 0x004c
+	DEALLOCATE_PRINTER_RESOURCES         = 0x004d
+	DELETE_PRINTER                       = 0x004e
+	GET_PRINTERS                         = 0x004f
+	SHUTDOWN_ONE_PRINTER                 = 0x0050
+	STARTUP_ONE_PRINTER                  = 0x0051
+	CANCEL_RESOURCE                      = 0x0052
+	CREATE_RESOURCE                      = 0x0053
+	INSTALL_RESOURCE                     = 0x0054
+	SEND_RESOURCE_DATA                   = 0x0055
+	SET_RESOURCE_ATTRIBUTES              = 0x0056
+	CREATE_RESOURCE_SUBSCRIPTIONS        = 0x0057
+	CREATE_SYSTEM_SUBSCRIPTIONS          = 0x0058
+	DISABLE_ALL_PRINTERS                 = 0x0059
+	ENABLE_ALL_PRINTERS                  = 0x005a
+	GET_SYSTEM_ATTRIBUTES                = 0x005b
+	GET_SYSTEM_SUPPORTED_VALUES          = 0x005c
+	PAUSE_ALL_PRINTERS                   = 0x005d
+	PAUSE_ALL_PRINTERS_AFTER_CURRENT_JOB = 0x005e
+	REGISTER_OUTPUT_DEVICE               = 0x005f
+	RESTART_SYSTEM                       = 0x0060
+	RESUME_ALL_PRINTERS                  = 0x0061
+	SET_SYSTEM_ATTRIBUTES                = 0x0062
+	SHUTDOWN_ALL_PRINTERS                = 0x0063
+	STARTUP_ALL_PRINTERS                 = 0x0064
+	PRIVATE                              = 0x4000
 
 	//	============ CUPS ============	
 	CUPS_PRINTER_LOCAL         = 0x0000
@@ -229,15 +289,19 @@ const (
 	CUPS_PRINTER_MEDIUM        = 0x2000
 	CUPS_PRINTER_LARGE         = 0x4000
 	CUPS_PRINTER_VARIABLE      = 0x8000
-	CUPS_PRINTER_IMPLICIT      = 0x1000
-	CUPS_PRINTER_DEFAULT       = 0x2000
-	CUPS_PRINTER_FAX           = 0x4000
-	CUPS_PRINTER_REJECTING     = 0x8000
-	CUPS_PRINTER_DELETE        = 0x1000
-	CUPS_PRINTER_NOT_SHARED    = 0x2000
-	CUPS_PRINTER_AUTHENTICATED = 0x4000
-	CUPS_PRINTER_COMMANDS      = 0x8000
-	CUPS_PRINTER_OPTIONS       = 0xe6ff
+	CUPS_PRINTER_IMPLICIT      = 0x10000
+	CUPS_PRINTER_DEFAULT       = 0x20000
+	CUPS_PRINTER_FAX           = 0x40000
+	CUPS_PRINTER_REJECTING     = 0x80000
+	CUPS_PRINTER_DELETE        = 0x100000
+	CUPS_PRINTER_NOT_SHARED    = 0x200000
+	CUPS_PRINTER_AUTHENTICATED = 0x400000
+	CUPS_PRINTER_COMMANDS      = 0x800000
+	CUPS_PRINTER_DISCOVERED    = 0x1000000
+	CUPS_PRINTER_SCANNER       = 0x2000000
+	CUPS_PRINTER_MFP           = 0x4000000
+	CUPS_PRINTER_3D            = 0x8000000
+	CUPS_PRINTER_OPTIONS       = 0x6fffc
 
 	CUPS_GET_DEFAULT      = 0x4001
 	CUPS_GET_PRINTERS     = 0x4002
@@ -254,6 +318,16 @@ const (
 	CUPS_MOVE_JOB         = 0x400d
 	CUPS_AUTHENTICATE_JOB = 0x400e
 
+	//	exit status of CUPS backends
+	CUPS_BACKEND_OK            = 0
+	CUPS_BACKEND_FAILED        = 1
+	CUPS_BACKEND_AUTH_REQUIRED = 2
+	CUPS_BACKEND_HOLD          = 3
+	CUPS_BACKEND_STOP          = 4
+	CUPS_BACKEND_CANCEL        = 5
+	CUPS_BACKEND_RETRY         = 6
+	CUPS_BACKEND_RETRY_CURRENT = 7
+
 	//	============ Status Codes ===========
 	OK                           = 0x0000
 	OK_SUBST                     = 0x0001
@@ -1,10 +1,12 @@
 package ipp
 
 import (
-	"os"
-	"fmt"
-	"net/http"
-	"io/ioutil"
+	"net"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type CupsServer struct {
@@ -12,16 +14,92 @@ type CupsServer struct {
 	username       string
 	password       string
 	requestCounter int32
+	compression    string              // see SetCompression
+	mu             sync.Mutex          // guards compressions
+	compressions   map[string][]string // compression-supported by printer-uri
 }
 
+//	Sets the CUPS host, e.g. "192.168.1.8" or "print-server:631"
 func (c *CupsServer) SetServer(server string) {
 	c.uri = server
 }
 
+//	Sets the requesting-user-name and the credentials used for HTTP Basic authentication
+func (c *CupsServer) SetUser(username, password string) {
+	c.username = username
+	c.password = password
+}
+
 func (c *CupsServer) CreateRequest(operationId uint16) Message {
 	m := newMessage(operationId)
 	return m
 }
+
+//	Creates a request with the attributes every operation requires, in the order they MUST be sent:
+//	attributes-charset, attributes-natural-language, the target and requesting-user-name.
+//	The target is printer-uri and job-id, if given, or job-uri if only the job-id is given.
+func (c *CupsServer) newRequest(operationId uint16, printerUri string, jobId int) Message {
+	m := c.CreateRequest(operationId)
+	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
+	if printerUri != "" {
+		m.AddAttribute(TAG_URI, "printer-uri", uri(printerUri))
+		if jobId != 0 {
+			m.AddAttribute(TAG_INTEGER, "job-id", integer(jobId))
+		}
+	} else if jobId != 0 {
+		m.AddAttribute(TAG_URI, "job-uri", uri(c.JobUri(jobId)))
+	}
+	m.AddAttribute(TAG_NAME, "requesting-user-name", nameWithoutLanguage(c.requestingUser()))
+	return m
+}
+
+func (c *CupsServer) requestingUser() string {
+	return requestingUser(c.username)
+}
+
+//	Returns the requesting-user-name of a client: its username, or the name of the OS user
+//	when it has none
+func requestingUser(username string) string {
+	if username != "" {
+		return username
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "anonymous"
+}
+
+//	Returns host:port of the server, PORT (631) is used when none was given
+func (c *CupsServer) host() string {
+	if _, _, err := net.SplitHostPort(c.uri); err == nil {
+		return c.uri
+	}
+	return net.JoinHostPort(c.uri, strconv.Itoa(PORT))
+}
+
+//	Returns the printer-uri of a CUPS queue, e.g. ipp://192.168.1.8:631/printers/laser.
+//	Names that already are uris are returned unchanged.
+func (c *CupsServer) PrinterUri(name string) string {
+	if strings.Contains(name, "://") {
+		return name
+	}
+	return "ipp://" + c.host() + "/printers/" + name
+}
+
+//	Returns the printer-uri of a CUPS class, e.g. ipp://192.168.1.8:631/classes/floor2.
+//	Names that already are uris are returned unchanged.
+func (c *CupsServer) ClassUri(name string) string {
+	if strings.Contains(name, "://") {
+		return name
+	}
+	return "ipp://" + c.host() + "/classes/" + name
+}
+
+//	Returns the job-uri of a job, e.g. ipp://192.168.1.8:631/jobs/147
+func (c *CupsServer) JobUri(jobId int) string {
+	return "ipp://" + c.host() + "/jobs/" + strconv.Itoa(jobId)
+}
 /*
  Octets           Symbolic Value               Protocol field
 
@@ -32,66 +110,106 @@ func (c *CupsServer) CreateRequest(operationId uint16) Message {
  0x47             charset type                 value-tag
  */
 
-func (c *CupsServer) GetPrinters() {
+//	CUPS-Get-Printers: returns every printer queue of the server, classes are left out
+func (c *CupsServer) GetPrinters() ([]Destination, error) {
 	m := c.CreateRequest(CUPS_GET_PRINTERS)
 	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
 	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
-	m.AddAttribute(TAG_KEYWORD, "requested-attributes", keyword("printer-name"))
+	m.AppendAttribute(requestedAttributes(destinationAttributes))
 	m.AddAttribute(TAG_ENUM, "printer-type", enum(0))
-	m.AddAttribute(TAG_ENUM, "printer-type-mask", enum(1))
-	c.DoRequest(m)
-	
+	m.AddAttribute(TAG_ENUM, "printer-type-mask", enum(CUPS_PRINTER_CLASS))
+	r, err := c.DoRequest(m)
+	return destinations(r, err)
 }
 
-func (c *CupsServer) GetPrinterAttributes() {
-	m := c.CreateRequest(GET_PRINTER_ATTRIBUTES)
+//	CUPS-Get-Classes: returns every class of the server including its member-names
+func (c *CupsServer) GetClasses() ([]Destination, error) {
+	m := c.CreateRequest(CUPS_GET_CLASSES)
 	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
 	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
-	m.AddAttribute(TAG_URI, "printer-uri", uri("ipp://192.168.1.1:631/ipp/"))
-	
-	a := NewAttribute()
-	a.AddValue(TAG_KEYWORD, "requested-attributes", keyword("copies-supported"))
-	a.AddValue(TAG_KEYWORD, "", keyword("document-format-supported"))
-	a.AddValue(TAG_KEYWORD, "", keyword("printer-is-accepting-jobs"))
-	a.AddValue(TAG_KEYWORD, "", keyword("printer-state"))
-	a.AddValue(TAG_KEYWORD, "", keyword("printer-state-message"))
-	a.AddValue(TAG_KEYWORD, "", keyword("printer-state-reasons"))
-	
-	m.AppendAttribute(a)
-	
-	c.DoRequest(m)
-	
-}
-
-func (c *CupsServer) PrintTestPage() {
-	m := c.CreateRequest(PAUSE_PRINTER)
+	m.AppendAttribute(requestedAttributes(append(destinationAttributes, "member-names", "member-uris")))
+	r, err := c.DoRequest(m)
+	return destinations(r, err)
+}
+
+//	CUPS-Get-Default: returns the default destination of the server
+func (c *CupsServer) GetDefault() (Destination, error) {
+	m := c.CreateRequest(CUPS_GET_DEFAULT)
 	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
 	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
-	c.DoRequest(m)
-	
+	m.AppendAttribute(requestedAttributes(append(destinationAttributes, "member-names", "member-uris")))
+	r, err := c.DoRequest(m)
+	if err != nil {
+		return Destination{}, err
+	}
+	ag, ok := r.Group(TAG_PRINTER)
+	if !ok {
+		return Destination{}, &StatusError{Code: NOT_FOUND, Message: "no default destination"}
+	}
+	return newDestination(ag), nil
 }
 
-func (c *CupsServer) DoRequest(m Message) {
-
-    fii, _ := os.Create("/Users/tmartino/aC")
-	defer fii.Close()
-	s := m.marshallMsg()
-	fii.Write(s.Bytes())
-	// "http://192.168.1.8:631/ipp/printer" "application/ipp"
-	
-	resp, err := http.Post("http://192.168.1.8:631/ipp/printer", "application/ipp", s)
+//	Get-Printer-Attributes: returns the Printer attributes of printer (see PrinterUri) keyed by
+//	name. requested are the requested-attributes, none returns what the printer sends by default.
+//	The request is sent to the host and path of the printer-uri.
+func (c *CupsServer) GetPrinterAttributes(printer string, requested ...string) (map[string]attribute, error) {
+	printerUri := c.PrinterUri(printer)
+	m := c.newRequest(GET_PRINTER_ATTRIBUTES, printerUri, 0)
+	if len(requested) > 0 {
+		a := NewAttribute()
+		for _, name := range requested {
+			a.AddValue(TAG_KEYWORD, "requested-attributes", keyword(name))
+		}
+		m.AppendAttribute(a)
+	}
+	r, err := c.doPrinterRequest(m, printerUri)
 	if err != nil {
-		fmt.Println("err: ",err)
+		return nil, err
 	}
-  body, errr := ioutil.ReadAll(resp.Body)
-	if errr != nil {
-		fmt.Println("errr:   ", errr)
+	ag, _ := r.Group(TAG_PRINTER)
+	return ag.Map(), nil
+}
+
+//	The CUPS banner file that makes the scheduler render its standard test page
+const testPage = "#CUPS-BANNER\nTemplate testprint\nShow printer-name printer-info printer-location printer-make-and-model printer-driver-name printer-driver-version paper-size imageable-area job-id options\n"
+
+//	Prints the CUPS test page on printer (see PrinterUri) and returns the job-id
+func (c *CupsServer) PrintTestPage(printer string) (int, error) {
+	printerUri := c.PrinterUri(printer)
+	m := c.newRequest(PRINT_JOB, printerUri, 0)
+	m.AddAttribute(TAG_NAME, "job-name", nameWithoutLanguage("Test Page"))
+	m.AddAttribute(TAG_MIMETYPE, "document-format", mimeMediaType("application/vnd.cups-banner"))
+	m.Data = []byte(testPage)
+	r, err := c.doPrinterRequest(m, printerUri)
+	if err != nil {
+		return 0, err
 	}
-  fmt.Println("Response Body: ", string(body))
-  fmt.Println("Response Body bytes[]: ", body)
-  fmt.Println("End Tag: ", TAG_END)
-  fmt.Println("Header: ", resp.Header)
-  x, eerr := ParseMessage(body)
-  fmt.Println("Message: ", x.attributeGroups[1].attributes[0], len(x.attributeGroups[1].attributes[0].values), "eerr: ", eerr)
+	a, _ := r.Attribute(TAG_JOB, "job-id")
+	return a.Int(), nil
+}
+
+//	Sends the request to the server root and returns the response.
+//	A response status other than successful-ok* is returned as a *StatusError.
+func (c *CupsServer) DoRequest(m Message) (Message, error) {
+	return c.doRequest(m, "/")
+}
+
+//	Sends the request to resource, e.g. the path "/ipp/print" of a printer-uri, and returns the
+//	response. A response status other than successful-ok* is returned as a *StatusError along
+//	with the response.
+func (c *CupsServer) DoRequestTo(m Message, resource string) (Message, error) {
+	return c.doRequest(m, resource)
+}
+
+//	Sends the request to resource, e.g. "/admin/" for operations that need administrative rights
+func (c *CupsServer) doRequest(m Message, resource string) (Message, error) {
+	m.requestId = atomic.AddInt32(&c.requestCounter, 1)
+	return postMessage("http://"+c.host()+resource, m, c.username, c.password)
+}
 
+//	Sends the request to the host and path of printerUri rather than to the server, e.g.
+//	ipp://192.168.1.8/ipp/print is posted to http://192.168.1.8:631/ipp/print
+func (c *CupsServer) doPrinterRequest(m Message, printerUri string) (Message, error) {
+	m.requestId = atomic.AddInt32(&c.requestCounter, 1)
+	return postMessage(httpUrl(printerUri), m, c.username, c.password)
 }
@@ -0,0 +1,147 @@
+package ipp
+
+import (
+	"io/ioutil"
+)
+
+//   The CUPS administration operations are:
+//
+//      CUPS-Add-Modify-Printer (0x4003)
+//      CUPS-Delete-Printer (0x4004)
+//      CUPS-Add-Modify-Class (0x4006)
+//      CUPS-Delete-Class (0x4007)
+//      CUPS-Accept-Jobs (0x4008)
+//      CUPS-Reject-Jobs (0x4009)
+//      CUPS-Set-Default (0x400A)
+//
+//   The target is the "printer-uri" of the queue, ipp://server/printers/name for printers and
+//   ipp://server/classes/name for classes. The new settings are supplied in the printer-attributes
+//   group; attributes that are left out keep their current value. A PPD file is sent as the
+//   document data of a CUPS-Add-Modify-Printer request.
+//   The requests are sent to /admin/ which normally requires an authenticated user, see SetUser.
+
+//	PrinterConfig holds the settings of a CUPS-Add-Modify-Printer or CUPS-Add-Modify-Class request,
+//	empty fields are left out of the request and keep their current value.
+type PrinterConfig struct {
+	DeviceUri string // device-uri, e.g. "socket://10.0.0.12:9100" (printers only)
+	PPDName   string // ppd-name, e.g. "everywhere" or a ppd-name from CUPS-Get-PPDs (printers only)
+	PPDFile   string // path of a PPD file that is uploaded with the request (printers only)
+	Info      string // printer-info
+	Location  string // printer-location
+	Shared    *bool  // printer-is-shared
+}
+
+//	Creates (or modifies) a printer queue and makes it ready for use: the queue is started
+//	(printer-state idle) and accepts jobs, like lpadmin -E does.
+func (c *CupsServer) AddPrinter(name string, cfg PrinterConfig) error {
+	m, err := c.printerConfigRequest(CUPS_ADD_PRINTER, c.PrinterUri(name), cfg)
+	if err != nil {
+		return err
+	}
+	m.AddGroupAttribute(TAG_PRINTER, TAG_BOOLEAN, "printer-is-accepting-jobs", Boolean(true))
+	m.AddGroupAttribute(TAG_PRINTER, TAG_ENUM, "printer-state", enum(PRINTER_IDLE))
+	_, err = c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Changes the settings of an existing printer queue, its state is left alone
+func (c *CupsServer) ModifyPrinter(name string, cfg PrinterConfig) error {
+	m, err := c.printerConfigRequest(CUPS_ADD_PRINTER, c.PrinterUri(name), cfg)
+	if err != nil {
+		return err
+	}
+	_, err = c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Deletes a printer queue and all of its jobs
+func (c *CupsServer) DeletePrinter(name string) error {
+	m := c.newRequest(CUPS_DELETE_PRINTER, c.PrinterUri(name), 0)
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Creates or modifies a class, members are the names (or printer-uris) of its printers and replace
+//	the current members. A nil members leaves the membership unchanged.
+//	DeviceUri, PPDName and PPDFile of cfg do not apply to classes and are ignored.
+func (c *CupsServer) AddClass(name string, members []string, cfg PrinterConfig) error {
+	m := c.newRequest(CUPS_ADD_CLASS, c.ClassUri(name), 0)
+	m.AddGroup(TAG_PRINTER)
+	if members != nil {
+		a := NewAttribute()
+		for _, member := range members {
+			a.AddValue(TAG_URI, "member-uris", uri(c.PrinterUri(member)))
+		}
+		m.AppendGroupAttribute(TAG_PRINTER, a)
+	}
+	addPrinterInfo(&m, cfg)
+	m.AddGroupAttribute(TAG_PRINTER, TAG_BOOLEAN, "printer-is-accepting-jobs", Boolean(true))
+	m.AddGroupAttribute(TAG_PRINTER, TAG_ENUM, "printer-state", enum(PRINTER_IDLE))
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Deletes a class, its member printers are not affected
+func (c *CupsServer) DeleteClass(name string) error {
+	m := c.newRequest(CUPS_DELETE_CLASS, c.ClassUri(name), 0)
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Makes a printer (or class uri) the default destination of the server
+func (c *CupsServer) SetDefault(name string) error {
+	m := c.newRequest(CUPS_SET_DEFAULT, c.PrinterUri(name), 0)
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Makes a queue accept new jobs again
+func (c *CupsServer) AcceptJobs(name string) error {
+	m := c.newRequest(CUPS_ACCEPT_JOBS, c.PrinterUri(name), 0)
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Makes a queue reject new jobs, reason is shown to users as the printer-state-message
+func (c *CupsServer) RejectJobs(name string, reason string) error {
+	m := c.newRequest(CUPS_REJECT_JOBS, c.PrinterUri(name), 0)
+	if reason != "" {
+		m.AddAttribute(TAG_TEXT, "printer-state-message", textWithoutLanguage(reason))
+	}
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	Builds a CUPS-Add-Modify-Printer request, the PPD file (if any) becomes the request data
+func (c *CupsServer) printerConfigRequest(operationId uint16, printerUri string, cfg PrinterConfig) (Message, error) {
+	m := c.newRequest(operationId, printerUri, 0)
+	m.AddGroup(TAG_PRINTER)
+	if cfg.DeviceUri != "" {
+		m.AddGroupAttribute(TAG_PRINTER, TAG_URI, "device-uri", uri(cfg.DeviceUri))
+	}
+	if cfg.PPDName != "" {
+		m.AddGroupAttribute(TAG_PRINTER, TAG_NAME, "ppd-name", nameWithoutLanguage(cfg.PPDName))
+	}
+	addPrinterInfo(&m, cfg)
+	if cfg.PPDFile != "" {
+		ppd, err := ioutil.ReadFile(cfg.PPDFile)
+		if err != nil {
+			return m, err
+		}
+		m.Data = ppd
+	}
+	return m, nil
+}
+
+//	Adds the settings printers and classes have in common
+func addPrinterInfo(m *Message, cfg PrinterConfig) {
+	if cfg.Info != "" {
+		m.AddGroupAttribute(TAG_PRINTER, TAG_TEXT, "printer-info", textWithoutLanguage(cfg.Info))
+	}
+	if cfg.Location != "" {
+		m.AddGroupAttribute(TAG_PRINTER, TAG_TEXT, "printer-location", textWithoutLanguage(cfg.Location))
+	}
+	if cfg.Shared != nil {
+		m.AddGroupAttribute(TAG_PRINTER, TAG_BOOLEAN, "printer-is-shared", Boolean(*cfg.Shared))
+	}
+}
@@ -0,0 +1,148 @@
+package ipp
+
+import (
+	"time"
+)
+
+//   CUPS-Get-Devices (0x400B)
+//
+//   Runs the CUPS backends and returns one printer-attributes group per device that was found.
+//   The client OPTIONALLY supplies "include-schemes" and "exclude-schemes" (1setOf name) to select
+//   the backends that are run, "timeout" (integer) the number of seconds to wait for the backends
+//   and "limit" (integer) the maximum number of devices to return.
+//
+//   CUPS-Get-PPDs (0x400C)
+//
+//   Returns one printer-attributes group per PPD file (or driver) matching the supplied
+//   "ppd-make" (text), "ppd-make-and-model" (text), "ppd-device-id" (text) and
+//   "ppd-natural-language" (naturalLanguage) operation attributes. "include-schemes",
+//   "exclude-schemes" and "limit" work as for CUPS-Get-Devices.
+
+//	Device is a device found by a CUPS backend
+type Device struct {
+	Class        string // device-class, 'direct', 'network', 'file' or 'serial'
+	Info         string // device-info
+	MakeAndModel string // device-make-and-model
+	Uri          string // device-uri
+	Id           string // device-id, the IEEE-1284 device id
+	Location     string // device-location
+}
+
+//	DeviceFilter narrows CUPS-Get-Devices, empty fields are left out of the request
+type DeviceFilter struct {
+	IncludeSchemes []string      // include-schemes, e.g. "usb", "dnssd"
+	ExcludeSchemes []string      // exclude-schemes
+	Timeout        time.Duration // timeout, rounded to seconds
+	Limit          int           // limit
+}
+
+//	PPD is a PPD file or driver known to the server
+type PPD struct {
+	Name            string   // ppd-name, the value for PrinterConfig.PPDName
+	NaturalLanguage []string // ppd-natural-language
+	Make            string   // ppd-make
+	MakeAndModel    string   // ppd-make-and-model
+	DeviceId        string   // ppd-device-id
+	Product         []string // ppd-product
+	PSVersion       []string // ppd-psversion
+	Type            string   // ppd-type
+	ModelNumber     int      // ppd-model-number
+}
+
+//	PPDFilter narrows CUPS-Get-PPDs, empty fields are left out of the request
+type PPDFilter struct {
+	Make            string   // ppd-make, e.g. "HP"
+	MakeAndModel    string   // ppd-make-and-model
+	DeviceId        string   // ppd-device-id
+	NaturalLanguage string   // ppd-natural-language, e.g. "en"
+	IncludeSchemes  []string // include-schemes, e.g. "drv", "everywhere"
+	ExcludeSchemes  []string // exclude-schemes
+	Limit           int      // limit
+}
+
+//	CUPS-Get-Devices: returns the devices the backends of the server found
+func (c *CupsServer) GetDevices(f DeviceFilter) ([]Device, error) {
+	m := c.newRequest(CUPS_GET_DEVICES, "", 0)
+	addSchemes(&m, f.IncludeSchemes, f.ExcludeSchemes)
+	if f.Timeout > 0 {
+		m.AddAttribute(TAG_INTEGER, "timeout", integer(f.Timeout/time.Second))
+	}
+	if f.Limit > 0 {
+		m.AddAttribute(TAG_INTEGER, "limit", integer(f.Limit))
+	}
+	r, err := c.doRequest(m, "/")
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	var devices []Device
+	for _, ag := range r.GroupsOf(TAG_PRINTER) {
+		a := ag.Map()
+		devices = append(devices, Device{
+			Class:        a["device-class"].String(),
+			Info:         a["device-info"].String(),
+			MakeAndModel: a["device-make-and-model"].String(),
+			Uri:          a["device-uri"].String(),
+			Id:           a["device-id"].String(),
+			Location:     a["device-location"].String(),
+		})
+	}
+	return devices, nil
+}
+
+//	CUPS-Get-PPDs: returns the PPD files and drivers of the server matching the filter
+func (c *CupsServer) GetPPDs(f PPDFilter) ([]PPD, error) {
+	m := c.newRequest(CUPS_GET_PPDS, "", 0)
+	addSchemes(&m, f.IncludeSchemes, f.ExcludeSchemes)
+	if f.Make != "" {
+		m.AddAttribute(TAG_TEXT, "ppd-make", textWithoutLanguage(f.Make))
+	}
+	if f.MakeAndModel != "" {
+		m.AddAttribute(TAG_TEXT, "ppd-make-and-model", textWithoutLanguage(f.MakeAndModel))
+	}
+	if f.DeviceId != "" {
+		m.AddAttribute(TAG_TEXT, "ppd-device-id", textWithoutLanguage(f.DeviceId))
+	}
+	if f.NaturalLanguage != "" {
+		m.AddAttribute(TAG_LANGUAGE, "ppd-natural-language", naturalLanguage(f.NaturalLanguage))
+	}
+	if f.Limit > 0 {
+		m.AddAttribute(TAG_INTEGER, "limit", integer(f.Limit))
+	}
+	r, err := c.doRequest(m, "/")
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	var ppds []PPD
+	for _, ag := range r.GroupsOf(TAG_PRINTER) {
+		a := ag.Map()
+		ppds = append(ppds, PPD{
+			Name:            a["ppd-name"].String(),
+			NaturalLanguage: a["ppd-natural-language"].Strings(),
+			Make:            a["ppd-make"].String(),
+			MakeAndModel:    a["ppd-make-and-model"].String(),
+			DeviceId:        a["ppd-device-id"].String(),
+			Product:         a["ppd-product"].Strings(),
+			PSVersion:       a["ppd-psversion"].Strings(),
+			Type:            a["ppd-type"].String(),
+			ModelNumber:     a["ppd-model-number"].Int(),
+		})
+	}
+	return ppds, nil
+}
+
+//	Adds include-schemes and exclude-schemes
+func addSchemes(m *Message, include, exclude []string) {
+	for _, s := range []struct {
+		name    string
+		schemes []string
+	}{{"include-schemes", include}, {"exclude-schemes", exclude}} {
+		if len(s.schemes) == 0 {
+			continue
+		}
+		a := NewAttribute()
+		for _, scheme := range s.schemes {
+			a.AddValue(TAG_NAME, s.name, nameWithoutLanguage(scheme))
+		}
+		m.AppendAttribute(a)
+	}
+}
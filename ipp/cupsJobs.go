@@ -0,0 +1,49 @@
+package ipp
+
+import (
+
+)
+
+//   CUPS-Move-Job (0x400D)
+//
+//   Moves a job, or all jobs of a printer, to another printer. The target is "printer-uri" and
+//   "job-id" for a single job, or just "printer-uri" for all jobs of that printer. The new
+//   destination is supplied as "job-printer-uri" in the job-attributes group.
+//
+//   CUPS-Authenticate-Job (0x400E)
+//
+//   Supplies the authentication information for a job that is held with the job-state-reason
+//   'cups-held-for-authentication'. The "auth-info" (1setOf text(MAX)) operation attribute holds
+//   one value for each keyword of the printer's "auth-info-required", e.g. username and password.
+//   The job is released when the authentication information is accepted.
+
+//	Moves one job to the printer dest (a queue name or printer-uri)
+func (c *CupsServer) MoveJob(jobId int, dest string) error {
+	m := c.newRequest(CUPS_MOVE_JOB, "", jobId)
+	m.AddGroupAttribute(TAG_JOB, TAG_URI, "job-printer-uri", uri(c.PrinterUri(dest)))
+	_, err := c.doRequest(m, "/jobs/")
+	return err
+}
+
+//	Moves every job of the printer from to the printer dest, e.g. off a failed printer onto a sibling queue
+func (c *CupsServer) MoveAllJobs(from, dest string) error {
+	m := c.newRequest(CUPS_MOVE_JOB, c.PrinterUri(from), 0)
+	m.AddGroupAttribute(TAG_JOB, TAG_URI, "job-printer-uri", uri(c.PrinterUri(dest)))
+	_, err := c.doRequest(m, "/jobs/")
+	return err
+}
+
+//	Supplies auth-info for a job held for authentication, one value per auth-info-required keyword
+//	of the printer in the same order, e.g. []string{"username", "password"}
+func (c *CupsServer) AuthenticateJob(jobId int, authInfo []string) error {
+	m := c.newRequest(CUPS_AUTHENTICATE_JOB, "", jobId)
+	if len(authInfo) > 0 {
+		a := NewAttribute()
+		for _, info := range authInfo {
+			a.AddValue(TAG_TEXT, "auth-info", textWithoutLanguage(info))
+		}
+		m.AppendAttribute(a)
+	}
+	_, err := c.doRequest(m, "/jobs/")
+	return err
+}
@@ -0,0 +1,142 @@
+package ipp
+
+import (
+	"strings"
+)
+
+//   CUPS-Get-Printers, CUPS-Get-Classes and CUPS-Get-Default return one printer-attributes
+//   group per destination. "printer-type" is a CUPS extension, an enum whose value is the
+//   bitwise OR of the CUPS_PRINTER_* flags describing the destination.
+
+//	PrinterType holds the CUPS_PRINTER_* bits of "printer-type"
+type PrinterType uint32
+
+var printerTypeNames = []struct {
+	bit  PrinterType
+	name string
+}{
+	{CUPS_PRINTER_CLASS, "class"},
+	{CUPS_PRINTER_REMOTE, "remote"},
+	{CUPS_PRINTER_BW, "bw"},
+	{CUPS_PRINTER_COLOR, "color"},
+	{CUPS_PRINTER_DUPLEX, "duplex"},
+	{CUPS_PRINTER_STAPLE, "staple"},
+	{CUPS_PRINTER_COPIES, "copies"},
+	{CUPS_PRINTER_COLLATE, "collate"},
+	{CUPS_PRINTER_PUNCH, "punch"},
+	{CUPS_PRINTER_COVER, "cover"},
+	{CUPS_PRINTER_BIND, "bind"},
+	{CUPS_PRINTER_SORT, "sort"},
+	{CUPS_PRINTER_SMALL, "small"},
+	{CUPS_PRINTER_MEDIUM, "medium"},
+	{CUPS_PRINTER_LARGE, "large"},
+	{CUPS_PRINTER_VARIABLE, "variable"},
+	{CUPS_PRINTER_IMPLICIT, "implicit"},
+	{CUPS_PRINTER_DEFAULT, "default"},
+	{CUPS_PRINTER_FAX, "fax"},
+	{CUPS_PRINTER_REJECTING, "rejecting"},
+	{CUPS_PRINTER_DELETE, "delete"},
+	{CUPS_PRINTER_NOT_SHARED, "not-shared"},
+	{CUPS_PRINTER_AUTHENTICATED, "authenticated"},
+	{CUPS_PRINTER_COMMANDS, "commands"},
+	{CUPS_PRINTER_DISCOVERED, "discovered"},
+	{CUPS_PRINTER_SCANNER, "scanner"},
+	{CUPS_PRINTER_MFP, "mfp"},
+	{CUPS_PRINTER_3D, "3d"},
+}
+
+//	Returns true if all bits of flag are set
+func (t PrinterType) Has(flag PrinterType) bool {
+	return t&flag == flag
+}
+
+//	Returns the set flags separated by "|", e.g. "remote|color|duplex".
+//	A local printer without any other flag is "local".
+func (t PrinterType) String() string {
+	var names []string
+	for _, n := range printerTypeNames {
+		if t&n.bit != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "local"
+	}
+	return strings.Join(names, "|")
+}
+
+//	Destination is a printer queue or class as listed by CUPS-Get-Printers, CUPS-Get-Classes
+//	and CUPS-Get-Default
+type Destination struct {
+	Name            string      // printer-name
+	Uri             string      // printer-uri-supported
+	DeviceUri       string      // device-uri
+	Location        string      // printer-location
+	Info            string      // printer-info
+	MakeAndModel    string      // printer-make-and-model
+	State           int         // printer-state, PRINTER_IDLE, PRINTER_PROCESSING or PRINTER_STOPPED
+	StateReasons    []string    // printer-state-reasons
+	StateMessage    string      // printer-state-message
+	IsAcceptingJobs bool        // printer-is-accepting-jobs
+	IsShared        bool        // printer-is-shared
+	Type            PrinterType // printer-type
+	MemberNames     []string    // member-names, classes only
+	MemberUris      []string    // member-uris, classes only
+}
+
+//	The requested-attributes of the listing operations
+var destinationAttributes = []string{
+	"printer-name",
+	"printer-uri-supported",
+	"device-uri",
+	"printer-location",
+	"printer-info",
+	"printer-make-and-model",
+	"printer-state",
+	"printer-state-reasons",
+	"printer-state-message",
+	"printer-is-accepting-jobs",
+	"printer-is-shared",
+	"printer-type",
+}
+
+func newDestination(ag attributeGroup) Destination {
+	a := ag.Map()
+	var d Destination
+	d.Name = a["printer-name"].String()
+	d.Uri = a["printer-uri-supported"].String()
+	d.DeviceUri = a["device-uri"].String()
+	d.Location = a["printer-location"].String()
+	d.Info = a["printer-info"].String()
+	d.MakeAndModel = a["printer-make-and-model"].String()
+	d.State = a["printer-state"].Int()
+	d.StateReasons = a["printer-state-reasons"].Strings()
+	d.StateMessage = a["printer-state-message"].String()
+	d.IsAcceptingJobs = a["printer-is-accepting-jobs"].Bool()
+	d.IsShared = a["printer-is-shared"].Bool()
+	d.Type = PrinterType(a["printer-type"].Int())
+	d.MemberNames = a["member-names"].Strings()
+	d.MemberUris = a["member-uris"].Strings()
+	return d
+}
+
+//	Returns one Destination per printer-attributes group
+func destinations(r Message, err error) ([]Destination, error) {
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	var dests []Destination
+	for _, ag := range r.GroupsOf(TAG_PRINTER) {
+		dests = append(dests, newDestination(ag))
+	}
+	return dests, nil
+}
+
+//	CUPS answers the listing operations with client-error-not-found when there is nothing to list,
+//	that is an empty list and not an error
+func listError(err error) error {
+	if se, ok := err.(*StatusError); ok && se.Code == NOT_FOUND {
+		return nil
+	}
+	return err
+}
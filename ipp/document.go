@@ -0,0 +1,142 @@
+package ipp
+
+//   PWG 5100.5 IPP Document Object
+//
+//   Every document of a job is a Document object identified by the job and its
+//   "document-number" (integer(1:MAX), starting at 1 in the order the documents were received).
+//   Document operations target the job ("job-uri", or "printer-uri" and "job-id") and supply
+//   "document-number" after the job target; the Printer answers with one document-attributes
+//   group (tag 0x09) per document.
+//
+//      Cancel-Document (0x0033)            cancels one document of a job
+//      Get-Document-Attributes (0x0034)    returns the document-attributes group of one document
+//      Get-Documents (0x0035)              returns one document-attributes group per document of a job
+//      Set-Document-Attributes (0x0037)    changes the attributes supplied in the document-attributes group
+//
+//   The document-state values are those of job-state without 'pending-held': 'pending' (3),
+//   'processing' (5), 'processing-stopped' (6), 'canceled' (7), 'aborted' (8) and 'completed' (9).
+
+//	Document is a document of a job as returned by Get-Document-Attributes and Get-Documents
+type Document struct {
+	Number               int         // document-number
+	JobId                int         // document-job-id
+	Name                 string      // document-name
+	Format               string      // document-format
+	FormatDetected       string      // document-format-detected
+	State                int         // document-state, DOCUMENT_PENDING to DOCUMENT_COMPLETE
+	StateReasons         []string    // document-state-reasons
+	StateMessage         string      // document-state-message
+	Impressions          int         // impressions, 0 if the Printer does not know
+	ImpressionsCompleted int         // impressions-completed
+	KOctets              int         // k-octets
+	Attributes           []attribute // every attribute of the document-attributes group
+}
+
+//	The document description and status attributes Document is made of
+var documentAttributes = []string{
+	"document-number",
+	"document-job-id",
+	"document-name",
+	"document-format",
+	"document-format-detected",
+	"document-state",
+	"document-state-reasons",
+	"document-state-message",
+	"impressions",
+	"impressions-completed",
+	"k-octets",
+}
+
+func newDocument(ag attributeGroup) Document {
+	a := ag.Map()
+	var d Document
+	d.Number = a["document-number"].Int()
+	d.JobId = a["document-job-id"].Int()
+	d.Name = a["document-name"].String()
+	d.Format = a["document-format"].String()
+	d.FormatDetected = a["document-format-detected"].String()
+	d.State = a["document-state"].Int()
+	d.StateReasons = a["document-state-reasons"].Strings()
+	d.StateMessage = a["document-state-message"].String()
+	d.Impressions = a["impressions"].Int()
+	d.ImpressionsCompleted = a["impressions-completed"].Int()
+	d.KOctets = a["k-octets"].Int()
+	d.Attributes = ag.attributes
+	return d
+}
+
+//	Returns the documents of the document-attributes groups of a response
+func (im *Message) Documents() []Document {
+	var docs []Document
+	for _, ag := range im.GroupsOf(TAG_DOCUMENT_ATTRIBUTES) {
+		docs = append(docs, newDocument(ag))
+	}
+	return docs
+}
+
+//	Get-Document-Attributes: returns a document of a job, requested names more attributes for
+//	Document.Attributes, e.g. "media-col" or "copies"
+func (c *CupsServer) GetDocumentAttributes(jobId, documentNumber int, requested ...string) (Document, error) {
+	m := c.newRequest(GET_DOCUMENT_ATTRIBUTES, "", jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	m.AppendAttribute(requestedAttributes(append(append([]string{}, documentAttributes...), requested...)))
+	r, err := c.doRequest(m, "/jobs/")
+	if err != nil {
+		return Document{}, err
+	}
+	docs := r.Documents()
+	if len(docs) == 0 {
+		return Document{}, &StatusError{Code: NOT_FOUND, Message: "no document-attributes in the response"}
+	}
+	return docs[0], nil
+}
+
+//	Get-Documents: returns the documents of a job ordered by document-number
+func (c *CupsServer) GetDocuments(jobId int, requested ...string) ([]Document, error) {
+	m := c.newRequest(GET_DOCUMENTS, "", jobId)
+	m.AppendAttribute(requestedAttributes(append(append([]string{}, documentAttributes...), requested...)))
+	r, err := c.doRequest(m, "/jobs/")
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	return r.Documents(), nil
+}
+
+//	Set-Document-Attributes: modifies the attributes of a document, attributes created with
+//	DeleteAttribute are removed. The names of attributes the printer returned as not-settable
+//	are returned along with the error.
+func (c *CupsServer) SetDocumentAttributes(jobId, documentNumber int, attrs ...attribute) ([]string, error) {
+	m := c.newRequest(SET_DOCUMENT_ATTRIBUTES, "", jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	m.AddGroup(TAG_DOCUMENT_ATTRIBUTES)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, a)
+	}
+	r, err := c.doRequest(m, "/jobs/")
+	return NotSettable(r), err
+}
+
+//	Cancel-Document: cancels a document of a job, the other documents are still printed
+func (c *CupsServer) CancelDocument(jobId, documentNumber int) error {
+	m := c.newRequest(CANCEL_DOCUMENT, "", jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	_, err := c.doRequest(m, "/jobs/")
+	return err
+}
+
+//	DocumentPrinter is implemented by Printers that support the Document object, NewServer
+//	registers the operations when available. Every request has been checked for the job
+//	target and, except for Get-Documents, for "document-number", see Request.DocumentNumber.
+type DocumentPrinter interface {
+	GetDocumentAttributes(r *Request, resp *Message) error
+	GetDocuments(r *Request, resp *Message) error
+	CancelDocument(r *Request, resp *Message) error
+}
+
+//	Operations whose target is a Document: the job target and "document-number"
+var documentTargetOperations = map[uint16]bool{
+	CANCEL_DOCUMENT:         true,
+	GET_DOCUMENT_ATTRIBUTES: true,
+	SET_DOCUMENT_ATTRIBUTES: true,
+	DELETE_DOCUMENT:         true,
+}
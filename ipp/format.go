@@ -0,0 +1,111 @@
+package ipp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"pwgraster"
+	"unicode/utf8"
+	"urf"
+)
+
+//   document-format detection
+//
+//   application/octet-stream asks the Printer to detect the format of a document ("auto-sense").
+//   The Printer reports the format it detected in the document-format-detected Job and
+//   Document attribute (PWG 5100.7 section 5.1). The format is recognized from the first bytes:
+//
+//      %PDF-                               application/pdf
+//      %! or ^D%!                          application/postscript
+//      RaS2 PwgRaster                      image/pwg-raster
+//      RaS2, RaS3                          application/vnd.cups-raster
+//      UNIRAST                             image/urf
+//      FF D8 FF                            image/jpeg
+//      89 'PNG' 0D 0A 1A 0A                image/png
+//      ESC E, ESC %-12345X (PJL)           application/vnd.hp-PCL, or the PJL ENTER LANGUAGE
+//      UTF-8 without control characters    text/plain
+
+//	The bytes of a document DetectFormat looks at
+const sniffLength = 512
+
+//	Returns the document-format of a document from its first bytes, application/octet-stream
+//	if it is not recognized and "" if there are none
+func DetectFormat(b []byte) string {
+	if len(b) > sniffLength {
+		b = b[:sniffLength]
+	}
+	switch {
+	case len(b) == 0:
+		return ""
+	case bytes.HasPrefix(b, []byte("%PDF-")):
+		return "application/pdf"
+	case bytes.HasPrefix(b, []byte("%!")), bytes.HasPrefix(b, []byte("\x04%!")):
+		return "application/postscript"
+	case bytes.HasPrefix(b, []byte(pwgraster.SyncWord+"PwgRaster")):
+		return "image/pwg-raster"
+	case bytes.HasPrefix(b, []byte("RaS2")), bytes.HasPrefix(b, []byte("RaS3")):
+		return "application/vnd.cups-raster"
+	case bytes.HasPrefix(b, []byte(urf.SyncWord)):
+		return "image/urf"
+	case bytes.HasPrefix(b, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(b, []byte("\x1b%-12345X")):
+		return pjlLanguage(b)
+	case bytes.HasPrefix(b, []byte("\x1bE")):
+		return "application/vnd.hp-PCL"
+	case isText(b):
+		return "text/plain"
+	}
+	return "application/octet-stream"
+}
+
+//	Returns the format a PJL job switches to with "@PJL ENTER LANGUAGE=", PCL if it does not
+func pjlLanguage(b []byte) string {
+	upper := bytes.ToUpper(b)
+	i := bytes.Index(upper, []byte("@PJL ENTER LANGUAGE"))
+	if i < 0 {
+		return "application/vnd.hp-PCL"
+	}
+	lang := bytes.TrimLeft(upper[i+len("@PJL ENTER LANGUAGE"):], " =")
+	switch {
+	case bytes.HasPrefix(lang, []byte("POSTSCRIPT")):
+		return "application/postscript"
+	case bytes.HasPrefix(lang, []byte("PDF")):
+		return "application/pdf"
+	case bytes.HasPrefix(lang, []byte("PCLXL")):
+		return "application/vnd.hp-PCLXL"
+	}
+	return "application/vnd.hp-PCL"
+}
+
+//	Returns true for UTF-8 without control characters other than tab, line feed, form feed and
+//	carriage return, a rune cut off at the end is allowed
+func isText(b []byte) bool {
+	for len(b) > 0 {
+		r, n := utf8.DecodeRune(b)
+		if r == utf8.RuneError && n <= 1 {
+			return !utf8.FullRune(b)
+		}
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\f' && r != '\r' || r == 0x7f {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
+
+//	Returns the document-format of the document r and a reader of the whole document, r is
+//	read from only as far as the returned reader is
+func SniffFormat(r io.Reader) (string, io.Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok || br.Size() < sniffLength {
+		br = bufio.NewReaderSize(r, sniffLength)
+	}
+	b, err := br.Peek(sniffLength)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", br, err
+	}
+	return DetectFormat(b), br, nil
+}
@@ -0,0 +1,345 @@
+package ipp
+
+import (
+	"sync/atomic"
+)
+
+//   PWG 5100.18 IPP Shared Infrastructure Extensions (INFRA)
+//
+//   An Output Device that can not be reached by clients (e.g. a printer behind NAT) registers
+//   with an Infrastructure Printer and pulls its jobs from there. The Proxy, the software on or
+//   next to the Output Device, is the client of these operations:
+//
+//      Register-Output-Device (0x005F)      registers the output-device-uuid with an Infrastructure
+//                                           System and returns the printer-uri to use (PWG 5100.22)
+//      Update-Output-Device-Attributes      reports the printer attributes of the Output Device
+//      (0x0049)
+//      Get-Jobs with "which-jobs"           lists the jobs that are waiting for the Output Device
+//      'fetchable'
+//      Fetch-Job (0x0043)                   returns the attributes of a job
+//      Acknowledge-Job (0x0041)             accepts (or refuses, with a fetch-status-code) a job
+//      Fetch-Document (0x0042)              returns the attributes and data of a document
+//      Acknowledge-Document (0x003F)        accepts (or refuses) a document
+//      Update-Job-Status (0x0048)           reports the job-state of a job on the Output Device
+//      Update-Document-Status (0x0047)      reports the document-state of a document
+//      Update-Active-Jobs (0x0045)          reports the states of all active jobs, the response
+//                                           lists the jobs whose state differs on the Infrastructure
+//                                           Printer, e.g. jobs canceled by their user
+//      Deregister-Output-Device (0x0046)    removes the Output Device from the Infrastructure Printer
+//
+//   Every request carries "output-device-uuid" (uri), the urn:uuid: of the Output Device,
+//   after "requesting-user-name".
+
+//	InfraClient is the Proxy side of INFRA, it talks to an Infrastructure Printer on behalf of
+//	the Output Device output-device-uuid
+type InfraClient struct {
+	printerUri     string
+	deviceUuid     string
+	username       string
+	password       string
+	requestCounter int32
+}
+
+//	Returns an InfraClient for the Infrastructure Printer printerUri, deviceUuid is the
+//	output-device-uuid, e.g. "urn:uuid:4509a320-00a0-008f-00b6-002507510eca".
+//	printerUri may be empty if the device still has to register, see RegisterOutputDevice.
+func NewInfraClient(printerUri, deviceUuid string) *InfraClient {
+	return &InfraClient{printerUri: printerUri, deviceUuid: deviceUuid}
+}
+
+//	Sets the requesting-user-name and the credentials used for HTTP Basic authentication
+func (c *InfraClient) SetUser(username, password string) {
+	c.username = username
+	c.password = password
+}
+
+//	Returns the printer-uri of the Infrastructure Printer
+func (c *InfraClient) PrinterUri() string {
+	return c.printerUri
+}
+
+//	OutputStatus is the state an Output Device reports with Update-Job-Status and Update-Document-Status
+type OutputStatus struct {
+	State                int      // job-state or document-state
+	Reasons              []string // job-state-reasons or document-state-reasons
+	Message              string   // job-state-message or document-state-message
+	ImpressionsCompleted int      // job-impressions-completed or impressions-completed
+	SheetsCompleted      int      // job-media-sheets-completed or media-sheets-completed
+}
+
+//	Register-Output-Device: registers the device with the Infrastructure System systemUri and
+//	returns the printer-uri of the Infrastructure Printer it is assigned, which is used from then on
+func (c *InfraClient) RegisterOutputDevice(systemUri string) (string, error) {
+	m := newMessage(REGISTER_OUTPUT_DEVICE)
+	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
+	m.AddAttribute(TAG_URI, "system-uri", uri(systemUri))
+	m.AddAttribute(TAG_NAME, "requesting-user-name", nameWithoutLanguage(c.requestingUser()))
+	m.AddAttribute(TAG_URI, "output-device-uuid", uri(c.deviceUuid))
+	r, err := c.do(m, systemUri)
+	if err != nil {
+		return "", err
+	}
+	a, ok := r.Attribute(TAG_PRINTER, "printer-uri-supported")
+	if !ok {
+		return "", &StatusError{Code: INTERNAL_ERROR, Message: "Register-Output-Device response has no printer-uri-supported"}
+	}
+	c.printerUri = a.String()
+	return c.printerUri, nil
+}
+
+//	Deregister-Output-Device: removes the device from the Infrastructure Printer
+func (c *InfraClient) DeregisterOutputDevice() error {
+	_, err := c.do(c.newRequest(DEREGISTER_OUTPUT_DEVICE, 0), c.printerUri)
+	return err
+}
+
+//	Update-Output-Device-Attributes: reports printer attributes of the device, e.g. printer-state,
+//	media-ready or document-format-supported. Attributes with the value-tag TAG_DELETEATTR remove
+//	the attribute, see DeleteAttribute.
+func (c *InfraClient) UpdateOutputDeviceAttributes(attrs ...attribute) error {
+	m := c.newRequest(UPDATE_OUTPUT_DEVICE_ATTRIBUTES, 0)
+	m.AddGroup(TAG_PRINTER)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_PRINTER, a)
+	}
+	_, err := c.do(m, c.printerUri)
+	return err
+}
+
+//	Get-Jobs with which-jobs 'fetchable': returns the job-ids waiting for the device
+func (c *InfraClient) FetchableJobs() ([]int, error) {
+	m := c.newRequest(GET_JOBS, 0)
+	m.AddAttribute(TAG_KEYWORD, "which-jobs", keyword("fetchable"))
+	m.AppendAttribute(requestedAttributes([]string{"job-id"}))
+	r, err := c.do(m, c.printerUri)
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	var ids []int
+	for _, ag := range r.GroupsOf(TAG_JOB) {
+		if a, ok := ag.Attribute("job-id"); ok {
+			ids = append(ids, a.Int())
+		}
+	}
+	return ids, nil
+}
+
+//	Fetch-Job: returns the attributes of a fetchable job, Attributes holds the whole job group
+func (c *InfraClient) FetchJob(jobId int) (Job, error) {
+	r, err := c.do(c.newRequest(FETCH_JOB, jobId), c.printerUri)
+	if err != nil {
+		return Job{}, err
+	}
+	ag, _ := r.Group(TAG_JOB)
+	a := ag.Map()
+	j := Job{
+		Id:           jobId,
+		Uri:          a["job-uri"].String(),
+		Name:         a["job-name"].String(),
+		User:         a["job-originating-user-name"].String(),
+		State:        a["job-state"].Int(),
+		StateReasons: a["job-state-reasons"].Strings(),
+		Attributes:   ag.attributes,
+	}
+	return j, nil
+}
+
+//	Acknowledge-Job: fetchStatus OK accepts the job, any other status-code (e.g. DOCUMENT_FORMAT)
+//	tells the Infrastructure Printer why the device can not print it
+func (c *InfraClient) AcknowledgeJob(jobId int, fetchStatus uint16, message string) error {
+	m := c.newRequest(ACKNOWLEDGE_JOB, jobId)
+	addFetchStatus(&m, fetchStatus, message)
+	_, err := c.do(m, c.printerUri)
+	return err
+}
+
+//	Fetch-Document: returns a document of a job in one of the formats the device accepts,
+//	nil formats accept whatever the Infrastructure Printer has
+func (c *InfraClient) FetchDocument(jobId, documentNumber int, formats []string) (JobDocument, error) {
+	m := c.newRequest(FETCH_DOCUMENT, jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	m.AddAttribute(TAG_KEYWORD, "compression-accepted", keyword("none"))
+	if len(formats) > 0 {
+		a := NewAttribute()
+		for _, f := range formats {
+			a.AddValue(TAG_MIMETYPE, "document-format-accepted", mimeMediaType(f))
+		}
+		m.AppendAttribute(a)
+	}
+	r, err := c.do(m, c.printerUri)
+	if err != nil {
+		return JobDocument{}, err
+	}
+	d := JobDocument{Number: documentNumber, Data: r.Data}
+	for _, group := range []byte{TAG_OPERATION, TAG_DOCUMENT_ATTRIBUTES} {
+		if a, ok := r.Attribute(group, "document-format"); ok {
+			d.Format = a.String()
+		}
+		if a, ok := r.Attribute(group, "document-name"); ok {
+			d.Name = a.String()
+		}
+	}
+	return d, nil
+}
+
+//	Acknowledge-Document: fetchStatus OK accepts the document, see AcknowledgeJob
+func (c *InfraClient) AcknowledgeDocument(jobId, documentNumber int, fetchStatus uint16, message string) error {
+	m := c.newRequest(ACKNOWLEDGE_DOCUMENT, jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	addFetchStatus(&m, fetchStatus, message)
+	_, err := c.do(m, c.printerUri)
+	return err
+}
+
+//	Update-Job-Status: reports the state of a job on the device
+func (c *InfraClient) UpdateJobStatus(jobId int, s OutputStatus) error {
+	m := c.newRequest(UPDATE_JOB_STATUS, jobId)
+	m.AddGroup(TAG_JOB)
+	m.AddGroupAttribute(TAG_JOB, TAG_ENUM, "output-device-job-state", enum(s.State))
+	m.AppendGroupAttribute(TAG_JOB, keywords("output-device-job-state-reasons", s.Reasons))
+	if s.Message != "" {
+		m.AddGroupAttribute(TAG_JOB, TAG_TEXT, "output-device-job-state-message", textWithoutLanguage(s.Message))
+	}
+	m.AddGroupAttribute(TAG_JOB, TAG_INTEGER, "job-impressions-completed", integer(s.ImpressionsCompleted))
+	m.AddGroupAttribute(TAG_JOB, TAG_INTEGER, "job-media-sheets-completed", integer(s.SheetsCompleted))
+	_, err := c.do(m, c.printerUri)
+	return err
+}
+
+//	Update-Document-Status: reports the state of a document on the device
+func (c *InfraClient) UpdateDocumentStatus(jobId, documentNumber int, s OutputStatus) error {
+	m := c.newRequest(UPDATE_DOCUMENT_STATUS, jobId)
+	m.AddAttribute(TAG_INTEGER, "document-number", integer(documentNumber))
+	m.AddGroup(TAG_DOCUMENT_ATTRIBUTES)
+	m.AddGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, TAG_ENUM, "output-device-document-state", enum(s.State))
+	m.AppendGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, keywords("output-device-document-state-reasons", s.Reasons))
+	if s.Message != "" {
+		m.AddGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, TAG_TEXT, "output-device-document-state-message", textWithoutLanguage(s.Message))
+	}
+	m.AddGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, TAG_INTEGER, "impressions-completed", integer(s.ImpressionsCompleted))
+	m.AddGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, TAG_INTEGER, "media-sheets-completed", integer(s.SheetsCompleted))
+	_, err := c.do(m, c.printerUri)
+	return err
+}
+
+//	Update-Active-Jobs: reports the job-state of every job active on the device (job-id to state)
+//	and returns the jobs whose state differs on the Infrastructure Printer, e.g. a job its user
+//	canceled in the meantime is returned as JOB_CANCELLED
+func (c *InfraClient) UpdateActiveJobs(states map[int]int) (map[int]int, error) {
+	m := c.newRequest(UPDATE_ACTIVE_JOBS, 0)
+	ids, jobStates := NewAttribute(), NewAttribute()
+	for id, state := range states {
+		ids.AddValue(TAG_INTEGER, "job-ids", integer(id))
+		jobStates.AddValue(TAG_ENUM, "output-device-job-states", enum(state))
+	}
+	if len(states) > 0 {
+		m.AppendAttribute(ids)
+		m.AppendAttribute(jobStates)
+	}
+	r, err := c.do(m, c.printerUri)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[int]int)
+	a, _ := r.Attribute(TAG_OPERATION, "job-ids")
+	s, _ := r.Attribute(TAG_OPERATION, "output-device-job-states")
+	rids, rstates := a.Ints(), s.Ints()
+	for i := 0; i < len(rids) && i < len(rstates); i++ {
+		changed[rids[i]] = rstates[i]
+	}
+	return changed, nil
+}
+
+//	Creates a request to the Infrastructure Printer: attributes-charset, attributes-natural-language,
+//	printer-uri, job-id (if not 0), requesting-user-name and output-device-uuid
+func (c *InfraClient) newRequest(operationId uint16, jobId int) Message {
+	m := newMessage(operationId)
+	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
+	m.AddAttribute(TAG_URI, "printer-uri", uri(c.printerUri))
+	if jobId != 0 {
+		m.AddAttribute(TAG_INTEGER, "job-id", integer(jobId))
+	}
+	m.AddAttribute(TAG_NAME, "requesting-user-name", nameWithoutLanguage(c.requestingUser()))
+	m.AddAttribute(TAG_URI, "output-device-uuid", uri(c.deviceUuid))
+	return m
+}
+
+func (c *InfraClient) requestingUser() string {
+	return requestingUser(c.username)
+}
+
+func (c *InfraClient) do(m Message, target string) (Message, error) {
+	m.requestId = atomic.AddInt32(&c.requestCounter, 1)
+	return postMessage(httpUrl(target), m, c.username, c.password)
+}
+
+//	Adds fetch-status-code and fetch-status-message for status-codes other than successful-ok
+func addFetchStatus(m *Message, code uint16, message string) {
+	if code == OK {
+		return
+	}
+	m.AddAttribute(TAG_ENUM, "fetch-status-code", enum(code))
+	if message != "" {
+		m.AddAttribute(TAG_TEXT, "fetch-status-message", textWithoutLanguage(message))
+	}
+}
+
+// ========== Infrastructure Printer ==========
+
+//	InfraPrinter is implemented by Printers that act as an INFRA Infrastructure Printer,
+//	NewServer registers the operations when available. Every request has been checked for
+//	"output-device-uuid", see Request.OutputDeviceUuid. Fetch-Document returns the document
+//	data in resp.Data.
+type InfraPrinter interface {
+	RegisterOutputDevice(r *Request, resp *Message) error
+	DeregisterOutputDevice(r *Request, resp *Message) error
+	UpdateOutputDeviceAttributes(r *Request, resp *Message) error
+	FetchJob(r *Request, resp *Message) error
+	AcknowledgeJob(r *Request, resp *Message) error
+	FetchDocument(r *Request, resp *Message) error
+	AcknowledgeDocument(r *Request, resp *Message) error
+	UpdateJobStatus(r *Request, resp *Message) error
+	UpdateDocumentStatus(r *Request, resp *Message) error
+	UpdateActiveJobs(r *Request, resp *Message) error
+}
+
+//	The INFRA operations, they all require "output-device-uuid"
+var infraOperations = map[uint16]bool{
+	REGISTER_OUTPUT_DEVICE:          true,
+	DEREGISTER_OUTPUT_DEVICE:        true,
+	UPDATE_OUTPUT_DEVICE_ATTRIBUTES: true,
+	FETCH_JOB:                       true,
+	ACKNOWLEDGE_JOB:                 true,
+	FETCH_DOCUMENT:                  true,
+	ACKNOWLEDGE_DOCUMENT:            true,
+	UPDATE_JOB_STATUS:               true,
+	UPDATE_DOCUMENT_STATUS:          true,
+	UPDATE_ACTIVE_JOBS:              true,
+}
+
+//	Returns the output-device-uuid of an INFRA request
+func (r *Request) OutputDeviceUuid() string {
+	a, _ := r.Operation("output-device-uuid")
+	return a.String()
+}
+
+//	Returns the document-number of a request, 0 if it has none
+func (r *Request) DocumentNumber() int {
+	a, _ := r.Operation("document-number")
+	return a.Int()
+}
+
+func (s *Server) handleInfra(p InfraPrinter) {
+	s.Handle(REGISTER_OUTPUT_DEVICE, p.RegisterOutputDevice)
+	s.Handle(DEREGISTER_OUTPUT_DEVICE, p.DeregisterOutputDevice)
+	s.Handle(UPDATE_OUTPUT_DEVICE_ATTRIBUTES, p.UpdateOutputDeviceAttributes)
+	s.Handle(FETCH_JOB, p.FetchJob)
+	s.Handle(ACKNOWLEDGE_JOB, p.AcknowledgeJob)
+	s.Handle(FETCH_DOCUMENT, p.FetchDocument)
+	s.Handle(ACKNOWLEDGE_DOCUMENT, p.AcknowledgeDocument)
+	s.Handle(UPDATE_JOB_STATUS, p.UpdateJobStatus)
+	s.Handle(UPDATE_DOCUMENT_STATUS, p.UpdateDocumentStatus)
+	s.Handle(UPDATE_ACTIVE_JOBS, p.UpdateActiveJobs)
+}
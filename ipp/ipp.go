@@ -1,7 +1,13 @@
 package ipp
 
 import (
-
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
 )
 //   Every operation request contains the following REQUIRED parameters:
 //
@@ -24,4 +30,153 @@ func NewRequest(idStatusCode uint16) Message {
 
 func NewResponse(idStatusCode uint16) Message {
 	return newMessage(idStatusCode)
-}
\ No newline at end of file
+}
+
+// ========== status codes ==========
+
+//	StatusError is returned when a response carries a status-code other than successful-ok*.
+//	Message holds the "status-message" operation attribute if the printer sent one.
+type StatusError struct {
+	Code    uint16
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ipp: %s (0x%04x)", StatusCodeString(e.Code), e.Code)
+	}
+	return fmt.Sprintf("ipp: %s (0x%04x): %s", StatusCodeString(e.Code), e.Code, e.Message)
+}
+
+//	Returns true for the successful-ok* status codes 0x0000 to 0x00FF
+func IsSuccessful(code uint16) bool {
+	return code <= 0x00ff
+}
+
+var statusCodeStrings = map[uint16]string{
+	OK:                           "successful-ok",
+	OK_SUBST:                     "successful-ok-ignored-or-substituted-attributes",
+	OK_CONFLICT:                  "successful-ok-conflicting-attributes",
+	OK_IGNORED_SUBSCRIPTIONS:     "successful-ok-ignored-subscriptions",
+	OK_IGNORED_NOTIFICATIONS:     "successful-ok-ignored-notifications",
+	OK_TOO_MANY_EVENTS:           "successful-ok-too-many-events",
+	OK_BUT_CANCEL_SUBSCRIPTION:   "successful-ok-but-cancel-subscription",
+	REDIRECTION_OTHER_SITE:       "redirection-other-site",
+	BAD_REQUEST:                  "client-error-bad-request",
+	FORBIDDEN:                    "client-error-forbidden",
+	NOT_AUTHENTICATED:            "client-error-not-authenticated",
+	NOT_AUTHORIZED:               "client-error-not-authorized",
+	NOT_POSSIBLE:                 "client-error-not-possible",
+	TIMEOUT:                      "client-error-timeout",
+	NOT_FOUND:                    "client-error-not-found",
+	GONE:                         "client-error-gone",
+	REQUEST_ENTITY:               "client-error-request-entity-too-large",
+	REQUEST_VALUE:                "client-error-request-value-too-long",
+	DOCUMENT_FORMAT:              "client-error-document-format-not-supported",
+	ATTRIBUTES:                   "client-error-attributes-or-values-not-supported",
+	URI_SCHEME:                   "client-error-uri-scheme-not-supported",
+	CHARSET:                      "client-error-charset-not-supported",
+	CONFLICT:                     "client-error-conflicting-attributes",
+	COMPRESSION_NOT_SUPPORTED:    "client-error-compression-not-supported",
+	COMPRESSION_ERROR:            "client-error-compression-error",
+	DOCUMENT_FORMAT_ERROR:        "client-error-document-format-error",
+	DOCUMENT_ACCESS_ERROR:        "client-error-document-access-error",
+	ATTRIBUTES_NOT_SETTABLE:      "client-error-attributes-not-settable",
+	IGNORED_ALL_SUBSCRIPTIONS:    "client-error-ignored-all-subscriptions",
+	TOO_MANY_SUBSCRIPTIONS:       "client-error-too-many-subscriptions",
+	IGNORED_ALL_NOTIFICATIONS:    "client-error-ignored-all-notifications",
+	PRINT_SUPPORT_FILE_NOT_FOUND: "client-error-print-support-file-not-found",
+	INTERNAL_ERROR:               "server-error-internal-error",
+	OPERATION_NOT_SUPPORTED:      "server-error-operation-not-supported",
+	SERVICE_UNAVAILABLE:          "server-error-service-unavailable",
+	VERSION_NOT_SUPPORTED:        "server-error-version-not-supported",
+	DEVICE_ERROR:                 "server-error-device-error",
+	TEMPORARY_ERROR:              "server-error-temporary-error",
+	NOT_ACCEPTING:                "server-error-not-accepting-jobs",
+	PRINTER_BUSY:                 "server-error-busy",
+	ERROR_JOB_CANCELLED:          "server-error-job-canceled",
+	MULTIPLE_JOBS_NOT_SUPPORTED:  "server-error-multiple-document-jobs-not-supported",
+	PRINTER_IS_DEACTIVATED:       "server-error-printer-is-deactivated",
+}
+
+//	Returns the keyword of a status-code, e.g. "client-error-not-found" for 0x0406
+func StatusCodeString(code uint16) string {
+	if s, ok := statusCodeStrings[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%04x", code)
+}
+
+// ========== transport ==========
+
+//	Posts the request to url as application/ipp and parses the response. The document of a
+//	request is streamed after the message, chunked as its length is not known.
+//	A response status other than successful-ok* is returned as a *StatusError along with the
+//	response so the caller can still inspect e.g. the unsupported-attributes group.
+func postMessage(url string, m Message, username, password string) (Message, error) {
+	b, err := m.marshallMsg()
+	if err != nil {
+		return Message{}, err
+	}
+	var msg io.Reader = bytes.NewReader(b)
+	if m.document != nil {
+		msg = io.MultiReader(msg, m.document)
+	}
+	req, err := http.NewRequest("POST", url, msg)
+	if err != nil {
+		return Message{}, err
+	}
+	req.Header.Set("Content-Type", "application/ipp")
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Message{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Message{}, fmt.Errorf("ipp: http %s from %s", resp.Status, url)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	r, err := ParseMessage(body)
+	if err != nil {
+		return r, err
+	}
+	if r.requestId != m.requestId {
+		return r, fmt.Errorf("ipp: response request-id %d does not match request %d", r.requestId, m.requestId)
+	}
+	return r, statusError(r)
+}
+
+//	Returns the http(s) URL of an ipp or ipps uri, port 631 unless the uri has one
+func httpUrl(printerUri string) string {
+	u, err := url.Parse(printerUri)
+	if err != nil {
+		return printerUri
+	}
+	switch u.Scheme {
+	case "ipp":
+		u.Scheme = "http"
+	case "ipps":
+		u.Scheme = "https"
+	default:
+		return printerUri
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), "631")
+	}
+	return u.String()
+}
+
+//	Returns a *StatusError for responses that are not successful-ok*
+func statusError(r Message) error {
+	if IsSuccessful(r.StatusCode()) {
+		return nil
+	}
+	msg, _ := r.Attribute(TAG_OPERATION, "status-message")
+	return &StatusError{Code: r.StatusCode(), Message: msg.String()}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"strconv"
+	"time"
 )
 
 //   -----------------------------------------------
@@ -49,6 +50,7 @@ type Message struct {
 	endAttributeTag       	byte
 	Data                  	[]byte
 	IsResponse				bool
+	document				io.Reader	// streamed after Data by postMessage, see PrintJob
 }
 
 func NewMessage(idStatusCode uint16) Message {
@@ -113,30 +115,159 @@ type attributeGroup struct {
 	attributes             []attribute
 }
 
-func newAg(a attribute) attributeGroup {
+func newAg(group byte) attributeGroup {
 	var x attributeGroup
-	x.beginAttributeGroupTag = uint8(TAG_OPERATION)
-	x.attributes = append(x.attributes, a)
+	x.beginAttributeGroupTag = group
 	return x
 }
 
+//	Adds an attribute to the operation attributes group
 func (im *Message) AddAttribute(tag byte, name string, value interface{}) {
-	im.addAttribute(tag, name, value)
+	im.addAttribute(TAG_OPERATION, tag, name, value)
 	return
 }
 
+//	Appends a (possibly multi-valued) attribute to the operation attributes group
 func (im *Message) AppendAttribute(attrib attribute) {
-	im.attributeGroups = append(im.attributeGroups, newAg(attrib))
+	im.appendAttribute(TAG_OPERATION, attrib)
 	return
 }
 
-func (im *Message) addAttribute(tag byte, name string, value interface{}) {
+//	Adds an attribute to the last group if it is a "group" group, otherwise a new group is started.
+//	e.g. AddGroupAttribute(TAG_JOB, TAG_INTEGER, "copies", integer(2))
+func (im *Message) AddGroupAttribute(group, tag byte, name string, value interface{}) {
+	im.addAttribute(group, tag, name, value)
+	return
+}
+
+//	Appends a (possibly multi-valued) attribute to the last group if it is a "group" group,
+//	otherwise a new group is started.
+func (im *Message) AppendGroupAttribute(group byte, attrib attribute) {
+	im.appendAttribute(group, attrib)
+	return
+}
+
+//	Starts a new attribute group, even if the last group has the same tag.
+//	Get-Jobs responses for example carry one job-attributes group per job.
+func (im *Message) AddGroup(group byte) {
+	im.attributeGroups = append(im.attributeGroups, newAg(group))
+	return
+}
+
+func (im *Message) addAttribute(group, tag byte, name string, value interface{}) {
 	var attrib attribute
 	attrib.addValue(tag, name, value)
-	im.attributeGroups = append(im.attributeGroups, newAg(attrib))
+	im.appendAttribute(group, attrib)
+	return
+}
+
+func (im *Message) appendAttribute(group byte, attrib attribute) {
+	n := len(im.attributeGroups)
+	if n == 0 || im.attributeGroups[n-1].beginAttributeGroupTag != group {
+		im.AddGroup(group)
+		n++
+	}
+	im.attributeGroups[n-1].attributes = append(im.attributeGroups[n-1].attributes, attrib)
+	return
+}
+
+//	Returns the operation-id of a request
+func (im *Message) OperationId() uint16 {
+	return im.operationIdStatusCode
+}
+
+//	Returns the status-code of a response
+func (im *Message) StatusCode() uint16 {
+	return im.operationIdStatusCode
+}
+
+//	Sets the operation-id of a request or the status-code of a response
+func (im *Message) SetStatusCode(code uint16) {
+	im.operationIdStatusCode = code
 	return
 }
 
+func (im *Message) RequestId() int32 {
+	return im.requestId
+}
+
+func (im *Message) SetRequestId(id int32) {
+	im.requestId = id
+	return
+}
+
+//	Returns the version-number as major, minor
+func (im *Message) Version() (int8, int8) {
+	return im.majorVer, im.minorVer
+}
+
+//	Sets the version-number, e.g. SetVersion(1, 1) for a request to an IPP/1.1 printer
+func (im *Message) SetVersion(major, minor int8) {
+	im.majorVer, im.minorVer = major, minor
+}
+
+//	Returns every attribute group in the order they were encoded
+func (im *Message) Groups() []attributeGroup {
+	return im.attributeGroups
+}
+
+//	Returns all groups with the begin-attribute-group-tag "group", e.g. every job in a Get-Jobs response
+func (im *Message) GroupsOf(group byte) []attributeGroup {
+	var ags []attributeGroup
+	for _, ag := range im.attributeGroups {
+		if ag.beginAttributeGroupTag == group {
+			ags = append(ags, ag)
+		}
+	}
+	return ags
+}
+
+//	Returns the first group with the begin-attribute-group-tag "group"
+func (im *Message) Group(group byte) (attributeGroup, bool) {
+	for _, ag := range im.attributeGroups {
+		if ag.beginAttributeGroupTag == group {
+			return ag, true
+		}
+	}
+	return attributeGroup{}, false
+}
+
+//	Looks up an attribute by name in the first group with the begin-attribute-group-tag "group"
+func (im *Message) Attribute(group byte, name string) (attribute, bool) {
+	ag, ok := im.Group(group)
+	if !ok {
+		return attribute{}, false
+	}
+	return ag.Attribute(name)
+}
+
+func (ag *attributeGroup) Tag() byte {
+	return ag.beginAttributeGroupTag
+}
+
+func (ag *attributeGroup) Attributes() []attribute {
+	return ag.attributes
+}
+
+//	Looks up an attribute by name
+func (ag *attributeGroup) Attribute(name string) (attribute, bool) {
+	for _, a := range ag.attributes {
+		if a.Name() == name {
+			return a, true
+		}
+	}
+	return attribute{}, false
+}
+
+//	Returns the attributes of the group keyed by name
+func (ag *attributeGroup) Map() map[string]attribute {
+	m := make(map[string]attribute, len(ag.attributes))
+	for _, a := range ag.attributes {
+		m[a.Name()] = a
+	}
+	return m
+}
+
 //   Each "attribute-with-one-value" field is encoded as follows:
 //
 //   -----------------------------------------------
@@ -184,6 +315,103 @@ func NewAttribute() attribute {
 	return a
 }
 
+//	Returns the name of the attribute which is carried by its first value
+func (i attribute) Name() string {
+	if len(i.values) == 0 {
+		return ""
+	}
+	return i.values[0].name
+}
+
+//	Returns the value-tag of the first value
+func (i attribute) Tag() byte {
+	if len(i.values) == 0 {
+		return TAG_ZERO
+	}
+	return i.values[0].valueTag
+}
+
+//	Returns the value-tag of every value, an attribute may mix e.g. integer and no-value
+func (i attribute) Tags() []byte {
+	var t []byte
+	for _, v := range i.values {
+		t = append(t, v.valueTag)
+	}
+	return t
+}
+
+//	Returns every value as a string
+func (i attribute) Strings() []string {
+	var s []string
+	for _, v := range i.values {
+		s = append(s, v.str())
+	}
+	return s
+}
+
+//	Returns the first value as a string
+func (i attribute) String() string {
+	if len(i.values) == 0 {
+		return ""
+	}
+	return i.values[0].str()
+}
+
+//	Returns every integer or enum value
+func (i attribute) Ints() []int {
+	var n []int
+	for _, v := range i.values {
+		switch x := v.value.(type) {
+		case integer:
+			n = append(n, int(x))
+		case enum:
+			n = append(n, int(x))
+		}
+	}
+	return n
+}
+
+//	Returns the first integer or enum value
+func (i attribute) Int() int {
+	n := i.Ints()
+	if len(n) == 0 {
+		return 0
+	}
+	return n[0]
+}
+
+//	Returns the first boolean value
+func (i attribute) Bool() bool {
+	if len(i.values) == 0 {
+		return false
+	}
+	b, _ := i.values[0].value.(ippBoolean)
+	return b == ippTrue
+}
+
+//	Returns the first dateTime value
+func (i attribute) Time() time.Time {
+	if len(i.values) == 0 {
+		return time.Time{}
+	}
+	d, ok := i.values[0].value.(dateTime)
+	if !ok {
+		return time.Time{}
+	}
+	return d.Time()
+}
+
+//	Returns every collection value, each with its members
+func (i attribute) Collections() []collection {
+	var c []collection
+	for _, v := range i.values {
+		if x, ok := v.value.(collection); ok {
+			c = append(c, x)
+		}
+	}
+	return c
+}
+
 func (i *attribute) AddValue(tag byte, name string, value interface{}) {
 	i.addValue(tag, name, value)
 	return
@@ -193,6 +421,13 @@ func (a *attributeValue) Str() (s string) {
 	return
 }
 
+func (a *attributeValue) str() string {
+	if a.String == nil {
+		return ""
+	}
+	return a.String()
+}
+
 func (i *attribute) appendValue(av attributeValue) {
 	i.values = append(i.values, av)
 	return
@@ -265,3 +500,21 @@ func UUID4() (int32, error) {
 	uid, err := strconv.Atoi(str)
 	return int32(uid), err
 }
+
+//	Returns the "requested-attributes" operation attribute listing names
+func requestedAttributes(names []string) attribute {
+	a := NewAttribute()
+	for _, n := range names {
+		a.AddValue(TAG_KEYWORD, "requested-attributes", keyword(n))
+	}
+	return a
+}
+
+//	Returns an attribute with one value per element of values, all with the value-tag "tag"
+func newAttribute(tag byte, name string, values ...interface{}) attribute {
+	a := NewAttribute()
+	for _, v := range values {
+		a.AddValue(tag, name, v)
+	}
+	return a
+}
@@ -3,7 +3,11 @@ package ipp
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -72,6 +76,8 @@ const (
 	ippFalse = 0x00
 )
 
+var errShortValue = errors.New("ipp: value shorter than its syntax requires")
+
 // ========== Marshler interface ==========
 
 // Marshaler is the interface implemented by objects that
@@ -84,6 +90,11 @@ type Marshler interface {
 // ========== textWithoutLanguage ==========
 type textWithoutLanguage []byte
 
+//	Takes a string and returns textWithoutLanguage (TAG_TEXT)
+func Text(s string) textWithoutLanguage {
+	return textWithoutLanguage(s)
+}
+
 func (i *textWithoutLanguage) bytes() []byte {
 	return []byte(*i)
 }
@@ -92,10 +103,23 @@ func (i *textWithoutLanguage) len() int {
 	return len(i.bytes())
 }
 
+func (i *textWithoutLanguage) MarshalIPP() ([]byte, error) {
+	return i.bytes(), nil
+}
+
+func (i *textWithoutLanguage) String() string {
+	return string(*i)
+}
+
 // ========== nameWithoutLanguage ==========
 
 type nameWithoutLanguage []byte
 
+//	Takes a string and returns nameWithoutLanguage (TAG_NAME)
+func Name(s string) nameWithoutLanguage {
+	return nameWithoutLanguage(s)
+}
+
 func (i *nameWithoutLanguage) bytes() []byte {
 	return []byte(*i)
 }
@@ -104,13 +128,21 @@ func (i *nameWithoutLanguage) len() int {
 	return len(i.bytes())
 }
 
+func (i *nameWithoutLanguage) MarshalIPP() ([]byte, error) {
+	return i.bytes(), nil
+}
+
+func (i *nameWithoutLanguage) String() string {
+	return string(*i)
+}
+
 // ========== signedShort ==========
 
 type signedShort int16
 
 func (i *signedShort) bytes() []byte {
 	buf := new(bytes.Buffer)
-	_ = binary.Write(buf, binary.LittleEndian, i)
+	_ = binary.Write(buf, binary.BigEndian, i)
 	return buf.Bytes()
 }
 
@@ -123,14 +155,16 @@ func (s *signedShort) len() int {
 type signedByte int8
 
 func (i *signedByte) UnMarshall(b []byte) error {
-	x, _ := binary.Varint(b)
-	*i = signedByte(int8(x))
+	if len(b) < 1 {
+		return errShortValue
+	}
+	*i = signedByte(int8(b[0]))
 	return nil
 }
 
 func (i *signedByte) bytes() []byte {
 	buf := new(bytes.Buffer)
-	_ = binary.Write(buf, binary.LittleEndian, i)
+	_ = binary.Write(buf, binary.BigEndian, i)
 	return buf.Bytes()
 }
 
@@ -201,6 +235,11 @@ func (i *naturalLanguage) UnMarshalIPP(b []byte) (error) {
 
 type mimeMediaType []byte // US-ASCII-STRING.
 
+//	Takes a string and returns mimeMediaType (TAG_MIMETYPE)
+func MimeMediaType(s string) mimeMediaType {
+	return mimeMediaType(s)
+}
+
 func (i *mimeMediaType) bytes() []byte { // US-ASCII-STRING.
 	return []byte(*i)
 }
@@ -228,6 +267,11 @@ func (i *mimeMediaType) String() string {
 
 type keyword []byte // US-ASCII-STRING.
 
+//	Takes a string and returns keyword (TAG_KEYWORD)
+func Keyword(s string) keyword {
+	return keyword(s)
+}
+
 func (i *keyword) bytes() []byte { // US-ASCII-STRING.
 	return []byte(*i)
  }
@@ -254,6 +298,11 @@ func (i *keyword) String() string {
 
 type uri []byte // US-ASCII-STRING.
 
+//	Takes a string and returns uri (TAG_URI)
+func Uri(s string) uri {
+	return uri(s)
+}
+
 func (i *uri) bytes() []byte { // US-ASCII-STRING.
 	return []byte(*i)
 }
@@ -273,6 +322,10 @@ func (i *uri) UnMarshalIPP(b []byte) (error) {
 	return nil
 }
 
+func (i *uri) String() string {
+	return string(*i)
+}
+
 // ========== uriScheme ==========
 
 type uriScheme []byte // US-ASCII-STRING.
@@ -296,13 +349,109 @@ func (i *uriScheme) UnMarshalIPP(b []byte) (error) {
 	return nil
 }
 
+func (i *uriScheme) String() string {
+	return string(*i)
+}
+
+// ========== memberAttrName ==========
+
+type memberName []byte // US-ASCII-STRING.
+
+func (i *memberName) bytes() []byte {
+	return []byte(*i)
+}
+
+func (i *memberName) len() int {
+	return len(i.bytes())
+}
+
+func (i *memberName) MarshalIPP() ([]byte, error) {
+	return i.bytes(), nil
+}
+
+func (i *memberName) UnMarshalIPP(b []byte) (error) {
+	*i = b
+	return nil
+}
+
+func (i *memberName) String() string {
+	return string(*i)
+}
+
+// ========== collection ==========
+
+// A collection value (RFC 8010 section 3.1.6) is a begCollection (0x34) field with an empty
+// value, then for each member a memberAttrName (0x4a) field naming it followed by its values
+// as additional-value fields, and an endCollection (0x37) field. Member values may be
+// collections themselves.
+//
+//	0x34  name-length  name  0x0000                     begCollection, the attribute "media-col"
+//	0x4a  0x0000  value-length  "media-source"          memberAttrName
+//	0x44  0x0000  value-length  "tray-1"                member value
+//	0x37  0x0000  0x0000                                endCollection
+type collection []attribute // the members, in the order they are encoded
+
+//	Takes the members (named attributes) and returns a collection value (TAG_BEGIN_COLLECTION),
+//	e.g. AddValue(TAG_BEGIN_COLLECTION, "media-col", Collection(newAttribute(TAG_KEYWORD, "media-source", keyword("tray-1"))))
+func Collection(members ...attribute) collection {
+	return collection(members)
+} 
+
+//	Returns the memberAttrName and member value fields and the endCollection field
+func (c collection) marshalMembers() ([]byte, error) {
+	b := new(bytes.Buffer)
+	for _, m := range c {
+		b.WriteByte(TAG_MEMBERNAME)
+		binary.Write(b, binary.BigEndian, uint16(0))
+		binary.Write(b, binary.BigEndian, uint16(len(m.Name())))
+		b.WriteString(m.Name())
+		for _, v := range m.values {
+			value, err := v.marshalValue()
+			if err != nil {
+				return nil, fmt.Errorf("member %s: %v", m.Name(), err)
+			}
+			b.WriteByte(v.valueTag)
+			binary.Write(b, binary.BigEndian, uint16(0))
+			binary.Write(b, binary.BigEndian, uint16(len(value)))
+			b.Write(value)
+			if members, ok := v.value.(collection); ok {
+				mb, err := members.marshalMembers()
+				if err != nil {
+					return nil, fmt.Errorf("member %s: %v", m.Name(), err)
+				}
+				b.Write(mb)
+			}
+		}
+	}
+	b.WriteByte(TAG_END_COLLECTION)
+	binary.Write(b, binary.BigEndian, uint32(0))
+	return b.Bytes(), nil
+} 
+
+//	Returns the members by name
+func (c collection) Map() map[string]attribute {
+	m := make(map[string]attribute)
+	for _, a := range c {
+		m[a.Name()] = a
+	}
+	return m
+} 
+
+func (c collection) String() string {
+	var s []string
+	for _, m := range c {
+		s = append(s, m.Name()+"="+strings.Join(m.Strings(), ","))
+	}
+	return "{" + strings.Join(s, " ") + "}"
+} 
+
 // ========== signedInteger ==========
 
 type signedInteger int32 // SIGNED-INTEGER
 
 func (i *signedInteger) bytes() []byte {
 	buf := new(bytes.Buffer)
-	_ = binary.Write(buf, binary.LittleEndian, i)
+	_ = binary.Write(buf, binary.BigEndian, i)
 	return buf.Bytes()
 }
 
@@ -311,11 +460,35 @@ func (i *signedInteger) len() int {
 }
 
 func (i *signedInteger) UnMarshall(b []byte) error {
-	x, _ := binary.Varint(b)
-	*i = signedInteger(int32(x))
+	if len(b) < 4 {
+		return errShortValue
+	}
+	*i = signedInteger(int32(binary.BigEndian.Uint32(b)))
 	return nil
 }
 
+// ========== octets ==========
+
+// OCTET-STRING with an unspecified format
+type octets []byte
+
+func (i *octets) len() int {
+	return len(*i)
+}
+
+func (i *octets) MarshalIPP() ([]byte, error) {
+	return []byte(*i), nil
+}
+
+func (i *octets) UnMarshalIPP(b []byte) (error) {
+	*i = append(octets{}, b...)
+	return nil
+}
+
+func (i *octets) String() string {
+	return string(*i)
+}
+
 // ========== octetString ==========
 
 // The length of a textWithLanguage value MUST be
@@ -339,13 +512,17 @@ func (o *octetString) MarshalIPP() ([]byte, error) {
 }
 
 func (o *octetString) UnMarshalIPP(b []byte) (error) {
-	l, _ := binary.Varint(b[:2])
-	ll := int16(l)
-	o.nameLength = signedShort(ll)					// a. number of octets in the following field
-	o.name = b[3:ll]								// b. type natural-language
-	lb, _ := binary.Varint(b[ll+1:ll+3])
-	o.valueLength = signedShort(lb)					// c. the number of octets in the following field
-	o.value = b[ll+4:]								// d. type textWithoutLanguage
+	if len(b) < 4 {
+		return errShortValue
+	}
+	ll := int(binary.BigEndian.Uint16(b[:2]))
+	if len(b) < 4+ll {
+		return errShortValue
+	}
+	o.nameLength = signedShort(ll)											// a. number of octets in the following field
+	o.name = naturalLanguage(b[2 : 2+ll])									// b. type natural-language
+	o.valueLength = signedShort(binary.BigEndian.Uint16(b[2+ll : 4+ll]))	// c. the number of octets in the following field
+	o.value = textWithoutLanguage(b[4+ll:])									// d. type textWithoutLanguage
 
 	return nil
 }
@@ -382,15 +559,12 @@ func (t *textWithLanguage) MarshalIPP() ([]byte, error) {
 }
 
 func (t *textWithLanguage) UnMarshalIPP(b []byte) (error) {
-	l, _ := binary.Varint(b[:2])
-	ll := int16(l)
-	t.nameLength = signedShort(ll)					// a. number of octets in the following field
-	t.name = b[3:ll]								// b. type []byte
-	lb, _ := binary.Varint(b[ll+1:ll+3])
-	t.valueLength = signedShort(lb)					// c. the number of octets in the following field
-	t.value = b[ll+4:]								// d. type []byte
+	o := (*octetString)(t)
+	return o.UnMarshalIPP(b)
+}
 
-	return nil
+func (t *textWithLanguage) String() string {
+	return string(t.value)
 }
 
 // ========== nameWithLanguage ==========
@@ -413,15 +587,12 @@ func (t *nameWithLanguage) MarshalIPP() ([]byte, error) {
 }
 
 func (t *nameWithLanguage) UnMarshalIPP(b []byte) (error) {
-	l, _ := binary.Varint(b[:2])
-	ll := int16(l)
-	t.nameLength = signedShort(ll)					// a. number of octets in the following field
-	t.name = b[3:ll]								// b. type []byte
-	lb, _ := binary.Varint(b[ll+1:ll+3])
-	t.valueLength = signedShort(lb)					// c. the number of octets in the following field
-	t.value = b[ll+4:]								// d. type []byte
+	o := (*octetString)(t)
+	return o.UnMarshalIPP(b)
+}
 
-	return nil
+func (t *nameWithLanguage) String() string {
+	return string(t.value)
 }
 
 // ========== ippBoolean ==========
@@ -429,6 +600,14 @@ func (t *nameWithLanguage) UnMarshalIPP(b []byte) (error) {
 // SIGNED-BYTE  where 0x00 is 'false' and 0x01 is 'true'.
 type ippBoolean signedByte
 
+//	Takes a bool and returns ippBoolean (TAG_BOOLEAN)
+func Boolean(b bool) ippBoolean {
+	if b {
+		return ippTrue
+	}
+	return ippFalse
+}
+
 // 0x00 is 'false' and 0x01 is 'true'
 
 func (i *ippBoolean) bytes() ([]byte, error) {
@@ -443,9 +622,9 @@ func (i *ippBoolean) MarshalIPP() ([]byte, error) {
 
 func (i *ippBoolean) UnMarshalIPP(b []byte) (error) {
 	var y signedByte
-	y.UnMarshall(b)
+	err := y.UnMarshall(b)
 	*i = ippBoolean(y)
-	return nil
+	return err
 }
 
 func (i *ippBoolean) String() string {
@@ -461,32 +640,49 @@ func (i *ippBoolean) String() string {
 
 type integer signedInteger
 
+//	Takes an int and returns integer (TAG_INTEGER)
+func Integer(i int) integer {
+	return integer(i)
+}
+
 func (i *integer) MarshalIPP() ([]byte, error) {
-	return i.MarshalIPP()
+	x := signedInteger(*i)
+	return x.bytes(), nil
 }
 
-func (i *integer) UnMarshalIPP([]byte) (error) {
-	return nil
+func (i *integer) UnMarshalIPP(b []byte) (error) {
+	var x signedInteger
+	err := x.UnMarshall(b)
+	*i = integer(x)
+	return err
 }
+
 func (i *integer) String() string {
-	return string(*i)
+	return strconv.Itoa(int(*i))
 }
 // ========== enum ==========
 
 type enum signedInteger
 
+//	Takes an int and returns enum (TAG_ENUM)
+func Enum(i int) enum {
+	return enum(i)
+}
+
 func (e *enum) MarshalIPP() ([]byte, error) {
-	return e.MarshalIPP()
+	x := signedInteger(*e)
+	return x.bytes(), nil
 }
 
 func (e *enum) UnMarshalIPP(b []byte) (error) {
-	lb, _ := binary.Varint(b)
-	*e = enum(lb)	
-	return nil
+	var x signedInteger
+	err := x.UnMarshall(b)
+	*e = enum(x)
+	return err
 }
 
 func (i *enum) String() string {
-	return string(*i)
+	return strconv.Itoa(int(*i))
 }
 
 // ========== dateTime ==========
@@ -505,9 +701,10 @@ type dateTime struct { //	field  octets  contents                range
 	minutes signedByte  //	5       6    minutes                   0..59
 	seconds signedByte  //	6       7    seconds                   0..60
 	//	             (use 60 for leap-second)
-	deciSeconds signedByte //	7       8    deci-seconds              0..9
-	UTC         signedByte //	8       9    direction from UTC        '+' / '-'
-	hoursFrUTC  signedByte //	9      10    hours from UTC            0..11	
+	deciSeconds  signedByte //	7       8    deci-seconds              0..9
+	UTC          signedByte //	8       9    direction from UTC        '+' / '-'
+	hoursFrUTC   signedByte //	9      10    hours from UTC            0..11	
+	minutesFrUTC signedByte //	10     11    minutes from UTC          0..59
 }
 
 //	Returns dateTime with Current time and date.
@@ -529,13 +726,28 @@ func DateTime(d time.Time) (dateTime, error) {
 	dt.hour = signedByte(d.Hour())
 	dt.minutes = signedByte(d.Minute())
 	dt.seconds = signedByte(d.Second())
-	dt.deciSeconds = signedByte(d.Nanosecond())
-	dt.UTC = signedByte(0x002d)
+	dt.deciSeconds = signedByte(d.Nanosecond() / 100000000)
 	_, frUtc := d.Zone()
-	dt.hoursFrUTC = signedByte(frUtc / 60)
+	dt.UTC = signedByte('+')
+	if frUtc < 0 {
+		dt.UTC = signedByte('-')
+		frUtc = -frUtc
+	}
+	dt.hoursFrUTC = signedByte(frUtc / 3600)
+	dt.minutesFrUTC = signedByte(frUtc % 3600 / 60)
 	return dt, nil
 }
 
+//	Returns the dateTime as a time.Time
+func (o *dateTime) Time() time.Time {
+	offset := int(o.hoursFrUTC)*3600 + int(o.minutesFrUTC)*60
+	if o.UTC == '-' {
+		offset = -offset
+	}
+	return time.Date(int(uint16(o.year)), time.Month(o.month), int(o.day), int(o.hour), int(o.minutes),
+		int(o.seconds), int(o.deciSeconds)*100000000, time.FixedZone("", offset))
+}
+
 func (o *dateTime) MarshalIPP() ([]byte, error) {
 	buf := []byte{}
 	buf = append(buf, o.year.bytes()...)
@@ -547,33 +759,33 @@ func (o *dateTime) MarshalIPP() ([]byte, error) {
 	buf = append(buf, o.deciSeconds.bytes()...)
 	buf = append(buf, o.UTC.bytes()...)
 	buf = append(buf, o.hoursFrUTC.bytes()...)
+	buf = append(buf, o.minutesFrUTC.bytes()...)
 
 	return buf, nil
 }
 
 func (o *dateTime) UnMarshalIPP(dt	[]byte) (error) {
-	a, _ := binary.Varint(dt[:16])										//	field  octets  contents                range
-	o.year = signedShort(a) 
-	a, _ = binary.Varint(dt[17:24])			//	1      1-2   year                      0..65536
-	o.month	= signedByte(a) 				//	2       3    month                     1..12
-	a, _ = binary.Varint(dt[25:32])
-	o.day     = signedByte(a) 				//	3       4    day                       1..31
-	a, _ = binary.Varint(dt[33:40])
-	o.hour    = signedByte(a)				//	4       5    hour                      0..23
-	a, _ = binary.Varint(dt[41:48])
-	o.minutes = signedByte(a)				//	5       6    minutes                   0..59
-	a, _ = binary.Varint(dt[49:56]) 
-	o.seconds = signedByte(a)				//	6       7    seconds                   0..60
-											//	             (use 60 for leap-second)
-	a, _ = binary.Varint(dt[57:64]) 
-	o.deciSeconds	= signedByte(a)			//	7       8    deci-seconds              0..9
-	a, _ = binary.Varint(dt[65:72])
-	o.UTC         	= signedByte(a) 		//	8       9    direction from UTC        '+' / '-'
-	a, _ = binary.Varint(dt[73:80])
-	o.hoursFrUTC  	= signedByte(a) 		//	9      10    hours from UTC            0..11	
+	if len(dt) < 11 {
+		return errShortValue
+	}														//	field  octets  contents                range
+	o.year = signedShort(binary.BigEndian.Uint16(dt[0:2]))	//	1      1-2   year                      0..65536
+	o.month	= signedByte(dt[2]) 							//	2       3    month                     1..12
+	o.day     = signedByte(dt[3]) 							//	3       4    day                       1..31
+	o.hour    = signedByte(dt[4])							//	4       5    hour                      0..23
+	o.minutes = signedByte(dt[5])							//	5       6    minutes                   0..59
+	o.seconds = signedByte(dt[6])							//	6       7    seconds                   0..60
+															//	             (use 60 for leap-second)
+	o.deciSeconds	= signedByte(dt[7])						//	7       8    deci-seconds              0..9
+	o.UTC         	= signedByte(dt[8]) 					//	8       9    direction from UTC        '+' / '-'
+	o.hoursFrUTC  	= signedByte(dt[9]) 					//	9      10    hours from UTC            0..11	
+	o.minutesFrUTC	= signedByte(dt[10]) 					//	10     11    minutes from UTC          0..59
 	return nil
 }
 
+func (o *dateTime) String() string {
+	return o.Time().Format(time.RFC3339)
+}
+
 // ========== resolution ==========
 
 //	Resolution Type
@@ -604,17 +816,28 @@ func (o *resolution) MarshalIPP() ([]byte, error) {
 }
 
 func (o *resolution) UnMarshalIPP(b []byte) (error) {
+	if len(b) < 9 {
+		return errShortValue
+	}
 	var s, d signedInteger
-	s.UnMarshall(b[:4])
-	d.UnMarshall(b[5:8])
+	s.UnMarshall(b[0:4])
+	d.UnMarshall(b[4:8])
 	o.crossFeedDirection = s
 	o.feedDirection = d
 	var e signedByte
-	e.UnMarshall(b[9:])
+	e.UnMarshall(b[8:])
 	o.units = e
 	return nil
 }
 
+func (o *resolution) String() string {
+	u := "dpi"
+	if o.units == RES_PER_CM {
+		u = "dpcm"
+	}
+	return fmt.Sprintf("%dx%d%s", o.crossFeedDirection, o.feedDirection, u)
+}
+
 // ========== rangeOfInteger ==========
 
 type rangeOfInteger struct { // Eight octets consisting of 2 SIGNED-INTEGERs.
@@ -622,6 +845,11 @@ type rangeOfInteger struct { // Eight octets consisting of 2 SIGNED-INTEGERs.
 	// bound and the second SIGNED-INTEGER contains the upper bound.
 }
 
+//	Takes the lower and upper bound and returns rangeOfInteger (TAG_RANGE)
+func RangeOfInteger(lower, upper int) rangeOfInteger {
+	return rangeOfInteger{signedInteger(lower), signedInteger(upper)}
+}
+
 func (o *rangeOfInteger) MarshalIPP() ([]byte, error) {
 	buf := []byte{}
 	buf = append(buf, o.lowerBound.bytes()...)
@@ -631,68 +859,113 @@ func (o *rangeOfInteger) MarshalIPP() ([]byte, error) {
 }
 
 func (o *rangeOfInteger) UnMarshalIPP(b []byte) (error) {
+	if len(b) < 8 {
+		return errShortValue
+	}
 	var s, d signedInteger
-	err := s.UnMarshall(b)
+	err := s.UnMarshall(b[0:4])
 	o.lowerBound = s
-	err = d.UnMarshall(b)
+	err = d.UnMarshall(b[4:8])
 	o.upperBound = d
 
 	return err
 }
 
 func (o *rangeOfInteger) String() string {
-	return string(o.lowerBound) + " to " + string(o.upperBound)
+	return strconv.Itoa(int(o.lowerBound)) + " to " + strconv.Itoa(int(o.upperBound))
 }
 // 1setOfX        		// Encoding according to the rules for an attribute with more than 1 value.
 // Each value X is encoded according to the rules for encoding its type.
 
 // ========== set the MarshalIPP and Length Functions ==========
 
-// function refer() uses the TAG value to set the MarshalIPP and Length Functions 
-// (eventually the UnMarshall as well)
+// function refer() uses the TAG value to set the MarshalIPP, Length and String Functions 
 func (a *attributeValue) refer() {
 	switch a.valueTag {
 	case TAG_STRING: // octetString with an  unspecified format
-		a.Marshal = (func() ([]byte, error) { b := a.value.(octetString); return b.MarshalIPP() })
-		a.Length = (func() uint16 { b := a.value.(octetString); return b.len()})
+		a.Marshal = (func() ([]byte, error) { b := a.value.(octets); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(octets); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(octets); return b.String() })
 	case TAG_DATE: // dateTime
 		a.Marshal = (func() ([]byte, error) { b := a.value.(dateTime); return b.MarshalIPP() })
-		a.Length = (func() uint16 { return uint16(9)})
+		a.Length = (func() uint16 { return uint16(11)})
+		a.String = (func() string { b := a.value.(dateTime); return b.String() })
 	case TAG_RESOLUTION: // resolution
 		a.Marshal = (func() ([]byte, error) { b := a.value.(resolution); return b.MarshalIPP() })
-		a.Length = (func() uint16 { return uint16(11)})
+		a.Length = (func() uint16 { return uint16(9)})
+		a.String = (func() string { b := a.value.(resolution); return b.String() })
 	case TAG_RANGE: // rangeOfInteger
 		a.Marshal = (func() ([]byte, error) { b := a.value.(rangeOfInteger); return b.MarshalIPP() })
 		a.Length = (func() uint16 { return uint16(8) })
-	// case TAG_BEGIN_COLLECTION: 	// reserved for definition in a future IETF standards track document
-	//	a.Marshal = (func() ([]byte, error) {b := a.value.(); return b.MarshalIPP()})
+		a.String = (func() string { b := a.value.(rangeOfInteger); return b.String() })
+	case TAG_BEGIN_COLLECTION: // collection, the members follow the empty value (see marshalMembers)
+		a.Marshal = (func() ([]byte, error) { return nil, nil })
+		a.Length = (func() uint16 { return uint16(0) })
+		a.String = (func() string { b, _ := a.value.(collection); return b.String() })
 	case TAG_TEXTLANG: // textWithLanguage
 		a.Marshal = (func() ([]byte, error) { b := a.value.(textWithLanguage); return b.MarshalIPP() })
 		a.Length = (func() uint16 { b := a.value.(textWithLanguage); return b.length() })
-	case TAG_LANGUAGE: // textWithLanguage
+		a.String = (func() string { b := a.value.(textWithLanguage); return b.String() })
+	case TAG_NAMELANG: // nameWithLanguage
+		a.Marshal = (func() ([]byte, error) { b := a.value.(nameWithLanguage); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(nameWithLanguage); return b.length() })
+		a.String = (func() string { b := a.value.(nameWithLanguage); return b.String() })
+	case TAG_TEXT: // textWithoutLanguage
+		a.Marshal = (func() ([]byte, error) { b := a.value.(textWithoutLanguage); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(textWithoutLanguage); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(textWithoutLanguage); return b.String() })
+	case TAG_NAME: // nameWithoutLanguage
+		a.Marshal = (func() ([]byte, error) { b := a.value.(nameWithoutLanguage); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(nameWithoutLanguage); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(nameWithoutLanguage); return b.String() })
+	case TAG_LANGUAGE: // naturalLanguage
 		a.Marshal = (func() ([]byte, error) { b := a.value.(naturalLanguage); return b.MarshalIPP() })
 		a.Length = (func() uint16 { b := a.value.(naturalLanguage); return uint16(b.len()) })
-	case TAG_KEYWORD: // textWithLanguage
+		a.String = (func() string { b := a.value.(naturalLanguage); return b.String() })
+	case TAG_KEYWORD: // keyword
 		a.Marshal = (func() ([]byte, error) { b := a.value.(keyword); return b.MarshalIPP() })
 		a.Length = (func() uint16 { b := a.value.(keyword); return uint16(b.len()) })
-	case TAG_NAMELANG: // nameWithLanguage
-		a.Marshal = (func() ([]byte, error) { b := a.value.(nameWithLanguage); return b.MarshalIPP() })
-		a.Length = (func() uint16 { b := a.value.(nameWithLanguage); return b.length() })
+		a.String = (func() string { b := a.value.(keyword); return b.String() })
+	case TAG_MIMETYPE: // mimeMediaType
+		a.Marshal = (func() ([]byte, error) { b := a.value.(mimeMediaType); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(mimeMediaType); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(mimeMediaType); return b.String() })
+	case TAG_MEMBERNAME: // memberAttrName
+		a.Marshal = (func() ([]byte, error) { b := a.value.(memberName); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b := a.value.(memberName); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(memberName); return b.String() })
 	case TAG_INTEGER: // integer
 		a.Marshal = (func() ([]byte, error) { b := a.value.(integer); return b.MarshalIPP() })
 		a.Length = (func() uint16 { return uint16(4) })
+		a.String = (func() string { b := a.value.(integer); return b.String() })
 	case TAG_BOOLEAN: // boolean
 		a.Marshal = (func() ([]byte, error) { b := a.value.(ippBoolean); return b.MarshalIPP() })
 		a.Length = (func() uint16 {return uint16(1)})
+		a.String = (func() string { b := a.value.(ippBoolean); return b.String() })
 	case TAG_ENUM:
 		a.Marshal = (func() ([]byte, error) { b := a.value.(enum); return b.MarshalIPP() })
 		a.Length = (func() uint16 { return uint16(4) })
+		a.String = (func() string { b := a.value.(enum); return b.String() })
 	case TAG_CHARSET:
 		a.Marshal = (func() ([]byte, error) { b := a.value.(charset); return b.bytes(), nil })
 		a.Length = (func() uint16 { b := a.value.(charset); return uint16(b.len()) })
+		a.String = (func() string { b := a.value.(charset); return b.String() })
 	case TAG_URI:
 		a.Marshal = (func() ([]byte, error) { b := a.value.(uri); return b.bytes(), nil })
 		a.Length = (func() uint16 { b := a.value.(uri); return uint16(b.len())})
+		a.String = (func() string { b := a.value.(uri); return b.String() })
+	case TAG_URISCHEME:
+		a.Marshal = (func() ([]byte, error) { b := a.value.(uriScheme); return b.bytes(), nil })
+		a.Length = (func() uint16 { b := a.value.(uriScheme); return uint16(b.len())})
+		a.String = (func() string { b := a.value.(uriScheme); return b.String() })
+	case TAG_UNSUPPORTED_VALUE, TAG_DEFAULT, TAG_UNKNOWN, TAG_NOVALUE, TAG_NOTSETTABLE, TAG_DELETEATTR, TAG_ADMINDEFINE:
+		// "out-of-band" values have no value field, value-length is 0
+		a.Marshal = (func() ([]byte, error) { return nil, nil })
+		a.Length = (func() uint16 { return uint16(0) })
+		a.String = (func() string { return "" })
+	default: // syntaxes we do not know are carried as raw octets
+		a.Marshal = (func() ([]byte, error) { b, _ := a.value.(octets); return b.MarshalIPP() })
+		a.Length = (func() uint16 { b, _ := a.value.(octets); return uint16(b.len()) })
+		a.String = (func() string { b, _ := a.value.(octets); return b.String() })
 	}
 }
-	
@@ -0,0 +1,194 @@
+package ipp
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"hash"
+)
+
+//   PWG 5100.11 IPP Job and Printer Extensions - Set 2
+//
+//   Cancel-Jobs (0x0038)
+//
+//   Cancels the jobs listed in "job-ids" (1setOf integer(1:MAX)), or every not completed job of
+//   the printer when "job-ids" is left out. Only an operator or administrator may cancel jobs
+//   of other users. If any of the jobs can not be canceled none is, the Printer returns
+//   'client-error-not-possible' and lists the offending job-ids in the unsupported-attributes group.
+//
+//   Cancel-My-Jobs (0x0039)
+//
+//   Like Cancel-Jobs for the jobs owned by the requesting user, without "job-ids" every not
+//   completed job of that user is canceled.
+//
+//   Resubmit-Job (0x003A)
+//
+//   Creates a copy of a retained job, optionally with new job template attributes supplied in
+//   the job-attributes group. The response holds the "job-id" and "job-uri" of the new job.
+//
+//   Close-Job (0x003B)
+//
+//   Tells the Printer that a job created with Create-Job will get no more documents, as if the
+//   last Send-Document had "last-document" set to true.
+//
+//   Get-Job-Templates
+//
+//   Has no operation-id in PWG 5100.11 or the IANA IPP registry, no Printer implements it. The
+//   job templates a Printer offers are the collections of its "job-presets-supported" Printer
+//   attribute (PWG 5100.13): each has a "preset-name" and the job template attributes of the
+//   preset, e.g. "sides" and "print-color-mode". GetJobTemplates reads them with
+//   Get-Printer-Attributes.
+//
+//   Identify-Printer (0x003C)
+//
+//   Makes the Printer identify itself to a person standing next to it. "identify-actions"
+//   (1setOf keyword) is any of 'display', 'flash', 'sound' and 'speak'; "message" (text(127))
+//   is shown or spoken by 'display' and 'speak'.
+//
+//   job-password (octetString(255)) and job-password-encryption (keyword)
+//
+//   Print-Job and Create-Job operation attributes that hold a job until its owner enters the
+//   password at the Printer ("secure release"). The client hashes the password with the
+//   algorithm named by job-password-encryption, 'none' sends it as is.
+
+//	Cancel-Jobs: cancels the jobs jobIds of a printer (see PrinterUri), or all of its jobs when no
+//	job-id is given. The job-ids the printer could not cancel are returned along with the error.
+func (c *CupsServer) CancelJobs(printer string, jobIds ...int) ([]int, error) {
+	return c.cancelJobs(CANCEL_JOBS, printer, jobIds)
+}
+
+//	Cancel-My-Jobs: cancels the jobs jobIds of the requesting user, or all of them when no
+//	job-id is given. The job-ids the printer could not cancel are returned along with the error.
+func (c *CupsServer) CancelMyJobs(printer string, jobIds ...int) ([]int, error) {
+	return c.cancelJobs(CANCEL_MY_JOBS, printer, jobIds)
+}
+
+func (c *CupsServer) cancelJobs(operationId uint16, printer string, jobIds []int) ([]int, error) {
+	m := c.newRequest(operationId, c.PrinterUri(printer), 0)
+	if len(jobIds) > 0 {
+		a := NewAttribute()
+		for _, id := range jobIds {
+			a.AddValue(TAG_INTEGER, "job-ids", integer(id))
+		}
+		m.AppendAttribute(a)
+	}
+	r, err := c.doRequest(m, "/jobs/")
+	var failed []int
+	for _, a := range r.Unsupported() {
+		if a.Name() == "job-ids" {
+			failed = append(failed, a.Ints()...)
+		}
+	}
+	return failed, err
+}
+
+//	Resubmit-Job: resubmits a retained job with the job template attributes attrs, e.g.
+//	newAttribute(TAG_INTEGER, "copies", integer(2)). Returns the job-id of the new job.
+func (c *CupsServer) ResubmitJob(jobId int, attrs ...attribute) (int, error) {
+	m := c.newRequest(RESUBMIT_JOB, "", jobId)
+	if len(attrs) > 0 {
+		m.AddGroup(TAG_JOB)
+		for _, a := range attrs {
+			m.AppendGroupAttribute(TAG_JOB, a)
+		}
+	}
+	r, err := c.doRequest(m, "/jobs/")
+	if err != nil {
+		return 0, err
+	}
+	a, _ := r.Attribute(TAG_JOB, "job-id")
+	return a.Int(), nil
+}
+
+//	Close-Job: closes a job created with CreateJob, no more documents will be sent
+func (c *CupsServer) CloseJob(jobId int) error {
+	_, err := c.doRequest(c.newRequest(CLOSE_JOB, "", jobId), "/jobs/")
+	return err
+}
+
+//	JobTemplate is a named set of job template attributes a printer offers
+type JobTemplate struct {
+	Name       string      // preset-name
+	Attributes []attribute // the job template attributes, e.g. sides or media-col
+}
+
+//	Returns the job templates of a printer (see PrinterUri), its job-presets-supported. The
+//	Attributes of a template can be passed to PrintJob or CreateJob as they are. The request is
+//	sent to the host and path of the printer-uri.
+func (c *CupsServer) GetJobTemplates(printer string) ([]JobTemplate, error) {
+	printerUri := c.PrinterUri(printer)
+	m := c.newRequest(GET_PRINTER_ATTRIBUTES, printerUri, 0)
+	m.AppendAttribute(requestedAttributes([]string{"job-presets-supported"}))
+	r, err := c.doPrinterRequest(m, printerUri)
+	if err != nil {
+		return nil, err
+	}
+	a, _ := r.Attribute(TAG_PRINTER, "job-presets-supported")
+	var templates []JobTemplate
+	for _, col := range a.Collections() {
+		var t JobTemplate
+		for _, member := range col {
+			if member.Name() == "preset-name" {
+				t.Name = member.String()
+			} else {
+				t.Attributes = append(t.Attributes, member)
+			}
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+//	Identify-Printer: makes the printer flash, sound, display or speak message, actions
+//	defaults to the printer's identify-actions-default when empty
+func (c *CupsServer) IdentifyPrinter(printer string, actions []string, message string) error {
+	m := c.newRequest(IDENTIFY_PRINTER, c.PrinterUri(printer), 0)
+	if len(actions) > 0 {
+		m.AppendAttribute(keywords("identify-actions", actions))
+	}
+	if message != "" {
+		m.AddAttribute(TAG_TEXT, "message", textWithoutLanguage(message))
+	}
+	_, err := c.doRequest(m, "/admin/")
+	return err
+}
+
+//	The job-password-encryption keywords JobPassword supports
+var jobPasswordHashes = map[string]func() hash.Hash{
+	"md5":      md5.New,
+	"sha":      sha1.New,
+	"sha2-224": sha256.New224,
+	"sha2-256": sha256.New,
+	"sha2-384": sha512.New384,
+	"sha2-512": sha512.New,
+}
+
+//	Returns the job-password and job-password-encryption operation attributes that hold a
+//	Print-Job or Create-Job until password is entered at the printer, e.g.
+//
+//		attrs, err := JobPassword("1234", "sha2-256")
+//		jobId, err := c.CreateJob("office", attrs...)
+//
+//	encryption is 'none' or one of 'md5', 'sha', 'sha2-224', 'sha2-256', 'sha2-384' and 'sha2-512',
+//	the printer lists the ones it accepts in job-password-encryption-supported.
+func JobPassword(password, encryption string) ([]attribute, error) {
+	value := []byte(password)
+	if encryption != "none" {
+		newHash, ok := jobPasswordHashes[encryption]
+		if !ok {
+			return nil, errors.New("ipp: job-password-encryption " + encryption + " is not supported")
+		}
+		h := newHash()
+		h.Write(value)
+		value = h.Sum(nil)
+	}
+	if len(value) > 255 {
+		return nil, errors.New("ipp: job-password is longer than 255 octets")
+	}
+	return []attribute{
+		newAttribute(TAG_STRING, "job-password", octets(value)),
+		newAttribute(TAG_KEYWORD, "job-password-encryption", keyword(encryption)),
+	}, nil
+}
@@ -0,0 +1,330 @@
+package ipp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//   A JobStore keeps the jobs of a MemoryPrinter across restarts. The printer saves a job every
+//   time it changes (a new job, a new document, a state transition) and deletes it when the job
+//   history is purged or expires.
+//
+//   After a restart the printer loads the saved jobs (see MemoryPrinter.SetStore):
+//
+//      - jobs that were 'processing' or 'processing-stopped' when the printer went down are
+//        'pending' again with the job-state-reasons 'job-restartable' and are printed again,
+//      - 'pending' and 'pending-held' jobs keep their state, a Create-Job still waiting for
+//        documents keeps waiting for Send-Document,
+//      - completed, canceled and aborted jobs are back in the job history.
+//
+//   Retention (see MemoryPrinter.SetRetention) follows the CUPS PreserveJobHistory and
+//   PreserveJobFiles settings: the documents of a job in a terminal state are removed after
+//   the preserve-job-files duration, the job itself after the preserve-job-history duration.
+
+//	JobStore persists the jobs of a MemoryPrinter
+type JobStore interface {
+	Save(j Job) error     // stores a new job or the new state of a job
+	Delete(id int) error  // removes a job and its documents
+	Load() ([]Job, error) // returns every stored job, ordered by job-id
+	LastJobId() int       // the highest job-id ever saved, deleted jobs included
+}
+
+//	DirStore is a JobStore in a local directory: an append-only log jobs.log with one JSON
+//	record per change and one file per document. The log is compacted on Load. The highest
+//	job-id is kept in last-job-id so that the ids of deleted jobs are never handed out again.
+type DirStore struct {
+	mu   sync.Mutex
+	dir  string
+	log  *os.File
+	last int // the highest job-id saved
+}
+
+//	Opens (or creates) the job store in dir
+func OpenDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+//	A line of jobs.log
+type jobRecord struct {
+	Id           int         `json:"job-id"`
+	Deleted      bool        `json:"deleted,omitempty"`
+	Uri          string      `json:"job-uri,omitempty"`
+	Name         string      `json:"job-name,omitempty"`
+	User         string      `json:"job-originating-user-name,omitempty"`
+	State        int         `json:"job-state,omitempty"`
+	StateReasons []string    `json:"job-state-reasons,omitempty"`
+	StateMessage string      `json:"job-state-message,omitempty"`
+	HoldUntil    string      `json:"job-hold-until,omitempty"`
+	Attributes   []byte      `json:"attributes,omitempty"` // the job template attributes, IPP encoded
+	Documents    []docRecord `json:"documents,omitempty"`
+	Created      time.Time   `json:"created"`
+	Processing   time.Time   `json:"processing"`
+	Completed    time.Time   `json:"completed"`
+	Incoming     bool        `json:"incoming,omitempty"`
+	Purged       bool        `json:"purged,omitempty"`
+}
+
+type docRecord struct {
+	Number   int    `json:"document-number"`
+	Name     string `json:"document-name,omitempty"`
+	Format   string `json:"document-format"`
+	Detected string `json:"document-format-detected,omitempty"`
+	File     string `json:"file"`
+	Canceled bool   `json:"canceled,omitempty"`
+}
+
+func (s *DirStore) Save(j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec := jobRecord{
+		Id:           j.Id,
+		Uri:          j.Uri,
+		Name:         j.Name,
+		User:         j.User,
+		State:        j.State,
+		StateReasons: j.StateReasons,
+		StateMessage: j.StateMessage,
+		HoldUntil:    j.HoldUntil,
+		Created:      j.Created,
+		Processing:   j.Processing,
+		Completed:    j.Completed,
+		Incoming:     j.incoming,
+		Purged:       j.Purged,
+	}
+	if j.Id > s.last {
+		if err := writeFileSync(filepath.Join(s.dir, "last-job-id"), []byte(strconv.Itoa(j.Id))); err != nil {
+			return err
+		}
+		s.last = j.Id
+	}
+	if len(j.Attributes) > 0 {
+		m := NewMessage(0)
+		m.AddGroup(TAG_JOB)
+		for _, a := range j.Attributes {
+			m.AppendGroupAttribute(TAG_JOB, a)
+		}
+		b, err := m.marshallMsg()
+		if err != nil {
+			return err
+		}
+		rec.Attributes = b
+	}
+	for _, d := range j.Documents {
+		file := strconv.Itoa(j.Id) + "-" + strconv.Itoa(d.Number) + ".doc"
+		if j.Purged {
+			os.Remove(filepath.Join(s.dir, file))
+		} else if _, err := os.Stat(filepath.Join(s.dir, file)); os.IsNotExist(err) {
+			if err := writeFileSync(filepath.Join(s.dir, file), d.Data); err != nil {
+				return err
+			}
+		}
+		rec.Documents = append(rec.Documents, docRecord{Number: d.Number, Name: d.Name, Format: d.Format, Detected: d.Detected, File: file, Canceled: d.Canceled})
+	}
+	return s.append(rec)
+}
+
+func (s *DirStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, _ := filepath.Glob(filepath.Join(s.dir, strconv.Itoa(id)+"-*.doc"))
+	for _, f := range files {
+		os.Remove(f)
+	}
+	return s.append(jobRecord{Id: id, Deleted: true})
+}
+
+//	Replays jobs.log, drops the records of deleted jobs and superseded states and rewrites the
+//	log with the current state of every job
+func (s *DirStore) Load() ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log != nil {
+		s.log.Close()
+		s.log = nil
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(s.dir, "last-job-id")); err == nil {
+		s.last, _ = strconv.Atoi(string(b))
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	recs := make(map[int]jobRecord)
+	f, err := os.Open(filepath.Join(s.dir, "jobs.log"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		sc := bufio.NewScanner(f)
+		sc.Buffer(nil, 1<<26)
+		for line := 1; sc.Scan(); line++ {
+			var rec jobRecord
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				// a record cut short by a crash or a corrupt line, the records after it still count
+				log.Println("ipp: skipping line", line, "of", f.Name(), err)
+				continue
+			}
+			if rec.Id > s.last {
+				// a log written before last-job-id was
+				s.last = rec.Id
+			}
+			if rec.Deleted {
+				delete(recs, rec.Id)
+			} else {
+				recs[rec.Id] = rec
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			// the log was not read to the end, rewriting it would lose the rest
+			return nil, err
+		}
+	}
+
+	var ids []int
+	for id := range recs {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var jobs []Job
+	tmp := filepath.Join(s.dir, "jobs.log.tmp")
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(out)
+	for _, id := range ids {
+		rec := recs[id]
+		j, err := s.job(rec)
+		if err != nil {
+			out.Close()
+			return nil, err
+		}
+		jobs = append(jobs, j)
+		if err := enc.Encode(rec); err != nil {
+			out.Close()
+			return nil, err
+		}
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return nil, err
+	}
+	out.Close()
+	if err := os.Rename(tmp, filepath.Join(s.dir, "jobs.log")); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *DirStore) LastJobId() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+//	Closes jobs.log
+func (s *DirStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+//	Turns a record back into a Job, reading its documents. A job whose document file is gone is
+//	aborted with the job-state-reasons 'document-access-error'.
+func (s *DirStore) job(rec jobRecord) (Job, error) {
+	j := Job{
+		Id:           rec.Id,
+		Uri:          rec.Uri,
+		Name:         rec.Name,
+		User:         rec.User,
+		State:        rec.State,
+		StateReasons: rec.StateReasons,
+		StateMessage: rec.StateMessage,
+		HoldUntil:    rec.HoldUntil,
+		Created:      rec.Created,
+		Processing:   rec.Processing,
+		Completed:    rec.Completed,
+		Purged:       rec.Purged,
+		incoming:     rec.Incoming,
+	}
+	if len(rec.Attributes) > 0 {
+		m, err := ParseMessage(rec.Attributes)
+		if err != nil {
+			return j, err
+		}
+		if ag, ok := m.Group(TAG_JOB); ok {
+			j.Attributes = ag.attributes
+		}
+	}
+	for _, d := range rec.Documents {
+		doc := JobDocument{Number: d.Number, Name: d.Name, Format: d.Format, Detected: d.Detected, Canceled: d.Canceled}
+		if !rec.Purged {
+			data, err := ioutil.ReadFile(filepath.Join(s.dir, d.File))
+			if err != nil && !os.IsNotExist(err) {
+				return j, err
+			}
+			if err != nil && !j.Done() {
+				j.setState(JOB_ABORTED, "document-access-error")
+				j.StateMessage = err.Error()
+			}
+			doc.Data = data
+		}
+		j.Documents = append(j.Documents, doc)
+	}
+	return j, nil
+}
+
+//	Appends a record to jobs.log and flushes it to disk, the caller holds s.mu
+func (s *DirStore) append(rec jobRecord) error {
+	if s.log == nil {
+		f, err := os.OpenFile(filepath.Join(s.dir, "jobs.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		s.log = f
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err = s.log.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+//	Writes a file under a temporary name and renames it when it is on disk, a crash never leaves
+//	a partial document behind
+func writeFileSync(name string, data []byte) error {
+	f, err := os.OpenFile(name+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".tmp")
+		return err
+	}
+	return os.Rename(name+".tmp", name)
+}
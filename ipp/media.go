@@ -0,0 +1,332 @@
+package ipp
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//   PWG 5101.1 Standard for Media Standardized Names
+//
+//   A self-describing media name is "class_size-name_short-dim" where short-dim is
+//   "<width>x<length><unit>" with the unit "mm" or "in", e.g.
+//
+//      iso_a4_210x297mm
+//      na_letter_8.5x11in
+//      na_number-10_4.125x9.5in
+//      custom_my-label_62x29mm
+//
+//   media-size (in media-col) holds x-dimension and y-dimension in hundredths of millimetres:
+//   a dimension in mm is multiplied by 100, one in inches by 2540, then rounded.
+//
+//   Legacy names from RFC 2911 and earlier drafts ('a4', 'letter', 'iso-a4', 'na-legal') and the
+//   PageSize names of PPD files ("A4", "Letter", "Letter.Fullbleed", "w612h792") are mapped with
+//   the standard size table below. The PPD suffixes ".Fullbleed" and ".Borderless" select the
+//   borderless variant of a size.
+
+//	Media is a media size named by PWG 5101.1
+type Media struct {
+	Class      string // e.g. "iso", "na", "jis", "jpn", "oe", "om", "prc", "roc" or "custom"
+	Name       string // the size name, e.g. "a4" or "letter"
+	Width      int    // x-dimension in hundredths of millimetres
+	Length     int    // y-dimension in hundredths of millimetres
+	Inches     bool   // the self-describing name uses inches
+	Borderless bool   // the borderless (full bleed) variant, from a PPD name
+}
+
+//	A size of the standard size table
+type mediaSize struct {
+	pwg    string // self-describing name
+	legacy string // legacy keyword, "" if there is none
+	ppd    string // PPD PageSize name, "" if there is none
+}
+
+//	The standard sizes of PWG 5101.1, with their legacy and PPD names
+var mediaSizes = []mediaSize{
+	{"iso_a0_841x1189mm", "a0", "A0"},
+	{"iso_a1_594x841mm", "a1", "A1"},
+	{"iso_a2_420x594mm", "a2", "A2"},
+	{"iso_a3_297x420mm", "a3", "A3"},
+	{"iso_a4_210x297mm", "a4", "A4"},
+	{"iso_a5_148x210mm", "a5", "A5"},
+	{"iso_a6_105x148mm", "a6", "A6"},
+	{"iso_a7_74x105mm", "a7", "A7"},
+	{"iso_a8_52x74mm", "a8", "A8"},
+	{"iso_a9_37x52mm", "a9", "A9"},
+	{"iso_a10_26x37mm", "a10", "A10"},
+	{"iso_b0_1000x1414mm", "b0", "ISOB0"},
+	{"iso_b1_707x1000mm", "b1", "ISOB1"},
+	{"iso_b2_500x707mm", "b2", "ISOB2"},
+	{"iso_b3_353x500mm", "b3", "ISOB3"},
+	{"iso_b4_250x353mm", "b4", "ISOB4"},
+	{"iso_b5_176x250mm", "b5", "ISOB5"},
+	{"iso_b6_125x176mm", "b6", "ISOB6"},
+	{"iso_b7_88x125mm", "b7", "ISOB7"},
+	{"iso_b8_62x88mm", "b8", "ISOB8"},
+	{"iso_b9_44x62mm", "b9", "ISOB9"},
+	{"iso_b10_31x44mm", "b10", "ISOB10"},
+	{"iso_c3_324x458mm", "c3", "EnvC3"},
+	{"iso_c4_229x324mm", "c4", "EnvC4"},
+	{"iso_c5_162x229mm", "c5", "EnvC5"},
+	{"iso_c6_114x162mm", "c6", "EnvC6"},
+	{"iso_c6c5_114x229mm", "c6c5", "EnvC65"},
+	{"iso_dl_110x220mm", "dl", "EnvDL"},
+	{"iso_sra3_320x450mm", "sra3", "SRA3"},
+	{"jis_b0_1030x1456mm", "", "B0"},
+	{"jis_b1_728x1030mm", "", "B1"},
+	{"jis_b2_515x728mm", "", "B2"},
+	{"jis_b3_364x515mm", "", "B3"},
+	{"jis_b4_257x364mm", "", "B4"},
+	{"jis_b5_182x257mm", "", "B5"},
+	{"jis_b6_128x182mm", "", "B6"},
+	{"jis_b7_91x128mm", "", "B7"},
+	{"jis_b8_64x91mm", "", "B8"},
+	{"jis_b9_45x64mm", "", "B9"},
+	{"jis_b10_32x45mm", "", "B10"},
+	{"na_letter_8.5x11in", "letter", "Letter"},
+	{"na_legal_8.5x14in", "legal", "Legal"},
+	{"na_executive_7.25x10.5in", "executive", "Executive"},
+	{"na_ledger_11x17in", "tabloid", "Tabloid"},
+	{"na_invoice_5.5x8.5in", "statement", "Statement"},
+	{"na_foolscap_8.5x13in", "folio", "FanFoldGermanLegal"},
+	{"na_govt-letter_8x10in", "", "8x10"},
+	{"na_index-3x5_3x5in", "", "3x5"},
+	{"na_index-4x6_4x6in", "", "4x6"},
+	{"na_5x7_5x7in", "", "5x7"},
+	{"na_index-5x8_5x8in", "", "5x8"},
+	{"na_number-10_4.125x9.5in", "na-number-10-envelope", "Env10"},
+	{"na_monarch_3.875x7.5in", "monarch-envelope", "EnvMonarch"},
+	{"na_a2_4.375x5.75in", "", "EnvA2"},
+	{"na_c_17x22in", "c", "AnsiC"},
+	{"na_d_22x34in", "d", "AnsiD"},
+	{"na_e_34x44in", "e", "AnsiE"},
+	{"na_arch-a_9x12in", "arch-a", "ARCHA"},
+	{"na_arch-b_12x18in", "arch-b", "ARCHB"},
+	{"na_arch-c_18x24in", "arch-c", "ARCHC"},
+	{"na_arch-d_24x36in", "arch-d", "ARCHD"},
+	{"na_arch-e_36x48in", "arch-e", "ARCHE"},
+	{"na_super-b_13x19in", "super-b", "SuperB"},
+	{"jpn_hagaki_100x148mm", "", "Postcard"},
+	{"jpn_oufuku_148x200mm", "", "DoublePostcardRotated"},
+	{"jpn_chou3_120x235mm", "", "EnvChou3"},
+	{"jpn_chou4_90x205mm", "", "EnvChou4"},
+	{"jpn_kaku2_240x332mm", "", "EnvKaku2"},
+	{"oe_photo-l_3.5x5in", "", "3.5x5"},
+	{"om_small-photo_100x150mm", "", "100x150mm"},
+	{"prc_16k_146x215mm", "", "PRC16K"},
+	{"prc_32k_97x151mm", "", "PRC32K"},
+	{"roc_16k_7.75x10.75in", "", ""},
+	{"roc_8k_10.75x15.5in", "", ""},
+}
+
+//	Legacy names that are not in the size table
+var legacyMediaNames = map[string]string{
+	"ledger": "na_ledger_11x17in",
+	"a":      "na_letter_8.5x11in",
+	"b":      "na_ledger_11x17in",
+	"env10":  "na_number-10_4.125x9.5in",
+}
+
+//	The size table indexed by self-describing, legacy and (lower case) PPD name
+var (
+	mediaByPwg    = make(map[string]Media)
+	mediaByLegacy = make(map[string]Media)
+	mediaByPpd    = make(map[string]Media)
+	standardMedia []Media
+)
+
+func init() {
+	for _, s := range mediaSizes {
+		m, err := parsePwgMedia(s.pwg)
+		if err != nil {
+			panic("ipp: bad media size " + s.pwg)
+		}
+		standardMedia = append(standardMedia, m)
+		mediaByPwg[s.pwg] = m
+		if s.legacy != "" {
+			mediaByLegacy[s.legacy] = m
+		}
+		if s.ppd != "" {
+			mediaByPpd[strings.ToLower(s.ppd)] = m
+		}
+	}
+	for legacy, pwg := range legacyMediaNames {
+		mediaByLegacy[legacy] = mediaByPwg[pwg]
+	}
+}
+
+//	Size tolerance of MediaForSize in hundredths of millimetres, PPD sizes are in whole points
+const mediaEpsilon = 50
+
+//	Parses a self-describing name, a legacy name or a PPD PageSize name
+func ParseMedia(name string) (Media, error) {
+	if m, err := parsePwgMedia(name); err == nil {
+		if std, ok := mediaByPwg[name]; ok {
+			return std, nil
+		}
+		return m, nil
+	}
+	if m, ok := legacyMedia(name); ok {
+		return m, nil
+	}
+	if m, ok := ppdMedia(name); ok {
+		return m, nil
+	}
+	return Media{}, errors.New("ipp: unknown media " + name)
+}
+
+//	Parses "class_name_WxHunit"
+func parsePwgMedia(name string) (Media, error) {
+	first, last := strings.Index(name, "_"), strings.LastIndex(name, "_")
+	if first <= 0 || last <= first+1 {
+		return Media{}, errors.New("ipp: " + name + " is not a self-describing media name")
+	}
+	m := Media{Class: name[:first], Name: name[first+1 : last]}
+	dims := name[last+1:]
+	scale := 100.0
+	switch {
+	case strings.HasSuffix(dims, "in"):
+		scale, m.Inches = 2540, true
+	case !strings.HasSuffix(dims, "mm"):
+		return Media{}, errors.New("ipp: " + name + " has no unit")
+	}
+	wl := strings.Split(dims[:len(dims)-2], "x")
+	if len(wl) != 2 {
+		return Media{}, errors.New("ipp: " + name + " has no WxH dimensions")
+	}
+	w, err1 := strconv.ParseFloat(wl[0], 64)
+	l, err2 := strconv.ParseFloat(wl[1], 64)
+	if err1 != nil || err2 != nil || w <= 0 || l <= 0 {
+		return Media{}, errors.New("ipp: " + name + " has bad dimensions")
+	}
+	m.Width = int(math.Floor(w*scale + 0.5))
+	m.Length = int(math.Floor(l*scale + 0.5))
+	return m, nil
+}
+
+//	Looks up a legacy keyword, e.g. "a4", "letter", or "iso-a4" and "jis-b5" with the class.
+//	Keywords are lower case, "B5" is the PPD name of JIS B5 and not the legacy ISO B5.
+func legacyMedia(name string) (Media, bool) {
+	if m, ok := mediaByLegacy[name]; ok {
+		return m, true
+	}
+	if i := strings.Index(name, "-"); i > 0 {
+		for _, m := range standardMedia {
+			if m.Class == name[:i] && m.Name == name[i+1:] {
+				return m, true
+			}
+		}
+	}
+	return Media{}, false
+}
+
+//	Looks up a PPD PageSize name, e.g. "A4", "Letter.Fullbleed" or the custom size "w612h792"
+//	in points
+func ppdMedia(name string) (Media, bool) {
+	base, suffix := name, ""
+	if i := strings.LastIndex(name, "."); i > 0 && strings.Trim(name[i+1:], "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz") == "" {
+		base, suffix = name[:i], name[i+1:]
+	}
+	m, ok := mediaByPpd[strings.ToLower(base)]
+	if !ok && strings.HasPrefix(base, "w") {
+		if i := strings.Index(base, "h"); i > 1 {
+			w, err1 := strconv.ParseFloat(base[1:i], 64)
+			l, err2 := strconv.ParseFloat(base[i+1:], 64)
+			if err1 == nil && err2 == nil && w > 0 && l > 0 {
+				m, ok = MediaForSize(int(math.Floor(w*2540/72+0.5)), int(math.Floor(l*2540/72+0.5))), true
+			}
+		}
+	}
+	if !ok {
+		return Media{}, false
+	}
+	m.Borderless = suffix == "Fullbleed" || suffix == "Borderless"
+	return m, true
+}
+
+//	Returns the self-describing name, e.g. "iso_a4_210x297mm"
+func (m Media) String() string {
+	var w, l string
+	unit := "mm"
+	if m.Inches {
+		w, l, unit = dimension(m.Width, 2540, 1000), dimension(m.Length, 2540, 1000), "in"
+	} else {
+		w, l = dimension(m.Width, 100, 100), dimension(m.Length, 100, 100)
+	}
+	return m.Class + "_" + m.Name + "_" + w + "x" + l + unit
+}
+
+//	Returns hundredths of millimetres in the unit of scale hundredths, rounded to 1/precision
+func dimension(v int, scale, precision float64) string {
+	return strconv.FormatFloat(math.Floor(float64(v)/scale*precision+0.5)/precision, 'f', -1, 64)
+}
+
+//	Returns the legacy name of a standard size, e.g. "a4", or "" if it has none
+func (m Media) LegacyName() string {
+	for _, s := range mediaSizes {
+		if s.legacy != "" && s.pwg == m.pwgName() {
+			return s.legacy
+		}
+	}
+	return ""
+}
+
+//	Returns the PPD PageSize name, e.g. "Letter" or "Letter.Fullbleed". Sizes without a PPD
+//	name are given as custom sizes in points, e.g. "w612h792".
+func (m Media) PPDName() string {
+	name := ""
+	for _, s := range mediaSizes {
+		if s.ppd != "" && s.pwg == m.pwgName() {
+			name = s.ppd
+		}
+	}
+	if name == "" {
+		pt := func(v int) string { return strconv.Itoa(int(math.Floor(float64(v)*72/2540 + 0.5))) }
+		name = "w" + pt(m.Width) + "h" + pt(m.Length)
+	}
+	if m.Borderless {
+		name += ".Fullbleed"
+	}
+	return name
+}
+
+//	Returns the name of the standard size of the same class, name and dimensions
+func (m Media) pwgName() string {
+	m.Borderless = false
+	return m.String()
+}
+
+//	Returns the standard size of width x length (in hundredths of millimetres) or a custom size
+//	"custom_WxHmm_WxHmm" when none is within 0.5 mm
+func MediaForSize(width, length int) Media {
+	if m, d := NearestMedia(width, length); d <= mediaEpsilon {
+		return m
+	}
+	m := Media{Class: "custom", Width: width, Length: length}
+	m.Name = dimension(width, 100, 100) + "x" + dimension(length, 100, 100) + "mm"
+	return m
+}
+
+//	Returns the standard size closest to width x length (in hundredths of millimetres) and
+//	the larger of the differences in width and length
+func NearestMedia(width, length int) (Media, int) {
+	best, bestDist := Media{}, math.MaxInt32
+	for _, m := range standardMedia {
+		d := abs(m.Width - width)
+		if dl := abs(m.Length - length); dl > d {
+			d = dl
+		}
+		if d < bestDist {
+			best, bestDist = m, d
+		}
+	}
+	return best, bestDist
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
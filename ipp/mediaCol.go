@@ -0,0 +1,175 @@
+package ipp
+
+//   media-col (PWG 5100.7 section 6.3)
+//
+//   media-col is a collection describing the media of a job; the Printer lists the media it
+//   supports in media-col-database and the media loaded in its trays in media-col-ready, both
+//   1setOf collection. The members used here are:
+//
+//      media-size          collection of x-dimension and y-dimension, integer hundredths of
+//                          millimetres (rangeOfInteger for custom sizes in media-col-database)
+//      media-size-name     keyword, e.g. 'iso_a4_210x297mm'
+//      media-type          keyword, e.g. 'stationery' or 'photographic-glossy'
+//      media-source        keyword, e.g. 'main', 'tray-1' or 'manual'
+//      media-color         keyword, e.g. 'white'
+//      media-top-margin, media-bottom-margin, media-left-margin, media-right-margin
+//                          integer hundredths of millimetres, 0 for borderless printing
+
+//	MediaCol is a media-col collection
+type MediaCol struct {
+	Width     int    // media-size x-dimension, the minimum of a custom size range
+	Length    int    // media-size y-dimension, the minimum of a custom size range
+	MaxWidth  int    // the maximum x-dimension of a custom size range, 0 for a fixed size
+	MaxLength int    // the maximum y-dimension of a custom size range, 0 for a fixed size
+	SizeName  string // media-size-name
+	Type      string // media-type
+	Source    string // media-source
+	Color     string // media-color
+	Margins   *MediaMargins
+}
+
+//	MediaMargins are the media-*-margin members in hundredths of millimetres
+type MediaMargins struct {
+	Top, Bottom, Left, Right int
+}
+
+//	Returns a MediaCol of the size of m, borderless if m is (e.g. "Letter.Fullbleed")
+func NewMediaCol(m Media) MediaCol {
+	mc := MediaCol{Width: m.Width, Length: m.Length}
+	if m.Borderless {
+		mc.Margins = &MediaMargins{}
+	}
+	return mc
+}
+
+//	Returns the "media-col" attribute, e.g. for the job-attributes group of Print-Job
+func (mc MediaCol) Attribute() attribute {
+	return mediaColAttribute("media-col", mc)
+}
+
+//	Returns a 1setOf collection attribute of MediaCols, e.g. media-col-database
+func mediaColAttribute(name string, cols ...MediaCol) attribute {
+	a := NewAttribute()
+	for _, mc := range cols {
+		a.AddValue(TAG_BEGIN_COLLECTION, name, mc.collection())
+	}
+	return a
+}
+
+func (mc MediaCol) collection() collection {
+	var members []attribute
+	if mc.Width > 0 || mc.Length > 0 {
+		x := newAttribute(TAG_INTEGER, "x-dimension", integer(mc.Width))
+		if mc.MaxWidth > 0 {
+			x = newAttribute(TAG_RANGE, "x-dimension", RangeOfInteger(mc.Width, mc.MaxWidth))
+		}
+		y := newAttribute(TAG_INTEGER, "y-dimension", integer(mc.Length))
+		if mc.MaxLength > 0 {
+			y = newAttribute(TAG_RANGE, "y-dimension", RangeOfInteger(mc.Length, mc.MaxLength))
+		}
+		members = append(members, newAttribute(TAG_BEGIN_COLLECTION, "media-size", Collection(x, y)))
+	}
+	for _, k := range []struct{ name, value string }{
+		{"media-size-name", mc.SizeName},
+		{"media-type", mc.Type},
+		{"media-source", mc.Source},
+		{"media-color", mc.Color},
+	} {
+		if k.value != "" {
+			members = append(members, newAttribute(TAG_KEYWORD, k.name, keyword(k.value)))
+		}
+	}
+	if m := mc.Margins; m != nil {
+		members = append(members,
+			newAttribute(TAG_INTEGER, "media-top-margin", integer(m.Top)),
+			newAttribute(TAG_INTEGER, "media-bottom-margin", integer(m.Bottom)),
+			newAttribute(TAG_INTEGER, "media-left-margin", integer(m.Left)),
+			newAttribute(TAG_INTEGER, "media-right-margin", integer(m.Right)),
+		)
+	}
+	return Collection(members...)
+}
+
+//	Decodes the collections of media-col, media-col-ready or media-col-database
+func MediaCols(a attribute) []MediaCol {
+	var cols []MediaCol
+	for _, c := range a.Collections() {
+		cols = append(cols, newMediaCol(c))
+	}
+	return cols
+}
+
+func newMediaCol(c collection) MediaCol {
+	m := c.Map()
+	var mc MediaCol
+	for _, size := range m["media-size"].Collections() {
+		s := size.Map()
+		mc.Width, mc.MaxWidth = dimensionRange(s["x-dimension"])
+		mc.Length, mc.MaxLength = dimensionRange(s["y-dimension"])
+	}
+	mc.SizeName = m["media-size-name"].String()
+	mc.Type = m["media-type"].String()
+	mc.Source = m["media-source"].String()
+	mc.Color = m["media-color"].String()
+	top, hasTop := m["media-top-margin"]
+	bottom, hasBottom := m["media-bottom-margin"]
+	left, hasLeft := m["media-left-margin"]
+	right, hasRight := m["media-right-margin"]
+	if hasTop || hasBottom || hasLeft || hasRight {
+		mc.Margins = &MediaMargins{Top: top.Int(), Bottom: bottom.Int(), Left: left.Int(), Right: right.Int()}
+	}
+	return mc
+}
+
+//	Returns an integer dimension, or the bounds of a rangeOfInteger dimension
+func dimensionRange(a attribute) (int, int) {
+	if len(a.values) > 0 {
+		if r, ok := a.values[0].value.(rangeOfInteger); ok {
+			return int(r.lowerBound), int(r.upperBound)
+		}
+	}
+	return a.Int(), 0
+}
+
+//	Returns true for media without margins
+func (mc MediaCol) Borderless() bool {
+	return mc.Margins != nil && *mc.Margins == MediaMargins{}
+}
+
+//	Returns the PWG 5101.1 size of the media, a custom size if it is not a standard size
+func (mc MediaCol) Media() Media {
+	if m, err := ParseMedia(mc.SizeName); err == nil {
+		return m
+	}
+	return MediaForSize(mc.Width, mc.Length)
+}
+
+//	Returns the first entry of cols (e.g. media-col-ready) that matches want: the size within
+//	0.5 mm or within a custom size range, and every keyword and the margins want sets, e.g.
+//
+//	want := NewMediaCol(a4)
+//	want.Type, want.Source, want.Margins = "photographic-glossy", "tray-2", &MediaMargins{}
+//	mc, ok := SelectMediaCol(MediaCols(database), want)
+func SelectMediaCol(cols []MediaCol, want MediaCol) (MediaCol, bool) {
+	for _, mc := range cols {
+		if want.Width > 0 && !fits(want.Width, mc.Width, mc.MaxWidth) ||
+			want.Length > 0 && !fits(want.Length, mc.Length, mc.MaxLength) ||
+			want.SizeName != "" && want.SizeName != mc.SizeName ||
+			want.Type != "" && want.Type != mc.Type ||
+			want.Source != "" && want.Source != mc.Source ||
+			want.Color != "" && want.Color != mc.Color ||
+			want.Margins != nil && (mc.Margins == nil || *want.Margins != *mc.Margins) {
+			continue
+		}
+		return mc, true
+	}
+	return MediaCol{}, false
+}
+
+//	Returns true if v is within mediaEpsilon of size, or within the range size to max
+func fits(v, size, max int) bool {
+	if max > 0 {
+		return v >= size && v <= max
+	}
+	return abs(v-size) <= mediaEpsilon
+}
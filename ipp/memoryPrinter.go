@@ -0,0 +1,1166 @@
+package ipp
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//   Job life cycle (RFC 8011 section 5.3.7)
+//
+//                                                      +----> canceled
+//                                                     /
+//       +----> pending  -------> processing ---------+------> completed
+//       |         ^                   ^               \
+//   --->+         |                   |                +----> aborted
+//       |         v                   v               /
+//       +----> pending-held    processing-stopped ---+
+//
+//   A job is 'pending-held' while it waits for documents (Create-Job, job-state-reasons
+//   'job-incoming'), while "job-hold-until" is not 'no-hold' and after Hold-Job. It is
+//   'processing-stopped' while the Printer is stopped (Pause-Printer) during processing.
+//   Restart-Job moves a job in a terminal state (canceled, aborted, completed) back to 'pending'.
+
+//	JobDocument is a document received with Print-Job or Send-Document
+type JobDocument struct {
+	Number   int    // document-number, starting at 1
+	Name     string // document-name
+	Format   string // document-format
+	Detected string // document-format-detected, "" if the format was not detected
+	Data     []byte
+	Canceled bool // Cancel-Document, the document is not printed
+}
+
+//	Returns the format of the document data, the detected format of an
+//	'application/octet-stream' document
+func (d JobDocument) DataFormat() string {
+	if d.Format == "application/octet-stream" && d.Detected != "" {
+		return d.Detected
+	}
+	return d.Format
+}
+
+//	Job is a job of a MemoryPrinter
+type Job struct {
+	Id           int
+	Uri          string      // job-uri
+	Name         string      // job-name
+	User         string      // job-originating-user-name
+	State        int         // job-state, JOB_PENDING to JOB_COMPLETE
+	StateReasons []string    // job-state-reasons
+	StateMessage string      // job-state-message, why the output failed
+	Purged       bool        // the document data was removed, see MemoryPrinter.SetRetention
+	HoldUntil    string      // job-hold-until, "" or 'no-hold' when the job is not held
+	Attributes   []attribute // the job template attributes the client supplied, e.g. copies and sides
+	Documents    []JobDocument
+	Created      time.Time
+	Processing   time.Time
+	Completed    time.Time
+
+	incoming bool      // documents may still be sent
+	printed  bool      // processing finished while the Printer was stopped
+	err      error     // the output error of a printed job
+	retries  int       // how often the backend asked to print the job again
+	retryAt  time.Time // a retried job is not processed before retryAt
+}
+
+//	Returns true for the terminal job-states: canceled, aborted and completed
+func (j *Job) Done() bool {
+	return j.State == JOB_CANCELLED || j.State == JOB_ABORTED || j.State == JOB_COMPLETE
+}
+
+func (j *Job) setState(state int, reasons ...string) {
+	j.State = state
+	j.StateReasons = reasons
+}
+
+//	MemoryPrinter is a Printer that keeps its jobs in memory. Jobs are processed one at a
+//	time in the order they were submitted and handed to the OutputBackend, see SetBackend.
+//	Without a backend processing just moves them to 'completed' and the documents stay in the
+//	job history, which makes it usable as a fake printer in tests.
+type MemoryPrinter struct {
+	mu           sync.Mutex
+	name         string
+	uri          string
+	uuid         string // printer-uuid
+	started      time.Time
+	state        int
+	stateReasons []string
+	accepting    bool
+	formats      []string
+	attrs        []attribute
+	jobs         map[int]*Job
+	nextJobId    int
+	backend      OutputBackend
+	store        JobStore
+	keepHistory  time.Duration // preserve-job-history, 0 keeps jobs forever
+	keepFiles    time.Duration // preserve-job-files, 0 keeps documents forever
+	watchers     []func()
+	fetcher      *DocumentFetcher // fetches the documents of Print-URI and Send-URI
+	busy         bool             // process is running, the backend is in use
+}
+
+//	Returns an idle MemoryPrinter named name that is reachable at printerUri,
+//	e.g. NewMemoryPrinter("office", "ipp://localhost:8631/ipp/print")
+func NewMemoryPrinter(name, printerUri string) *MemoryPrinter {
+	return &MemoryPrinter{
+		name:         name,
+		uri:          printerUri,
+		uuid:         newUuid(),
+		started:      time.Now(),
+		state:        PRINTER_IDLE,
+		stateReasons: []string{"none"},
+		accepting:    true,
+		formats:      []string{"application/octet-stream", "application/pdf", "image/pwg-raster"},
+		jobs:         make(map[int]*Job),
+		nextJobId:    1,
+	}
+}
+
+//	Sets document-format-supported, the first format is the document-format-default
+func (p *MemoryPrinter) SetDocumentFormats(formats ...string) {
+	p.mu.Lock()
+	p.formats = formats
+	p.mu.Unlock()
+	p.changed()
+}
+
+//	Sets the OutputBackend that receives the documents of processing jobs, nil keeps them in memory only
+func (p *MemoryPrinter) SetBackend(b OutputBackend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backend = b
+}
+
+//	Persists the jobs in s and recovers the jobs s holds from an earlier run. Jobs that were
+//	processing when the printer stopped are printed again. New jobs get ids above the highest
+//	job-id s ever saved, including the ids of purged and expired jobs.
+func (p *MemoryPrinter) SetStore(s JobStore) error {
+	jobs, err := s.Load()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.store = s
+	if last := s.LastJobId(); last >= p.nextJobId {
+		p.nextJobId = last + 1
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		if j.State == JOB_PROCESSING || j.State == JOB_STOPPED {
+			j.setState(JOB_PENDING, "job-restartable")
+			j.Processing = time.Time{}
+			p.save(j)
+		}
+		p.jobs[j.Id] = j
+		if j.Id >= p.nextJobId {
+			p.nextJobId = j.Id + 1
+		}
+	}
+	p.expire()
+	p.schedule()
+	return nil
+}
+
+//	Sets how long jobs in a terminal state stay in the job history (preserve-job-history) and how
+//	long their documents are kept (preserve-job-files), 0 keeps them forever
+func (p *MemoryPrinter) SetRetention(history, files time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keepHistory, p.keepFiles = history, files
+	p.expire()
+}
+
+//	Sets the DocumentFetcher of Print-URI and Send-URI, nil (the default) disables them
+func (p *MemoryPrinter) SetFetcher(f *DocumentFetcher) {
+	p.mu.Lock()
+	p.fetcher = f
+	p.mu.Unlock()
+	p.changed()
+}
+
+//	Adds or replaces a printer description attribute, e.g. printer-make-and-model or sides-supported
+func (p *MemoryPrinter) SetAttribute(a attribute) {
+	p.mu.Lock()
+	replaced := false
+	for i, x := range p.attrs {
+		if x.Name() == a.Name() {
+			p.attrs[i] = a
+			replaced = true
+		}
+	}
+	if !replaced {
+		p.attrs = append(p.attrs, a)
+	}
+	p.mu.Unlock()
+	p.changed()
+}
+
+//	Calls fn after the printer description attributes changed, see SetAttribute and
+//	SetDocumentFormats
+func (p *MemoryPrinter) Watch(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.watchers = append(p.watchers, fn)
+}
+
+//	Calls the watchers, the caller does not hold p.mu
+func (p *MemoryPrinter) changed() {
+	p.mu.Lock()
+	watchers := p.watchers
+	p.mu.Unlock()
+	for _, fn := range watchers {
+		fn()
+	}
+}
+
+//	Returns printer-state, PRINTER_IDLE, PRINTER_PROCESSING or PRINTER_STOPPED
+func (p *MemoryPrinter) State() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state
+}
+
+//	Returns a copy of a job
+func (p *MemoryPrinter) Job(id int) (Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+//	Returns a copy of every job, including the completed ones, ordered by job-id
+func (p *MemoryPrinter) Jobs() []Job {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire()
+	var jobs []Job
+	for _, j := range p.sortedJobs() {
+		jobs = append(jobs, *j)
+	}
+	return jobs
+}
+
+//	Stops scheduling jobs, a job that is processing becomes 'processing-stopped'
+func (p *MemoryPrinter) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = PRINTER_STOPPED
+	p.stateReasons = []string{"paused"}
+	for _, j := range p.jobs {
+		if j.State == JOB_PROCESSING {
+			j.setState(JOB_STOPPED, "printer-stopped")
+			p.save(j)
+		}
+	}
+}
+
+//	Resumes scheduling jobs
+func (p *MemoryPrinter) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state = PRINTER_IDLE
+	if p.busy {
+		p.state = PRINTER_PROCESSING
+	}
+	p.stateReasons = []string{"none"}
+	for _, j := range p.jobs {
+		if j.State != JOB_STOPPED {
+			continue
+		}
+		if j.printed {
+			p.finish(j, j.err)
+		} else {
+			j.setState(JOB_PROCESSING, "job-printing")
+			p.state = PRINTER_PROCESSING
+			p.save(j)
+		}
+	}
+	p.schedule()
+}
+
+// ========== Document operations ==========
+
+func (p *MemoryPrinter) GetDocumentAttributes(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	d, err := document(j, r.DocumentNumber())
+	if err != nil {
+		return err
+	}
+	resp.AddGroup(TAG_DOCUMENT_ATTRIBUTES)
+	for _, a := range filterAttributes(p.docAttributes(j, d), r.RequestedAttributes()) {
+		resp.AppendGroupAttribute(TAG_DOCUMENT_ATTRIBUTES, a)
+	}
+	return nil
+}
+
+//	Get-Documents: one document-attributes group per document, ordered by document-number
+func (p *MemoryPrinter) GetDocuments(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	for i := range j.Documents {
+		ag := newAg(TAG_DOCUMENT_ATTRIBUTES)
+		ag.attributes = filterAttributes(p.docAttributes(j, &j.Documents[i]), r.RequestedAttributes())
+		resp.attributeGroups = append(resp.attributeGroups, ag)
+	}
+	return nil
+}
+
+//	Cancel-Document: a document can be canceled while its job is not processing yet, the job
+//	is canceled along with its last document
+func (p *MemoryPrinter) CancelDocument(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	d, err := document(j, r.DocumentNumber())
+	if err != nil {
+		return err
+	}
+	if d.Canceled || j.Done() || j.State == JOB_PROCESSING || j.State == JOB_STOPPED {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "document " + strconv.Itoa(d.Number) + " is already " + jobStateString(documentState(j, d))}
+	}
+	// the backend may hold a copy of the slice, see process
+	docs := append([]JobDocument(nil), j.Documents...)
+	docs[d.Number-1].Canceled = true
+	j.Documents = docs
+	if !j.incoming {
+		canceled := true
+		for _, doc := range j.Documents {
+			canceled = canceled && doc.Canceled
+		}
+		if canceled {
+			j.setState(JOB_CANCELLED, "job-canceled-by-user")
+			j.Completed = time.Now()
+			p.schedule()
+		}
+	}
+	p.save(j)
+	return nil
+}
+
+// ========== Printer operations ==========
+
+func (p *MemoryPrinter) PrintJob(r *Request, resp *Message) error {
+	if err := p.validateJob(r); err != nil {
+		return err
+	}
+	data, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j := p.newJob(r)
+	p.addDocument(j, r, data)
+	p.release(j)
+	if err := p.accept(j); err != nil {
+		return err
+	}
+	p.schedule()
+	p.addJobStatus(resp, j)
+	return nil
+}
+
+func (p *MemoryPrinter) ValidateJob(r *Request, resp *Message) error {
+	return p.validateJob(r)
+}
+
+func (p *MemoryPrinter) CreateJob(r *Request, resp *Message) error {
+	if err := p.validateJob(r); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j := p.newJob(r)
+	j.incoming = true
+	j.setState(JOB_HELD, "job-incoming")
+	if err := p.accept(j); err != nil {
+		return err
+	}
+	p.addJobStatus(resp, j)
+	return nil
+}
+
+func (p *MemoryPrinter) SendDocument(r *Request, resp *Message) error {
+	last, ok := r.Operation("last-document")
+	if !ok {
+		return &StatusError{Code: BAD_REQUEST, Message: "missing last-document"}
+	}
+	if err := p.checkFormat(r); err != nil {
+		return err
+	}
+	data, err := readDocument(r)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	if !j.incoming {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "job " + strconv.Itoa(j.Id) + " does not accept documents"}
+	}
+	if len(data) > 0 || !last.Bool() {
+		p.addDocument(j, r, data)
+	}
+	if last.Bool() {
+		j.incoming = false
+		p.release(j)
+	}
+	if err := p.save(j); err != nil {
+		return &StatusError{Code: INTERNAL_ERROR, Message: err.Error()}
+	}
+	p.schedule()
+	p.addJobStatus(resp, j)
+	return nil
+}
+
+func (p *MemoryPrinter) PrintUri(r *Request, resp *Message) error {
+	if err := p.validateJob(r); err != nil {
+		return err
+	}
+	if err := p.fetch(r); err != nil {
+		return err
+	}
+	return p.PrintJob(r, resp)
+}
+
+func (p *MemoryPrinter) SendUri(r *Request, resp *Message) error {
+	p.mu.Lock()
+	_, err := p.job(r)
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := p.fetch(r); err != nil {
+		return err
+	}
+	return p.SendDocument(r, resp)
+}
+
+//	Fetches the document-uri of a Print-URI or Send-URI request into r.Document
+func (p *MemoryPrinter) fetch(r *Request) error {
+	p.mu.Lock()
+	f := p.fetcher
+	p.mu.Unlock()
+	if f == nil {
+		return &StatusError{Code: OPERATION_NOT_SUPPORTED, Message: "Print-URI and Send-URI are not supported"}
+	}
+	return r.fetchDocument(f)
+}
+
+func (p *MemoryPrinter) CancelJob(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	if j.Done() {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "job " + strconv.Itoa(j.Id) + " is already " + jobStateString(j.State)}
+	}
+	// a processing job stays with the backend until process returns, which schedules the next job
+	j.incoming = false
+	j.setState(JOB_CANCELLED, "job-canceled-by-user")
+	j.Completed = time.Now()
+	p.save(j)
+	p.schedule()
+	return nil
+}
+
+func (p *MemoryPrinter) GetJobAttributes(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	resp.AddGroup(TAG_JOB)
+	for _, a := range filterAttributes(p.jobAttributes(j), r.RequestedAttributes()) {
+		resp.AppendGroupAttribute(TAG_JOB, a)
+	}
+	return nil
+}
+
+//	Get-Jobs: "which-jobs" 'not-completed' (the default), 'completed' or 'all', "my-jobs" and "limit".
+//	Without "requested-attributes" only job-id and job-uri are returned.
+func (p *MemoryPrinter) GetJobs(r *Request, resp *Message) error {
+	which := "not-completed"
+	if a, ok := r.Operation("which-jobs"); ok {
+		which = a.String()
+	}
+	if which != "not-completed" && which != "completed" && which != "all" {
+		resp.AddGroupAttribute(TAG_UNSUPPORTED_GROUP, TAG_KEYWORD, "which-jobs", keyword(which))
+		return &StatusError{Code: ATTRIBUTES, Message: "which-jobs " + which + " is not supported"}
+	}
+	myJobs, _ := r.Operation("my-jobs")
+	limit, _ := r.Operation("limit")
+	requested := r.RequestedAttributes()
+	if requested == nil {
+		requested = []string{"job-id", "job-uri"}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expire()
+	n := 0
+	for _, j := range p.sortedJobs() {
+		if which == "not-completed" && j.Done() || which == "completed" && !j.Done() {
+			continue
+		}
+		if myJobs.Bool() && j.User != r.User {
+			continue
+		}
+		if limit.Int() > 0 && n >= limit.Int() {
+			break
+		}
+		n++
+		resp.AddGroup(TAG_JOB)
+		for _, a := range filterAttributes(p.jobAttributes(j), requested) {
+			resp.AppendGroupAttribute(TAG_JOB, a)
+		}
+	}
+	return nil
+}
+
+func (p *MemoryPrinter) GetPrinterAttributes(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	resp.AddGroup(TAG_PRINTER)
+	for _, a := range filterAttributes(p.printerAttributes(), r.RequestedAttributes()) {
+		resp.AppendGroupAttribute(TAG_PRINTER, a)
+	}
+	return nil
+}
+
+//	Hold-Job: a 'pending' or 'pending-held' job is held until it is released
+func (p *MemoryPrinter) HoldJob(r *Request, resp *Message) error {
+	until := "indefinite"
+	if a, ok := r.Operation("job-hold-until"); ok {
+		until = a.String()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	if j.State != JOB_PENDING && j.State != JOB_HELD {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "job " + strconv.Itoa(j.Id) + " is " + jobStateString(j.State)}
+	}
+	j.HoldUntil = until
+	if !j.incoming {
+		p.release(j)
+	}
+	p.save(j)
+	p.schedule()
+	return nil
+}
+
+//	Release-Job: a held job becomes 'pending' again
+func (p *MemoryPrinter) ReleaseJob(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	if j.State != JOB_HELD || j.incoming {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "job " + strconv.Itoa(j.Id) + " is not held"}
+	}
+	j.HoldUntil = "no-hold"
+	p.release(j)
+	p.save(j)
+	p.schedule()
+	return nil
+}
+
+//	Restart-Job: a canceled, aborted or completed job is processed again
+func (p *MemoryPrinter) RestartJob(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, err := p.job(r)
+	if err != nil {
+		return err
+	}
+	if !j.Done() || len(j.Documents) == 0 || j.Purged {
+		return &StatusError{Code: NOT_POSSIBLE, Message: "job " + strconv.Itoa(j.Id) + " can not be restarted"}
+	}
+	if a, ok := r.Operation("job-hold-until"); ok {
+		j.HoldUntil = a.String()
+	}
+	j.Processing, j.Completed, j.printed, j.err, j.retries = time.Time{}, time.Time{}, false, nil, 0
+	j.StateMessage = ""
+	p.release(j)
+	p.save(j)
+	p.schedule()
+	return nil
+}
+
+// ========== Printer administration ==========
+
+func (p *MemoryPrinter) PausePrinter(r *Request, resp *Message) error {
+	p.Pause()
+	return nil
+}
+
+func (p *MemoryPrinter) ResumePrinter(r *Request, resp *Message) error {
+	p.Resume()
+	return nil
+}
+
+//	Purge-Jobs: removes every job, including the job history. A job that is processing stays
+//	with the backend until its output returns.
+func (p *MemoryPrinter) PurgeJobs(r *Request, resp *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.jobs {
+		p.delete(id)
+	}
+	return nil
+}
+
+// ========== scheduling ==========
+
+//	Checks that the printer accepts jobs and supports the document-format
+func (p *MemoryPrinter) validateJob(r *Request) error {
+	p.mu.Lock()
+	accepting := p.accepting
+	p.mu.Unlock()
+	if !accepting {
+		return &StatusError{Code: NOT_ACCEPTING}
+	}
+	return p.checkFormat(r)
+}
+
+//	Checks the document-format of the request, or the document-format-detected when it is
+//	'application/octet-stream'. A printer that supports no format but 'application/octet-stream'
+//	accepts every detected format.
+func (p *MemoryPrinter) checkFormat(r *Request) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	format := "application/octet-stream"
+	if len(p.formats) > 0 {
+		format = p.formats[0]
+	}
+	if a, ok := r.Operation("document-format"); ok {
+		if !p.supportsFormat(a.String()) {
+			return &StatusError{Code: DOCUMENT_FORMAT, Message: "document-format " + a.String() + " is not supported"}
+		}
+		format = a.String()
+	}
+	detected := r.FormatDetected
+	if format != "application/octet-stream" || detected == "" || detected == format || p.supportsFormat(detected) {
+		return nil
+	}
+	for _, f := range p.formats {
+		if f != "application/octet-stream" {
+			return &StatusError{Code: DOCUMENT_FORMAT, Message: "document-format-detected " + detected + " is not supported"}
+		}
+	}
+	return nil
+}
+
+//	Returns true if format is one of document-format-supported, the caller holds p.mu
+func (p *MemoryPrinter) supportsFormat(format string) bool {
+	for _, f := range p.formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+//	Reads the document data of a request, errors are DOCUMENT_ACCESS_ERROR unless they are
+//	*StatusError, e.g. COMPRESSION_ERROR
+func readDocument(r *Request) ([]byte, error) {
+	data, err := ioutil.ReadAll(r.Document)
+	if _, ok := err.(*StatusError); err != nil && !ok {
+		err = &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: err.Error()}
+	}
+	return data, err
+}
+
+//	Creates a job from the request, the caller holds p.mu
+func (p *MemoryPrinter) newJob(r *Request) *Job {
+	j := &Job{Id: p.nextJobId, User: r.User, Created: time.Now(), HoldUntil: "no-hold"}
+	p.nextJobId++
+	j.Uri = p.uri + "/" + strconv.Itoa(j.Id)
+	j.Name = "job-" + strconv.Itoa(j.Id)
+	if a, ok := r.Operation("job-name"); ok {
+		j.Name = a.String()
+	}
+	if ag, ok := r.Group(TAG_JOB); ok {
+		for _, a := range ag.attributes {
+			if a.Name() == "job-hold-until" {
+				j.HoldUntil = a.String()
+			}
+			j.Attributes = append(j.Attributes, a)
+		}
+	}
+	p.jobs[j.Id] = j
+	return j
+}
+
+func (p *MemoryPrinter) addDocument(j *Job, r *Request, data []byte) {
+	d := JobDocument{Number: len(j.Documents) + 1, Format: "application/octet-stream", Data: data}
+	if len(p.formats) > 0 {
+		d.Format = p.formats[0]
+	}
+	if a, ok := r.Operation("document-format"); ok {
+		d.Format = a.String()
+	}
+	if a, ok := r.Operation("document-name"); ok {
+		d.Name = a.String()
+	}
+	d.Detected = r.FormatDetected
+	j.Documents = append(j.Documents, d)
+}
+
+//	Looks up the target job of the request, the caller holds p.mu
+func (p *MemoryPrinter) job(r *Request) (*Job, error) {
+	p.expire()
+	id := r.JobId()
+	j, ok := p.jobs[id]
+	if !ok {
+		return nil, &StatusError{Code: NOT_FOUND, Message: "job " + strconv.Itoa(id) + " does not exist"}
+	}
+	return j, nil
+}
+
+//	Moves a job that has all of its documents to 'pending', or 'pending-held' if job-hold-until says so
+func (p *MemoryPrinter) release(j *Job) {
+	if j.HoldUntil != "" && j.HoldUntil != "no-hold" {
+		j.setState(JOB_HELD, "job-hold-until-specified")
+		return
+	}
+	j.setState(JOB_PENDING, "none")
+}
+
+//	Starts processing the oldest pending job when the printer is idle and the backend is not
+//	busy, the caller holds p.mu
+func (p *MemoryPrinter) schedule() {
+	if p.state != PRINTER_IDLE || p.busy {
+		return
+	}
+	now := time.Now()
+	for _, j := range p.sortedJobs() {
+		if j.State != JOB_PENDING || j.retryAt.After(now) {
+			continue
+		}
+		j.setState(JOB_PROCESSING, "job-printing")
+		j.Processing = time.Now()
+		p.state = PRINTER_PROCESSING
+		p.busy = true
+		p.save(j)
+		go p.process(j)
+		return
+	}
+}
+
+//	Sends the documents of a job to the backend outside of p.mu
+func (p *MemoryPrinter) process(j *Job) {
+	p.mu.Lock()
+	job, backend := *j, p.backend
+	p.mu.Unlock()
+	var err error
+	if backend != nil {
+		for _, d := range job.Documents {
+			if d.Canceled {
+				continue
+			}
+			if err = backend.Output(job, d); err != nil {
+				break
+			}
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.busy = false
+	switch {
+	case p.jobs[j.Id] != j:
+		// purged while processing
+	case j.State == JOB_PROCESSING:
+		p.finish(j, err)
+		return
+	case j.State == JOB_STOPPED:
+		j.printed, j.err = true, err
+		return
+	}
+	// canceled or purged while processing, the backend is free for the next job
+	if p.state == PRINTER_PROCESSING {
+		p.state = PRINTER_IDLE
+	}
+	p.schedule()
+}
+
+//	Moves a processed job to 'completed', or to 'aborted' or 'canceled' if its output failed.
+//	A job the backend asked to hold or to retry is 'pending-held' or 'pending' again.
+func (p *MemoryPrinter) finish(j *Job, err error) {
+	if p.state == PRINTER_PROCESSING {
+		p.state = PRINTER_IDLE
+	}
+	if err == nil {
+		j.setState(JOB_COMPLETE, "job-completed-successfully")
+		j.Completed = time.Now()
+		p.save(j)
+		p.schedule()
+		return
+	}
+	state, reason := backendState(err)
+	if state == JOB_PENDING {
+		if j.retries++; j.retries > backendRetryLimit {
+			state, reason = JOB_ABORTED, "aborted-by-system"
+		}
+	}
+	j.setState(state, reason)
+	j.StateMessage = err.Error()
+	switch {
+	case state == JOB_HELD:
+		j.HoldUntil = "indefinite"
+	case reason == "printer-stopped":
+		p.state = PRINTER_STOPPED
+		p.stateReasons = []string{"paused"}
+	case reason == "job-queued":
+		j.retryAt = time.Now().Add(backendRetryInterval)
+		time.AfterFunc(backendRetryInterval, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.schedule()
+		})
+	case state != JOB_PENDING:
+		j.Completed = time.Now()
+	}
+	p.save(j)
+	p.schedule()
+}
+
+//	Saves a new job, a job the store can not hold is dropped and the operation fails
+func (p *MemoryPrinter) accept(j *Job) error {
+	if err := p.save(j); err != nil {
+		delete(p.jobs, j.Id)
+		return &StatusError{Code: INTERNAL_ERROR, Message: err.Error()}
+	}
+	return nil
+}
+
+//	Saves the state of a job in the JobStore, the caller holds p.mu
+func (p *MemoryPrinter) save(j *Job) error {
+	if p.store == nil {
+		return nil
+	}
+	err := p.store.Save(*j)
+	if err != nil {
+		log.Println("ipp: saving job", j.Id, err)
+	}
+	return err
+}
+
+//	Removes a job from the job table and the JobStore, the caller holds p.mu
+func (p *MemoryPrinter) delete(id int) {
+	delete(p.jobs, id)
+	if p.store == nil {
+		return
+	}
+	if err := p.store.Delete(id); err != nil {
+		log.Println("ipp: deleting job", id, err)
+	}
+}
+
+//	Applies the retention policy: removes the documents and then the jobs that completed longer
+//	ago than preserve-job-files and preserve-job-history, the caller holds p.mu
+func (p *MemoryPrinter) expire() {
+	now := time.Now()
+	for id, j := range p.jobs {
+		if !j.Done() || j.Completed.IsZero() {
+			continue
+		}
+		age := now.Sub(j.Completed)
+		if p.keepHistory > 0 && age > p.keepHistory {
+			p.delete(id)
+			continue
+		}
+		if p.keepFiles > 0 && age > p.keepFiles && !j.Purged {
+			j.Purged = true
+			docs := make([]JobDocument, len(j.Documents))
+			for i, d := range j.Documents {
+				d.Data = nil
+				docs[i] = d
+			}
+			j.Documents = docs
+			p.save(j)
+		}
+	}
+}
+
+func (p *MemoryPrinter) sortedJobs() []*Job {
+	var jobs []*Job
+	for _, j := range p.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Id < jobs[b].Id })
+	return jobs
+}
+
+// ========== attributes ==========
+
+//	Adds the job-attributes group Print-Job, Create-Job and Send-Document respond with
+func (p *MemoryPrinter) addJobStatus(resp *Message, j *Job) {
+	resp.AddGroup(TAG_JOB)
+	resp.AddGroupAttribute(TAG_JOB, TAG_URI, "job-uri", uri(j.Uri))
+	resp.AddGroupAttribute(TAG_JOB, TAG_INTEGER, "job-id", integer(j.Id))
+	resp.AddGroupAttribute(TAG_JOB, TAG_ENUM, "job-state", enum(j.State))
+	resp.AppendGroupAttribute(TAG_JOB, keywords("job-state-reasons", j.StateReasons))
+}
+
+//	Returns the seconds since the printer started, the unit of printer-up-time and time-at-*
+func (p *MemoryPrinter) upTime(t time.Time) int {
+	return int(t.Sub(p.started)/time.Second) + 1
+}
+
+func (p *MemoryPrinter) jobAttributes(j *Job) []attribute {
+	attrs := []attribute{
+		newAttribute(TAG_URI, "job-uri", uri(j.Uri)),
+		newAttribute(TAG_INTEGER, "job-id", integer(j.Id)),
+		newAttribute(TAG_URI, "job-printer-uri", uri(p.uri)),
+		newAttribute(TAG_NAME, "job-name", nameWithoutLanguage(j.Name)),
+		newAttribute(TAG_NAME, "job-originating-user-name", nameWithoutLanguage(j.User)),
+		newAttribute(TAG_ENUM, "job-state", enum(j.State)),
+		keywords("job-state-reasons", j.StateReasons),
+		newAttribute(TAG_INTEGER, "job-printer-up-time", integer(p.upTime(time.Now()))),
+		newAttribute(TAG_INTEGER, "number-of-documents", integer(len(j.Documents))),
+		newAttribute(TAG_INTEGER, "time-at-creation", integer(p.upTime(j.Created))),
+		timeAt("time-at-processing", p, j.Processing),
+		timeAt("time-at-completed", p, j.Completed),
+	}
+	if j.StateMessage != "" {
+		attrs = append(attrs, newAttribute(TAG_TEXT, "job-state-message", textWithoutLanguage(j.StateMessage)))
+	}
+	if len(j.Documents) > 0 {
+		attrs = append(attrs, newAttribute(TAG_MIMETYPE, "document-format", mimeMediaType(j.Documents[0].Format)))
+		if d := j.Documents[0]; d.Detected != "" {
+			attrs = append(attrs, newAttribute(TAG_MIMETYPE, "document-format-detected", mimeMediaType(d.Detected)))
+		}
+	}
+	return append(attrs, j.Attributes...)
+}
+
+//	Returns the document document-number of a job
+func document(j *Job, number int) (*JobDocument, error) {
+	if number < 1 || number > len(j.Documents) {
+		return nil, &StatusError{Code: NOT_FOUND, Message: "document " + strconv.Itoa(number) + " of job " + strconv.Itoa(j.Id) + " does not exist"}
+	}
+	return &j.Documents[number-1], nil
+}
+
+//	A document follows the state of its job unless it was canceled, a 'pending-held' job has
+//	'pending' documents
+func documentState(j *Job, d *JobDocument) int {
+	switch {
+	case d.Canceled:
+		return DOCUMENT_CANCELLED
+	case j.State == JOB_HELD:
+		return DOCUMENT_PENDING
+	}
+	return j.State
+}
+
+func (p *MemoryPrinter) docAttributes(j *Job, d *JobDocument) []attribute {
+	state := documentState(j, d)
+	reasons := []string{"canceled-by-user"}
+	if !d.Canceled {
+		reasons = nil
+		for _, reason := range j.StateReasons {
+			reasons = append(reasons, strings.TrimPrefix(reason, "job-"))
+		}
+	}
+	attrs := []attribute{
+		newAttribute(TAG_INTEGER, "document-number", integer(d.Number)),
+		newAttribute(TAG_INTEGER, "document-job-id", integer(j.Id)),
+		newAttribute(TAG_URI, "document-job-uri", uri(j.Uri)),
+		newAttribute(TAG_URI, "document-printer-uri", uri(p.uri)),
+		newAttribute(TAG_MIMETYPE, "document-format", mimeMediaType(d.Format)),
+		newAttribute(TAG_ENUM, "document-state", enum(state)),
+		keywords("document-state-reasons", reasons),
+		newAttribute(TAG_INTEGER, "k-octets", integer((len(d.Data)+1023)/1024)),
+		newAttribute(TAG_INTEGER, "printer-up-time", integer(p.upTime(time.Now()))),
+		newAttribute(TAG_INTEGER, "time-at-creation", integer(p.upTime(j.Created))),
+		timeAt("time-at-processing", p, j.Processing),
+		timeAt("time-at-completed", p, j.Completed),
+	}
+	if d.Name != "" {
+		attrs = append(attrs, newAttribute(TAG_NAME, "document-name", nameWithoutLanguage(d.Name)))
+	}
+	if d.Detected != "" {
+		attrs = append(attrs, newAttribute(TAG_MIMETYPE, "document-format-detected", mimeMediaType(d.Detected)))
+	}
+	if j.StateMessage != "" && !d.Canceled {
+		attrs = append(attrs, newAttribute(TAG_TEXT, "document-state-message", textWithoutLanguage(j.StateMessage)))
+	}
+	return attrs
+}
+
+//	Returns time-at-processing or time-at-completed, 'no-value' while the job has not got there
+func timeAt(name string, p *MemoryPrinter, t time.Time) attribute {
+	if t.IsZero() {
+		return newAttribute(TAG_NOVALUE, name, nil)
+	}
+	return newAttribute(TAG_INTEGER, name, integer(p.upTime(t)))
+}
+
+func (p *MemoryPrinter) printerAttributes() []attribute {
+	queued := 0
+	for _, j := range p.jobs {
+		if !j.Done() {
+			queued++
+		}
+	}
+	ops := NewAttribute()
+	for _, op := range []uint16{PRINT_JOB, VALIDATE_JOB, CREATE_JOB, SEND_DOCUMENT, CANCEL_JOB,
+		GET_JOB_ATTRIBUTES, GET_JOBS, GET_PRINTER_ATTRIBUTES, HOLD_JOB, RELEASE_JOB, RESTART_JOB,
+		PAUSE_PRINTER, RESUME_PRINTER, PURGE_JOBS, CANCEL_DOCUMENT, GET_DOCUMENT_ATTRIBUTES, GET_DOCUMENTS} {
+		ops.AddValue(TAG_ENUM, "operations-supported", enum(op))
+	}
+	if p.fetcher != nil {
+		ops.AddValue(TAG_ENUM, "operations-supported", enum(PRINT_URI))
+		ops.AddValue(TAG_ENUM, "operations-supported", enum(SEND_URI))
+	}
+	defaultFormat := "application/octet-stream"
+	if len(p.formats) > 0 {
+		defaultFormat = p.formats[0]
+	}
+	attrs := []attribute{
+		newAttribute(TAG_URI, "printer-uri-supported", uri(p.uri)),
+		newAttribute(TAG_KEYWORD, "uri-security-supported", keyword("none")),
+		newAttribute(TAG_KEYWORD, "uri-authentication-supported", keyword("requesting-user-name")),
+		newAttribute(TAG_NAME, "printer-name", nameWithoutLanguage(p.name)),
+		newAttribute(TAG_URI, "printer-uuid", uri(p.uuid)),
+		newAttribute(TAG_ENUM, "printer-state", enum(p.state)),
+		keywords("printer-state-reasons", p.stateReasons),
+		newAttribute(TAG_BOOLEAN, "printer-is-accepting-jobs", Boolean(p.accepting)),
+		newAttribute(TAG_INTEGER, "queued-job-count", integer(queued)),
+		newAttribute(TAG_INTEGER, "printer-up-time", integer(p.upTime(time.Now()))),
+		newAttribute(TAG_KEYWORD, "ipp-versions-supported", keyword("1.1"), keyword("2.0")),
+		ops,
+		newAttribute(TAG_CHARSET, "charset-configured", charset("utf-8")),
+		newAttribute(TAG_CHARSET, "charset-supported", charset("utf-8")),
+		newAttribute(TAG_LANGUAGE, "natural-language-configured", naturalLanguage("en")),
+		newAttribute(TAG_LANGUAGE, "generated-natural-language-supported", naturalLanguage("en")),
+		newAttribute(TAG_MIMETYPE, "document-format-default", mimeMediaType(defaultFormat)),
+		newAttribute(TAG_KEYWORD, "pdl-override-supported", keyword("not-attempted")),
+		keywords("compression-supported", compressionSupported),
+		newAttribute(TAG_KEYWORD, "job-hold-until-supported", keyword("no-hold"), keyword("indefinite")),
+	}
+	formats := NewAttribute()
+	for _, f := range p.formats {
+		formats.AddValue(TAG_MIMETYPE, "document-format-supported", mimeMediaType(f))
+	}
+	attrs = append(attrs, formats)
+	if p.fetcher != nil && len(p.fetcher.Schemes) > 0 {
+		schemes := NewAttribute()
+		for _, s := range p.fetcher.Schemes {
+			schemes.AddValue(TAG_URISCHEME, "reference-uri-schemes-supported", uriScheme(s))
+		}
+		attrs = append(attrs, schemes)
+	}
+	// attributes set with SetAttribute replace those above
+	set := make(map[string]bool)
+	for _, a := range p.attrs {
+		set[a.Name()] = true
+	}
+	var merged []attribute
+	for _, a := range attrs {
+		if !set[a.Name()] {
+			merged = append(merged, a)
+		}
+	}
+	return append(merged, p.attrs...)
+}
+
+//	Returns a random (version 4) UUID as a urn:uuid: uri
+func newUuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+//	Returns a keyword attribute, 'none' if there are no values
+func keywords(name string, values []string) attribute {
+	if len(values) == 0 {
+		values = []string{"none"}
+	}
+	a := NewAttribute()
+	for _, v := range values {
+		a.AddValue(TAG_KEYWORD, name, keyword(v))
+	}
+	return a
+}
+
+//	Returns the attributes named in requested, all of them if requested is empty or holds one of
+//	the group names 'all', 'job-template', 'job-description' or 'printer-description'
+func filterAttributes(attrs []attribute, requested []string) []attribute {
+	if len(requested) == 0 {
+		return attrs
+	}
+	want := make(map[string]bool)
+	for _, r := range requested {
+		switch r {
+		case "all", "job-template", "job-description", "printer-description", "document-description", "document-template":
+			return attrs
+		}
+		want[r] = true
+	}
+	var filtered []attribute
+	for _, a := range attrs {
+		if want[a.Name()] {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+//	Returns the keyword of a job-state, e.g. "pending-held"
+func jobStateString(state int) string {
+	switch state {
+	case JOB_PENDING:
+		return "pending"
+	case JOB_HELD:
+		return "pending-held"
+	case JOB_PROCESSING:
+		return "processing"
+	case JOB_STOPPED:
+		return "processing-stopped"
+	case JOB_CANCELLED:
+		return "canceled"
+	case JOB_ABORTED:
+		return "aborted"
+	case JOB_COMPLETE:
+		return "completed"
+	}
+	return strconv.Itoa(state)
+}
@@ -0,0 +1,432 @@
+package ipp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//   Names of operations, status codes and tags
+//
+//   Operations, status codes and value and group tags are written by name in ipptool test files
+//   and in the output of the CUPS tools: operations as in RFC 8011 ("Get-Printer-Attributes"),
+//   status codes as their keyword ("client-error-not-found"), tags as the attribute syntax
+//   ("rangeOfInteger") or the delimiter ("printer-attributes-tag"). Values are written as text:
+//
+//      integer, enum       123, 0x0b (enums also by keyword, e.g. "completed", see ParseEnum)
+//      boolean             true, false
+//      rangeOfInteger      1-100
+//      resolution          600dpi, 600x300dpi, 118dpcm
+//      dateTime            2006-01-02T15:04:05Z07:00 (RFC 3339)
+//      octetString         the octets, or <hex digits>
+//      the string types    the string
+
+//	The names of the operations
+var operationNames = map[uint16]string{
+	PRINT_JOB:                            "Print-Job",
+	PRINT_URI:                            "Print-URI",
+	VALIDATE_JOB:                         "Validate-Job",
+	CREATE_JOB:                           "Create-Job",
+	SEND_DOCUMENT:                        "Send-Document",
+	SEND_URI:                             "Send-URI",
+	CANCEL_JOB:                           "Cancel-Job",
+	GET_JOB_ATTRIBUTES:                   "Get-Job-Attributes",
+	GET_JOBS:                             "Get-Jobs",
+	GET_PRINTER_ATTRIBUTES:               "Get-Printer-Attributes",
+	HOLD_JOB:                             "Hold-Job",
+	RELEASE_JOB:                          "Release-Job",
+	RESTART_JOB:                          "Restart-Job",
+	PAUSE_PRINTER:                        "Pause-Printer",
+	RESUME_PRINTER:                       "Resume-Printer",
+	PURGE_JOBS:                           "Purge-Jobs",
+	SET_PRINTER_ATTRIBUTES:               "Set-Printer-Attributes",
+	SET_JOB_ATTRIBUTES:                   "Set-Job-Attributes",
+	GET_PRINTER_SUPPORTED_VALUES:         "Get-Printer-Supported-Values",
+	CREATE_PRINTER_SUBSCRIPTION:          "Create-Printer-Subscriptions",
+	CREATE_JOB_SUBSCRIPTION:              "Create-Job-Subscriptions",
+	GET_SUBSCRIPTION_ATTRIBUTES:          "Get-Subscription-Attributes",
+	GET_SUBSCRIPTIONS:                    "Get-Subscriptions",
+	RENEW_SUBSCRIPTION:                   "Renew-Subscription",
+	CANCEL_SUBSCRIPTION:                  "Cancel-Subscription",
+	GET_NOTIFICATIONS:                    "Get-Notifications",
+	SEND_NOTIFICATIONS:                   "Send-Notifications",
+	GET_PRINT_SUPPORT_FILES:              "Get-Print-Support-Files",
+	ENABLE_PRINTER:                       "Enable-Printer",
+	DISABLE_PRINTER:                      "Disable-Printer",
+	PAUSE_PRINTER_AFTER_CURRENT_JOB:      "Pause-Printer-After-Current-Job",
+	HOLD_NEW_JOBS:                        "Hold-New-Jobs",
+	RELEASE_HELD_NEW_JOBS:                "Release-Held-New-Jobs",
+	DEACTIVATE_PRINTER:                   "Deactivate-Printer",
+	ACTIVATE_PRINTER:                     "Activate-Printer",
+	RESTART_PRINTER:                      "Restart-Printer",
+	SHUTDOWN_PRINTER:                     "Shutdown-Printer",
+	STARTUP_PRINTER:                      "Startup-Printer",
+	REPROCESS_JOB:                        "Reprocess-Job",
+	CANCEL_CURRENT_JOB:                   "Cancel-Current-Job",
+	SUSPEND_CURRENT_JOB:                  "Suspend-Current-Job",
+	RESUME_JOB:                           "Resume-Job",
+	PROMOTE_JOB:                          "Promote-Job",
+	SCHEDULE_JOB_AFTER:                   "Schedule-Job-After",
+	CANCEL_DOCUMENT:                      "Cancel-Document",
+	GET_DOCUMENT_ATTRIBUTES:              "Get-Document-Attributes",
+	GET_DOCUMENTS:                        "Get-Documents",
+	DELETE_DOCUMENT:                      "Delete-Document",
+	SET_DOCUMENT_ATTRIBUTES:              "Set-Document-Attributes",
+	CANCEL_JOBS:                          "Cancel-Jobs",
+	CANCEL_MY_JOBS:                       "Cancel-My-Jobs",
+	RESUBMIT_JOB:                         "Resubmit-Job",
+	CLOSE_JOB:                            "Close-Job",
+	IDENTIFY_PRINTER:                     "Identify-Printer",
+	ACKNOWLEDGE_DOCUMENT:                 "Acknowledge-Document",
+	ACKNOWLEDGE_IDENTIFY_PRINTER:         "Acknowledge-Identify-Printer",
+	ACKNOWLEDGE_JOB:                      "Acknowledge-Job",
+	FETCH_DOCUMENT:                       "Fetch-Document",
+	FETCH_JOB:                            "Fetch-Job",
+	GET_OUTPUT_DEVICE_ATTRIBUTES:         "Get-Output-Device-Attributes",
+	UPDATE_ACTIVE_JOBS:                   "Update-Active-Jobs",
+	DEREGISTER_OUTPUT_DEVICE:             "Deregister-Output-Device",
+	UPDATE_DOCUMENT_STATUS:               "Update-Document-Status",
+	UPDATE_JOB_STATUS:                    "Update-Job-Status",
+	UPDATE_OUTPUT_DEVICE_ATTRIBUTES:      "Update-Output-Device-Attributes",
+	ALLOCATE_PRINTER_RESOURCES:           "Allocate-Printer-Resources",
+	CREATE_PRINTER:                       "Create-Printer",
+	DEALLOCATE_PRINTER_RESOURCES:         "Deallocate-Printer-Resources",
+	DELETE_PRINTER:                       "Delete-Printer",
+	GET_PRINTERS:                         "Get-Printers",
+	SHUTDOWN_ONE_PRINTER:                 "Shutdown-One-Printer",
+	STARTUP_ONE_PRINTER:                  "Startup-One-Printer",
+	CANCEL_RESOURCE:                      "Cancel-Resource",
+	CREATE_RESOURCE:                      "Create-Resource",
+	INSTALL_RESOURCE:                     "Install-Resource",
+	SEND_RESOURCE_DATA:                   "Send-Resource-Data",
+	SET_RESOURCE_ATTRIBUTES:              "Set-Resource-Attributes",
+	CREATE_RESOURCE_SUBSCRIPTIONS:        "Create-Resource-Subscriptions",
+	CREATE_SYSTEM_SUBSCRIPTIONS:          "Create-System-Subscriptions",
+	DISABLE_ALL_PRINTERS:                 "Disable-All-Printers",
+	ENABLE_ALL_PRINTERS:                  "Enable-All-Printers",
+	GET_SYSTEM_ATTRIBUTES:                "Get-System-Attributes",
+	GET_SYSTEM_SUPPORTED_VALUES:          "Get-System-Supported-Values",
+	PAUSE_ALL_PRINTERS:                   "Pause-All-Printers",
+	PAUSE_ALL_PRINTERS_AFTER_CURRENT_JOB: "Pause-All-Printers-After-Current-Job",
+	REGISTER_OUTPUT_DEVICE:               "Register-Output-Device",
+	RESTART_SYSTEM:                       "Restart-System",
+	RESUME_ALL_PRINTERS:                  "Resume-All-Printers",
+	SET_SYSTEM_ATTRIBUTES:                "Set-System-Attributes",
+	SHUTDOWN_ALL_PRINTERS:                "Shutdown-All-Printers",
+	STARTUP_ALL_PRINTERS:                 "Startup-All-Printers",
+	CUPS_GET_DEFAULT:                     "CUPS-Get-Default",
+	CUPS_GET_PRINTERS:                    "CUPS-Get-Printers",
+	CUPS_ADD_PRINTER:                     "CUPS-Add-Modify-Printer",
+	CUPS_DELETE_PRINTER:                  "CUPS-Delete-Printer",
+	CUPS_GET_CLASSES:                     "CUPS-Get-Classes",
+	CUPS_ADD_CLASS:                       "CUPS-Add-Modify-Class",
+	CUPS_DELETE_CLASS:                    "CUPS-Delete-Class",
+	CUPS_ACCEPT_JOBS:                     "CUPS-Accept-Jobs",
+	CUPS_REJECT_JOBS:                     "CUPS-Reject-Jobs",
+	CUPS_SET_DEFAULT:                     "CUPS-Set-Default",
+	CUPS_GET_DEVICES:                     "CUPS-Get-Devices",
+	CUPS_GET_PPDS:                        "CUPS-Get-PPDs",
+	CUPS_MOVE_JOB:                        "CUPS-Move-Job",
+	CUPS_AUTHENTICATE_JOB:                "CUPS-Authenticate-Job",
+}
+
+//	Returns the name of an operation-id, e.g. "Get-Printer-Attributes" for 0x000b
+func OperationName(id uint16) string {
+	if s, ok := operationNames[id]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%04x", id)
+}
+
+//	Returns the operation-id of an operation name, case is ignored, or of a number like "0x000b"
+func ParseOperation(name string) (uint16, bool) {
+	for id, s := range operationNames {
+		if strings.EqualFold(s, name) {
+			return id, true
+		}
+	}
+	n, err := strconv.ParseUint(name, 0, 16)
+	return uint16(n), err == nil
+}
+
+//	Returns the status-code of a keyword, e.g. 0x0406 for "client-error-not-found", or of a
+//	number like "0x0406"
+func ParseStatusCode(name string) (uint16, bool) {
+	for code, s := range statusCodeStrings {
+		if strings.EqualFold(s, name) {
+			return code, true
+		}
+	}
+	n, err := strconv.ParseUint(name, 0, 16)
+	return uint16(n), err == nil
+}
+
+//	The keywords of the enum attributes, the -default, -supported and -ready attributes share
+//	the values of theirs
+var enumNames = map[string]map[int]string{
+	"job-state": {
+		JOB_PENDING:    "pending",
+		JOB_HELD:       "pending-held",
+		JOB_PROCESSING: "processing",
+		JOB_STOPPED:    "processing-stopped",
+		JOB_CANCELLED:  "canceled",
+		JOB_ABORTED:    "aborted",
+		JOB_COMPLETE:   "completed",
+	},
+	"document-state": {
+		DOCUMENT_PENDING:    "pending",
+		DOCUMENT_PROCESSING: "processing",
+		DOCUMENT_STOPPED:    "processing-stopped",
+		DOCUMENT_CANCELLED:  "canceled",
+		DOCUMENT_ABORTED:    "aborted",
+		DOCUMENT_COMPLETE:   "completed",
+	},
+	"printer-state": {
+		PRINTER_IDLE:       "idle",
+		PRINTER_PROCESSING: "processing",
+		PRINTER_STOPPED:    "stopped",
+	},
+	"orientation-requested": {
+		PORTRAIT:          "portrait",
+		LANDSCAPE:         "landscape",
+		REVERSE_LANDSCAPE: "reverse-landscape",
+		REVERSE_PORTRAIT:  "reverse-portrait",
+	},
+	"print-quality": {
+		QUALITY_DRAFT:  "draft",
+		QUALITY_NORMAL: "normal",
+		QUALITY_HIGH:   "high",
+	},
+	"finishings": {
+		FINISHINGS_NONE:                "none",
+		FINISHINGS_STAPLE:              "staple",
+		FINISHINGS_PUNCH:               "punch",
+		FINISHINGS_COVER:               "cover",
+		FINISHINGS_BIND:                "bind",
+		FINISHINGS_SADDLE_STITCH:       "saddle-stitch",
+		FINISHINGS_EDGE_STITCH:         "edge-stitch",
+		FINISHINGS_FOLD:                "fold",
+		FINISHINGS_TRIM:                "trim",
+		FINISHINGS_BALE:                "bale",
+		FINISHINGS_BOOKLET_MAKER:       "booklet-maker",
+		FINISHINGS_JOB_OFFSET:          "jog-offset",
+		FINISHINGS_STAPLE_TOP_LEFT:     "staple-top-left",
+		FINISHINGS_STAPLE_BOTTOM_LEFT:  "staple-bottom-left",
+		FINISHINGS_STAPLE_TOP_RIGHT:    "staple-top-right",
+		FINISHINGS_STAPLE_BOTTOM_RIGHT: "staple-bottom-right",
+		FINISHINGS_EDGE_STITCH_LEFT:    "edge-stitch-left",
+		FINISHINGS_EDGE_STITCH_TOP:     "edge-stitch-top",
+		FINISHINGS_EDGE_STITCH_RIGHT:   "edge-stitch-right",
+		FINISHINGS_EDGE_STITCH_BOTTOM:  "edge-stitch-bottom",
+		FINISHINGS_STAPLE_DUAL_LEFT:    "staple-dual-left",
+		FINISHINGS_STAPLE_DUAL_TOP:     "staple-dual-top",
+		FINISHINGS_STAPLE_DUAL_RIGHT:   "staple-dual-right",
+		FINISHINGS_STAPLE_DUAL_BOTTOM:  "staple-dual-bottom",
+		FINISHINGS_BIND_LEFT:           "bind-left",
+		FINISHINGS_BIND_TOP:            "bind-top",
+		FINISHINGS_BIND_RIGHT:          "bind-right",
+		FINISHINGS_BIND_BOTTO:          "bind-bottom",
+	},
+}
+
+//	Returns the value of an enum keyword of the attribute name, e.g. 9 for job-state
+//	"completed" or 4 for finishings-supported "staple", case is ignored. The values of
+//	operations-supported are operation names, see ParseOperation.
+func ParseEnum(name, keyword string) (int, bool) {
+	for _, suffix := range []string{"-default", "-supported", "-ready"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	if name == "operations" {
+		op, ok := ParseOperation(keyword)
+		return int(op), ok
+	}
+	for n, s := range enumNames[name] {
+		if strings.EqualFold(s, keyword) {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+//	The names of the delimiter and value tags
+var tagNames = map[byte]string{
+	TAG_ZERO:                "zero",
+	TAG_OPERATION:           "operation-attributes-tag",
+	TAG_JOB:                 "job-attributes-tag",
+	TAG_END:                 "end-of-attributes-tag",
+	TAG_PRINTER:             "printer-attributes-tag",
+	TAG_UNSUPPORTED_GROUP:   "unsupported-attributes-tag",
+	TAG_SUBSCRIPTION:        "subscription-attributes-tag",
+	TAG_EVENT_NOTIFICATION:  "event-notification-attributes-tag",
+	TAG_DOCUMENT_ATTRIBUTES: "document-attributes-tag",
+	TAG_SYSTEM:              "system-attributes-tag",
+	TAG_UNSUPPORTED_VALUE:   "unsupported",
+	TAG_DEFAULT:             "default",
+	TAG_UNKNOWN:             "unknown",
+	TAG_NOVALUE:             "no-value",
+	TAG_NOTSETTABLE:         "not-settable",
+	TAG_DELETEATTR:          "delete-attribute",
+	TAG_ADMINDEFINE:         "admin-define",
+	TAG_INTEGER:             "integer",
+	TAG_BOOLEAN:             "boolean",
+	TAG_ENUM:                "enum",
+	TAG_STRING:              "octetString",
+	TAG_DATE:                "dateTime",
+	TAG_RESOLUTION:          "resolution",
+	TAG_RANGE:               "rangeOfInteger",
+	TAG_BEGIN_COLLECTION:    "collection",
+	TAG_TEXTLANG:            "textWithLanguage",
+	TAG_NAMELANG:            "nameWithLanguage",
+	TAG_END_COLLECTION:      "endCollection",
+	TAG_TEXT:                "textWithoutLanguage",
+	TAG_NAME:                "nameWithoutLanguage",
+	TAG_KEYWORD:             "keyword",
+	TAG_URI:                 "uri",
+	TAG_URISCHEME:           "uriScheme",
+	TAG_CHARSET:             "charset",
+	TAG_LANGUAGE:            "naturalLanguage",
+	TAG_MIMETYPE:            "mimeMediaType",
+	TAG_MEMBERNAME:          "memberAttrName",
+}
+
+//	The short names ipptool accepts besides those of tagNames
+var tagAliases = map[string]byte{
+	"operation":     TAG_OPERATION,
+	"job":           TAG_JOB,
+	"printer":       TAG_PRINTER,
+	"unsupported":   TAG_UNSUPPORTED_GROUP,
+	"subscription":  TAG_SUBSCRIPTION,
+	"event":         TAG_EVENT_NOTIFICATION,
+	"document":      TAG_DOCUMENT_ATTRIBUTES,
+	"system":        TAG_SYSTEM,
+	"text":          TAG_TEXT,
+	"name":          TAG_NAME,
+	"language":      TAG_LANGUAGE,
+	"mimetype":      TAG_MIMETYPE,
+	"begCollection": TAG_BEGIN_COLLECTION,
+}
+
+//	Returns the name of a tag, e.g. "keyword" for TAG_KEYWORD
+func TagName(tag byte) string {
+	if s, ok := tagNames[tag]; ok {
+		return s
+	}
+	return fmt.Sprintf("0x%02x", tag)
+}
+
+//	Returns the tag of a name, e.g. TAG_PRINTER for "printer-attributes-tag" or "printer".
+//	"unsupported" is the unsupported-attributes-tag, the out-of-band value is TAG_UNSUPPORTED_VALUE.
+func ParseTag(name string) (byte, bool) {
+	for tag, s := range tagNames {
+		if strings.EqualFold(s, name) && tag != TAG_UNSUPPORTED_VALUE {
+			return tag, true
+		}
+	}
+	for s, tag := range tagAliases {
+		if strings.EqualFold(s, name) {
+			return tag, true
+		}
+	}
+	return 0, false
+}
+
+//	Returns the value of tag written as text (see above), to be added with AddValue. The
+//	out-of-band tags have no value, collections are made with Collection.
+func ParseValue(tag byte, s string) (interface{}, error) {
+	switch tag {
+	case TAG_UNSUPPORTED_VALUE, TAG_DEFAULT, TAG_UNKNOWN, TAG_NOVALUE, TAG_NOTSETTABLE, TAG_DELETEATTR, TAG_ADMINDEFINE:
+		return nil, nil
+	case TAG_INTEGER:
+		n, err := strconv.ParseInt(s, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("ipp: invalid integer %q", s)
+		}
+		return integer(n), nil
+	case TAG_ENUM:
+		if n, err := strconv.ParseInt(s, 0, 32); err == nil {
+			return enum(n), nil
+		}
+		if op, ok := ParseOperation(s); ok {
+			return enum(op), nil
+		}
+		return nil, fmt.Errorf("ipp: invalid enum %q", s)
+	case TAG_BOOLEAN:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("ipp: invalid boolean %q", s)
+		}
+		return Boolean(b), nil
+	case TAG_RANGE:
+		// the lower bound may be negative, the '-' between the bounds follows its first digit
+		i := 0
+		if s != "" {
+			i = strings.Index(s[1:], "-") + 1
+		}
+		if i < 1 {
+			return nil, fmt.Errorf("ipp: invalid rangeOfInteger %q", s)
+		}
+		lower, err1 := strconv.Atoi(s[:i])
+		upper, err2 := strconv.Atoi(s[i+1:])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("ipp: invalid rangeOfInteger %q", s)
+		}
+		return RangeOfInteger(lower, upper), nil
+	case TAG_RESOLUTION:
+		return parseResolution(s)
+	case TAG_DATE:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("ipp: invalid dateTime %q", s)
+		}
+		return DateTime(t)
+	case TAG_STRING:
+		if strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") {
+			b, err := hex.DecodeString(s[1 : len(s)-1])
+			if err != nil {
+				return nil, fmt.Errorf("ipp: invalid octetString %q", s)
+			}
+			return octets(b), nil
+		}
+		return octets(s), nil
+	case TAG_TEXT:
+		return textWithoutLanguage(s), nil
+	case TAG_NAME:
+		return nameWithoutLanguage(s), nil
+	case TAG_KEYWORD:
+		return keyword(s), nil
+	case TAG_URI:
+		return uri(s), nil
+	case TAG_URISCHEME:
+		return uriScheme(s), nil
+	case TAG_CHARSET:
+		return charset(s), nil
+	case TAG_LANGUAGE:
+		return naturalLanguage(s), nil
+	case TAG_MIMETYPE:
+		return mimeMediaType(s), nil
+	case TAG_MEMBERNAME:
+		return memberName(s), nil
+	}
+	return nil, fmt.Errorf("ipp: values of %s cannot be written as text", TagName(tag))
+}
+
+//	Parses a resolution like "600dpi", "600x300dpi" or "118dpcm"
+func parseResolution(s string) (resolution, error) {
+	units := int8(RES_PER_INCH)
+	digits := strings.TrimSuffix(s, "dpi")
+	if strings.HasSuffix(s, "dpcm") {
+		units, digits = RES_PER_CM, strings.TrimSuffix(s, "dpcm")
+	}
+	xy := strings.SplitN(digits, "x", 2)
+	x, err := strconv.Atoi(xy[0])
+	y := x
+	if err == nil && len(xy) == 2 {
+		y, err = strconv.Atoi(xy[1])
+	}
+	if err != nil || digits == s {
+		return resolution{}, fmt.Errorf("ipp: invalid resolution %q", s)
+	}
+	return NewResolution(x, y, units)
+}
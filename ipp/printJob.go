@@ -0,0 +1,145 @@
+package ipp
+
+import (
+	"io"
+)
+
+//   Print-Job (RFC 8011 section 4.2.1)
+//
+//   The request carries the document data after the end-of-attributes-tag. Its operation
+//   attributes are, after the target and requesting-user-name, job-name, ipp-attribute-fidelity,
+//   document-name, compression, document-format, document-natural-language and the job size
+//   attributes; the job template attributes (copies, sides, media-col, ...) are sent in the
+//   job-attributes group.
+
+//	The operation attributes callers of PrintJob may pass along with the job template attributes
+var jobOperationAttributes = map[string]bool{
+	"job-name":                  true,
+	"ipp-attribute-fidelity":    true,
+	"document-name":             true,
+	"compression":               true,
+	"document-format":           true,
+	"document-natural-language": true,
+	"job-k-octets":              true,
+	"job-impressions":           true,
+	"job-media-sheets":          true,
+	"job-password":              true,
+	"job-password-encryption":   true,
+}
+
+//	Print-Job: prints doc on printer (see PrinterUri) and returns the job-id. attrs are job
+//	template attributes, e.g. copies:
+//
+//	copies := NewAttribute()
+//	copies.AddValue(TAG_INTEGER, "copies", Integer(2))
+//
+//	and the operation attributes above, e.g. job-name or those of JobPassword. Unless attrs has
+//	document-format, it is detected from the first bytes of doc (see SniffFormat). Unless attrs
+//	has compression, doc is compressed if the printer supports it (see SetCompression). doc is
+//	streamed to the host and path of the printer-uri, it is not read into memory.
+func (c *CupsServer) PrintJob(printer string, doc io.Reader, attrs ...attribute) (int, error) {
+	printerUri := c.PrinterUri(printer)
+	m := c.newRequest(PRINT_JOB, printerUri, 0)
+	compression := ""
+	for _, a := range attrs {
+		if a.Name() == "compression" {
+			compression = a.String()
+		}
+	}
+	if compression == "" {
+		compression = c.documentCompression(printerUri)
+	}
+	format, doc, err := SniffFormat(doc)
+	if err != nil {
+		return 0, err
+	}
+	addJobAttributes(&m, attrs, compression, format)
+	z, err := compressor(doc, compression)
+	if err != nil {
+		return 0, err
+	}
+	defer z.Close()
+	m.document = z
+	r, err := c.doPrinterRequest(m, printerUri)
+	if err != nil {
+		return 0, err
+	}
+	a, _ := r.Attribute(TAG_JOB, "job-id")
+	return a.Int(), nil
+}
+
+//	Create-Job: creates a job on printer (see PrinterUri) that gets its documents with
+//	SendDocument or SendUri, and returns the job-id. attrs are those of PrintJob. The job is
+//	printed once a document with last-document true was sent or it is closed with CloseJob.
+//	The request is sent to the host and path of the printer-uri.
+func (c *CupsServer) CreateJob(printer string, attrs ...attribute) (int, error) {
+	printerUri := c.PrinterUri(printer)
+	m := c.newRequest(CREATE_JOB, printerUri, 0)
+	addJobAttributes(&m, attrs, "", "")
+	r, err := c.doPrinterRequest(m, printerUri)
+	if err != nil {
+		return 0, err
+	}
+	a, _ := r.Attribute(TAG_JOB, "job-id")
+	return a.Int(), nil
+}
+
+//	Send-Document: adds doc to a job created with CreateJob, last closes the job. attrs are
+//	operation attributes, e.g. document-name. document-format is detected like PrintJob does
+//	unless attrs has it, doc is compressed only if attrs has compression. doc is streamed like
+//	that of PrintJob.
+func (c *CupsServer) SendDocument(jobId int, doc io.Reader, last bool, attrs ...attribute) error {
+	m := c.newRequest(SEND_DOCUMENT, "", jobId)
+	m.AddAttribute(TAG_BOOLEAN, "last-document", Boolean(last))
+	compression := ""
+	for _, a := range attrs {
+		if a.Name() == "compression" {
+			compression = a.String()
+		}
+	}
+	format, doc, err := SniffFormat(doc)
+	if err != nil {
+		return err
+	}
+	addJobAttributes(&m, attrs, "", format)
+	z, err := compressor(doc, compression)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+	m.document = z
+	_, err = c.doRequest(m, "/jobs/")
+	return err
+}
+
+//	Adds the operation attributes of attrs, compression unless attrs has it or it is 'none' and
+//	document-format unless attrs has it or format is "", to the operation group and the other
+//	attributes to the job-attributes group
+func addJobAttributes(m *Message, attrs []attribute, compression, format string) {
+	var template []attribute
+	for _, a := range attrs {
+		if !jobOperationAttributes[a.Name()] {
+			template = append(template, a)
+			continue
+		}
+		switch a.Name() {
+		case "compression":
+			compression = ""
+		case "document-format":
+			format = ""
+		}
+		m.AppendAttribute(a)
+	}
+	if compression != "" && compression != "none" {
+		m.AddAttribute(TAG_KEYWORD, "compression", keyword(compression))
+	}
+	if format != "" {
+		m.AddAttribute(TAG_MIMETYPE, "document-format", mimeMediaType(format))
+	}
+	if len(template) > 0 {
+		m.AddGroup(TAG_JOB)
+		for _, a := range template {
+			m.AppendGroupAttribute(TAG_JOB, a)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package ipp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//   Print-URI and Send-URI (RFC 8011 sections 4.2.2 and 4.3.2)
+//
+//   Instead of the document data the client supplies the "document-uri" operation attribute,
+//   the Printer fetches the document itself. The uri schemes it can fetch are listed in
+//   "reference-uri-schemes-supported". A uri with another scheme is rejected with
+//   client-error-uri-scheme-not-supported, a document that cannot be fetched (it does not
+//   exist, access is denied, it is too large) with client-error-document-access-error.
+//   Send-URI adds the document to a job created with Create-Job, like Send-Document.
+//
+//   As fetching lets anyone who can print make the Printer read uris on their behalf, the
+//   DocumentFetcher only reads uris below the prefixes it is given.
+
+//	The largest document a DocumentFetcher reads unless MaxSize is set
+const DefaultMaxDocumentSize = 64 << 20
+
+//	DocumentFetcher retrieves the documents of Print-URI and Send-URI requests over http, https
+//	and file uris, see MemoryPrinter.SetFetcher
+type DocumentFetcher struct {
+	Schemes []string     // reference-uri-schemes-supported
+	Allow   []string     // the uri prefixes documents may be fetched from, nothing else is
+	MaxSize int64        // the largest document in bytes, 0 is DefaultMaxDocumentSize
+	Client  *http.Client // the client of http and https uris, nil uses one with a timeout of a minute
+}
+
+//	Returns a DocumentFetcher that reads the uris below the prefixes in allow and supports their
+//	schemes, e.g. NewDocumentFetcher("https://intranet.example.com/docs/", "file:///srv/print/")
+func NewDocumentFetcher(allow ...string) *DocumentFetcher {
+	f := &DocumentFetcher{Allow: allow}
+	for _, a := range allow {
+		u, err := url.Parse(a)
+		if err != nil || u.Scheme == "" {
+			continue
+		}
+		known := false
+		for _, s := range f.Schemes {
+			known = known || s == u.Scheme
+		}
+		if !known {
+			f.Schemes = append(f.Schemes, u.Scheme)
+		}
+	}
+	return f
+}
+
+//	Returns the document at documentUri. A scheme not in Schemes is URI_SCHEME, a uri that is
+//	not allowed or cannot be read and a document larger than MaxSize are DOCUMENT_ACCESS_ERROR.
+func (f *DocumentFetcher) Fetch(documentUri string) ([]byte, error) {
+	u, err := url.Parse(documentUri)
+	if err != nil || u.Scheme == "" {
+		return nil, &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: "document-uri " + documentUri + " is not a uri"}
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	supported := false
+	for _, s := range f.Schemes {
+		supported = supported || strings.EqualFold(s, u.Scheme)
+	}
+	if !supported || u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file" {
+		return nil, &StatusError{Code: URI_SCHEME, Message: "uri scheme " + u.Scheme + " is not supported"}
+	}
+	if !f.allowed(u) {
+		return nil, &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: "document-uri " + documentUri + " is not allowed"}
+	}
+	var body io.ReadCloser
+	if u.Scheme == "file" {
+		body, err = f.open(u)
+	} else {
+		body, err = f.get(u)
+	}
+	if err != nil {
+		if _, ok := err.(*StatusError); !ok {
+			err = &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: err.Error()}
+		}
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(body, f.maxSize()+1))
+	if err != nil {
+		return nil, &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: err.Error()}
+	}
+	if int64(len(data)) > f.maxSize() {
+		return nil, f.tooLarge(documentUri)
+	}
+	return data, nil
+}
+
+func (f *DocumentFetcher) maxSize() int64 {
+	if f.MaxSize > 0 {
+		return f.MaxSize
+	}
+	return DefaultMaxDocumentSize
+}
+
+func (f *DocumentFetcher) tooLarge(documentUri string) error {
+	return &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: "document " + documentUri + " is larger than " + strconv.FormatInt(f.maxSize(), 10) + " bytes"}
+}
+
+//	Returns true if u is below one of the Allow prefixes, paths are compared after removing
+//	"." and ".." elements and only at '/' boundaries. The path of a file prefix also matches
+//	with its symlinks resolved, file://localhost/ and file:/// are the same.
+func (f *DocumentFetcher) allowed(u *url.URL) bool {
+	if u.User != nil || u.Opaque != "" {
+		return false
+	}
+	p := path.Clean("/" + u.Path)
+	for _, s := range f.Allow {
+		a, err := url.Parse(s)
+		if err != nil || !strings.EqualFold(a.Scheme, u.Scheme) || !strings.EqualFold(fileHost(a), fileHost(u)) {
+			continue
+		}
+		prefixes := []string{path.Clean("/" + a.Path)}
+		if strings.EqualFold(a.Scheme, "file") {
+			// open checks the path with its symlinks resolved, e.g. /tmp may be /private/tmp
+			if dir, err := filepath.EvalSymlinks(filepath.FromSlash(prefixes[0])); err == nil {
+				prefixes = append(prefixes, filepath.ToSlash(dir))
+			}
+		}
+		for _, prefix := range prefixes {
+			if p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//	Returns the host of u, "" for the local host of a file uri
+func fileHost(u *url.URL) string {
+	if strings.EqualFold(u.Scheme, "file") && strings.EqualFold(u.Host, "localhost") {
+		return ""
+	}
+	return u.Host
+}
+
+//	Opens a file uri, file://localhost/path and file:///path are local files. The path with its
+//	symlinks resolved must be allowed too, a link below an Allow prefix may point anywhere.
+func (f *DocumentFetcher) open(u *url.URL) (io.ReadCloser, error) {
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, errors.New("document-uri " + u.String() + " is not a local file")
+	}
+	name, err := filepath.EvalSymlinks(filepath.FromSlash(path.Clean(u.Path)))
+	if err != nil {
+		return nil, err
+	}
+	resolved := *u
+	resolved.Path = filepath.ToSlash(name)
+	if !f.allowed(&resolved) {
+		return nil, &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: "document-uri " + u.String() + " is not allowed"}
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := file.Stat(); err != nil || !fi.Mode().IsRegular() {
+		file.Close()
+		return nil, errors.New("document-uri " + u.String() + " is not a regular file")
+	} else if fi.Size() > f.maxSize() {
+		file.Close()
+		return nil, f.tooLarge(u.String())
+	}
+	return file, nil
+}
+
+//	Gets an http or https uri, redirects are followed only to allowed uris
+func (f *DocumentFetcher) get(u *url.URL) (io.ReadCloser, error) {
+	client := http.Client{Timeout: time.Minute}
+	if f.Client != nil {
+		client = *f.Client
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !f.allowed(req.URL) {
+			return errors.New("redirect to " + req.URL.String() + " is not allowed")
+		}
+		return nil
+	}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.New("GET " + u.String() + ": " + resp.Status)
+	}
+	if resp.ContentLength > f.maxSize() {
+		resp.Body.Close()
+		return nil, f.tooLarge(u.String())
+	}
+	return resp.Body, nil
+}
+
+//	Replaces Document with the document at the "document-uri" of a Print-URI or Send-URI request,
+//	decompressed and with FormatDetected set like the document data of Print-Job
+func (r *Request) fetchDocument(f *DocumentFetcher) error {
+	a, ok := r.Operation("document-uri")
+	if !ok {
+		return &StatusError{Code: BAD_REQUEST, Message: "missing document-uri"}
+	}
+	data, err := f.Fetch(a.String())
+	if err != nil {
+		return err
+	}
+	compression := "none"
+	if c, ok := r.Operation("compression"); ok {
+		compression = c.String()
+	}
+	return r.setDocument(bytes.NewReader(data), compression, f.maxSize())
+}
+
+//	UriPrinter is implemented by Printers that support Print-URI and Send-URI, NewServer
+//	registers the operations when available
+type UriPrinter interface {
+	PrintUri(r *Request, resp *Message) error
+	SendUri(r *Request, resp *Message) error
+}
+
+//	Print-URI: prints the document at documentUri, which the printer fetches, and returns the
+//	job-id. attrs are those of PrintJob, document-format is not detected.
+func (c *CupsServer) PrintUri(printer, documentUri string, attrs ...attribute) (int, error) {
+	m := c.newRequest(PRINT_URI, c.PrinterUri(printer), 0)
+	m.AddAttribute(TAG_URI, "document-uri", uri(documentUri))
+	addJobAttributes(&m, attrs, "", "")
+	r, err := c.doRequest(m, "/")
+	if err != nil {
+		return 0, err
+	}
+	a, _ := r.Attribute(TAG_JOB, "job-id")
+	return a.Int(), nil
+}
+
+//	Send-URI: adds the document at documentUri to a job created with CreateJob, last closes the
+//	job. attrs are operation attributes, e.g. document-name or document-format.
+func (c *CupsServer) SendUri(jobId int, documentUri string, last bool, attrs ...attribute) error {
+	m := c.newRequest(SEND_URI, "", jobId)
+	m.AddAttribute(TAG_URI, "document-uri", uri(documentUri))
+	m.AddAttribute(TAG_BOOLEAN, "last-document", Boolean(last))
+	for _, a := range attrs {
+		m.AppendAttribute(a)
+	}
+	_, err := c.doRequest(m, "/jobs/")
+	return err
+}
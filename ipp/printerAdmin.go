@@ -0,0 +1,87 @@
+package ipp
+
+import (
+
+)
+
+//   The Printer administration operations are:
+//
+//      Pause-Printer (RFC 2911 section 3.2.7)
+//      Resume-Printer (RFC 2911 section 3.2.8)
+//      Purge-Jobs (RFC 2911 section 3.2.9)
+//      Enable-Printer (RFC 3998 section 3.1.1)
+//      Disable-Printer (RFC 3998 section 3.1.2)
+//      Pause-Printer-After-Current-Job (RFC 3998 section 3.2.1)
+//      Hold-New-Jobs (RFC 3998 section 3.3.1)
+//      Release-Held-New-Jobs (RFC 3998 section 3.3.2)
+//
+//   The target of each operation is the Printer object identified by "printer-uri".
+//   The client OPTIONALLY supplies "printer-message-from-operator" (text(127)), the
+//   Printer sets the Printer attribute of the same name to the supplied value.
+
+//	PrinterAdmin sends the administrative operations for one queue (or class) of a CupsServer.
+type PrinterAdmin struct {
+	server  *CupsServer
+	printer string // printer-uri
+}
+
+//	Returns the PrinterAdmin of a queue name (see PrinterUri) or of a printer-uri
+func (c *CupsServer) PrinterAdmin(printer string) PrinterAdmin {
+	return PrinterAdmin{server: c, printer: c.PrinterUri(printer)}
+}
+
+//	Returns the printer-uri the operations are sent to
+func (p *PrinterAdmin) PrinterUri() string {
+	return p.printer
+}
+
+//	Pause-Printer: stops the Printer from scheduling jobs, the job that is processing is stopped
+//	as soon as possible. New jobs are still accepted.
+func (p *PrinterAdmin) Pause(message string) error {
+	return p.do(PAUSE_PRINTER, message)
+}
+
+//	Resume-Printer: the Printer resumes scheduling jobs
+func (p *PrinterAdmin) Resume(message string) error {
+	return p.do(RESUME_PRINTER, message)
+}
+
+//	Purge-Jobs: removes all jobs of the Printer, no matter what their job-state is
+func (p *PrinterAdmin) Purge(message string) error {
+	return p.do(PURGE_JOBS, message)
+}
+
+//	Enable-Printer: the Printer accepts new jobs again
+func (p *PrinterAdmin) Enable(message string) error {
+	return p.do(ENABLE_PRINTER, message)
+}
+
+//	Disable-Printer: the Printer rejects new jobs, jobs already accepted are still processed
+func (p *PrinterAdmin) Disable(message string) error {
+	return p.do(DISABLE_PRINTER, message)
+}
+
+//	Pause-Printer-After-Current-Job: the Printer finishes the job that is processing and then pauses
+func (p *PrinterAdmin) PauseAfterCurrentJob(message string) error {
+	return p.do(PAUSE_PRINTER_AFTER_CURRENT_JOB, message)
+}
+
+//	Hold-New-Jobs: new jobs are accepted but put in the 'pending-held' state
+func (p *PrinterAdmin) HoldNewJobs(message string) error {
+	return p.do(HOLD_NEW_JOBS, message)
+}
+
+//	Release-Held-New-Jobs: releases the jobs held by Hold-New-Jobs and stops holding new jobs
+func (p *PrinterAdmin) ReleaseHeldNewJobs(message string) error {
+	return p.do(RELEASE_HELD_NEW_JOBS, message)
+}
+
+//	Sends operation to /admin/, a message of "" leaves printer-message-from-operator out
+func (p *PrinterAdmin) do(operationId uint16, message string) error {
+	m := p.server.newRequest(operationId, p.printer, 0)
+	if message != "" {
+		m.AddAttribute(TAG_TEXT, "printer-message-from-operator", textWithoutLanguage(message))
+	}
+	_, err := p.server.doRequest(m, "/admin/")
+	return err
+}
@@ -0,0 +1,498 @@
+package ipp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//   A Proxy is an IPP gateway: clients send their requests to the front-end printer-uri of the
+//   Proxy, which forwards them to one of its back-end printers and relays the responses.
+//
+//   Requests are rewritten on the way in:
+//
+//      - "printer-uri" (and any other uri value) naming the front-end printer names the back-end,
+//      - "job-id" and "job-uri" of the front-end job name the back-end job.
+//
+//   Responses are rewritten on the way out:
+//
+//      - back-end job-ids become front-end job-ids, job-uris become <front-end printer-uri>/<job-id>,
+//      - uri values naming the back-end printer (e.g. printer-uri-supported, job-printer-uri)
+//        name the front-end printer.
+//
+//   Document data follows the request attributes and is streamed to the back-end as it arrives.
+//
+//   With more than one back-end printer the Proxy balances the job creating operations
+//   (Print-Job, Print-URI, Create-Job) round robin over the healthy back-ends, Validate-Job goes
+//   to the back-end the next job would go to. Job operations go to the back-end that holds the
+//   job, Get-Jobs asks every healthy back-end and merges the job groups up to the "limit", all
+//   other operations go to the first healthy back-end.
+//   A back-end is unhealthy when a request to it fails or when a health check (Get-Printer-Attributes)
+//   finds it unreachable or not accepting jobs, see CheckHealth. It is healthy again when a
+//   request to it succeeds, an unhealthy back-end is checked again every upstreamRetryInterval.
+//
+//   The Proxy forgets a front-end job-id when the back-end no longer knows the job: a job
+//   operation for it fails with client-error-not-found, or the job was canceled, aborted or
+//   completed and a Get-Jobs for all or the completed jobs without a "limit" does not list it.
+
+//	Proxy is an http.Handler forwarding IPP requests for one front-end printer to back-end printers
+type Proxy struct {
+	Client *http.Client // the client used to reach the back-ends, nil for http.DefaultClient
+
+	mu        sync.Mutex
+	front     string
+	upstreams []*upstream
+	next      int // the next upstream of the round robin
+	jobs      map[int]*proxyJob
+	backJobs  map[backJob]int // back-end job to front-end job-id
+	nextJobId int
+}
+
+//	How often a Proxy checks an unhealthy back-end again when requests come in
+const upstreamRetryInterval = 30 * time.Second
+
+//	A back-end printer of a Proxy
+type upstream struct {
+	uri     string
+	healthy bool
+	checked time.Time // when the back-end was last found unhealthy or checked again
+}
+
+//	A job as the back-end knows it
+type proxyJob struct {
+	up     *upstream
+	backId int
+	uri    string // the back-end job-uri
+	done   bool   // the last job-state seen was canceled, aborted or completed
+}
+
+type backJob struct {
+	up *upstream
+	id int
+}
+
+//	Returns a Proxy that accepts requests for the printer frontUri and forwards them to the
+//	printers backendUris, e.g. NewProxy("ipp://gateway/printers/office", "ipp://10.0.0.12/ipp/print")
+func NewProxy(frontUri string, backendUris ...string) *Proxy {
+	p := &Proxy{
+		front:     frontUri,
+		jobs:      make(map[int]*proxyJob),
+		backJobs:  make(map[backJob]int),
+		nextJobId: 1,
+	}
+	for _, u := range backendUris {
+		p.upstreams = append(p.upstreams, &upstream{uri: u, healthy: true})
+	}
+	return p
+}
+
+//	Returns the printer-uris of the back-ends that are currently healthy
+func (p *Proxy) Healthy() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var uris []string
+	for _, up := range p.upstreams {
+		if up.healthy {
+			uris = append(uris, up.uri)
+		}
+	}
+	return uris
+}
+
+//	Sends Get-Printer-Attributes to every back-end, a back-end is healthy when it answers and
+//	its printer-is-accepting-jobs is true
+func (p *Proxy) CheckHealth() {
+	p.mu.Lock()
+	ups := append([]*upstream{}, p.upstreams...)
+	p.mu.Unlock()
+	for _, up := range ups {
+		p.check(up)
+	}
+}
+
+func (p *Proxy) check(up *upstream) {
+	m := NewRequest(GET_PRINTER_ATTRIBUTES)
+	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en"))
+	m.AddAttribute(TAG_URI, "printer-uri", uri(up.uri))
+	m.AppendAttribute(requestedAttributes([]string{"printer-is-accepting-jobs", "printer-state"}))
+	r, data, err := p.forward(up, m, nil)
+	if err == nil {
+		data.Close()
+	}
+	accepting, _ := r.Attribute(TAG_PRINTER, "printer-is-accepting-jobs")
+	p.mu.Lock()
+	p.setHealthy(up, err == nil && accepting.Bool())
+	p.mu.Unlock()
+}
+
+//	The caller holds p.mu
+func (p *Proxy) setHealthy(up *upstream, healthy bool) {
+	up.healthy = healthy
+	if !healthy {
+		up.checked = time.Now()
+	}
+}
+
+//	Returns the healthy back-ends and starts checking the unhealthy ones that were not checked
+//	for upstreamRetryInterval, the caller holds p.mu
+func (p *Proxy) healthy() []*upstream {
+	var ups []*upstream
+	for _, up := range p.upstreams {
+		if up.healthy {
+			ups = append(ups, up)
+		} else if time.Since(up.checked) >= upstreamRetryInterval {
+			up.checked = time.Now()
+			go p.check(up)
+		}
+	}
+	return ups
+}
+
+//	Runs CheckHealth every interval until stop is called
+func (p *Proxy) StartHealthChecks(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			p.CheckHealth()
+			select {
+			case <-t.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, hr *http.Request) {
+	if hr.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "IPP requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	decoded, err := contentDecoder(hr.Body, hr.Header.Get("Content-Encoding"), DefaultMaxRequestSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	body := bufio.NewReader(decoded)
+	m, err := ReadMessage(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ipp")
+
+	if m.OperationId() == GET_JOBS {
+		resp := p.getJobs(m)
+		w.Write(marshalResponse(&m, resp))
+		return
+	}
+	up, id, err := p.route(&m)
+	if err != nil {
+		resp := newResponseTo(&m)
+		setStatusError(&resp, err)
+		w.Write(marshalResponse(&m, resp))
+		return
+	}
+	resp, data, err := p.forward(up, m, body)
+	if err != nil {
+		resp = newResponseTo(&m)
+		setStatusError(&resp, err)
+		w.Write(marshalResponse(&m, resp))
+		return
+	}
+	defer data.Close()
+	if id != 0 && resp.StatusCode() == NOT_FOUND {
+		p.forget(id)
+	}
+	p.rewriteResponse(up, &resp)
+	w.Write(marshalResponse(&m, resp))
+	io.Copy(w, data)
+}
+
+//	Picks the back-end of a request and rewrites the request for it, id is the front-end
+//	job-id of a job operation
+func (p *Proxy) route(m *Message) (up *upstream, id int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var job *proxyJob
+	if a, ok := m.Attribute(TAG_OPERATION, "job-uri"); ok {
+		s := a.String()
+		id, _ = strconv.Atoi(s[strings.LastIndex(s, "/")+1:])
+		if job, ok = p.jobs[id]; !ok {
+			return nil, 0, &StatusError{Code: NOT_FOUND, Message: "job " + s + " does not exist"}
+		}
+	} else if a, ok := m.Attribute(TAG_OPERATION, "job-id"); ok {
+		id = a.Int()
+		if job, ok = p.jobs[id]; !ok {
+			return nil, 0, &StatusError{Code: NOT_FOUND, Message: "job " + strconv.Itoa(id) + " does not exist"}
+		}
+	}
+	healthy := p.healthy()
+	switch {
+	case job != nil:
+		up = job.up
+	case m.OperationId() == PRINT_JOB || m.OperationId() == PRINT_URI ||
+		m.OperationId() == CREATE_JOB || m.OperationId() == VALIDATE_JOB:
+		for i := range p.upstreams {
+			u := p.upstreams[(p.next+i)%len(p.upstreams)]
+			if u.healthy {
+				up = u
+				// Validate-Job checks the back-end of the next job without taking its turn
+				if m.OperationId() != VALIDATE_JOB {
+					p.next = (p.next + i + 1) % len(p.upstreams)
+				}
+				break
+			}
+		}
+	case len(healthy) > 0:
+		up = healthy[0]
+	}
+	if up == nil {
+		return nil, 0, &StatusError{Code: SERVICE_UNAVAILABLE, Message: "no back-end printer is available"}
+	}
+
+	for _, ag := range m.attributeGroups {
+		for i := range ag.attributes {
+			a := &ag.attributes[i]
+			switch {
+			case job != nil && a.Name() == "job-id" && ag.beginAttributeGroupTag == TAG_OPERATION:
+				a.setValue(0, TAG_INTEGER, integer(job.backId))
+			case job != nil && a.Name() == "job-uri" && ag.beginAttributeGroupTag == TAG_OPERATION:
+				a.setValue(0, TAG_URI, uri(job.uri))
+			default:
+				rewriteUris(a, p.front, up.uri)
+			}
+		}
+	}
+	return up, id, nil
+}
+
+//	Sends the request attributes followed by the document data to a back-end and reads the
+//	response attributes, data is the response data that follows them
+func (p *Proxy) forward(up *upstream, m Message, document io.Reader) (resp Message, data io.ReadCloser, err error) {
+	m.Data = nil
+	b, err := m.marshallMsg()
+	if err != nil {
+		return resp, nil, err
+	}
+	var body io.Reader = bytes.NewReader(b)
+	if document != nil {
+		body = io.MultiReader(body, document)
+	}
+	req, err := http.NewRequest("POST", httpUrl(up.uri), body)
+	if err != nil {
+		return resp, nil, err
+	}
+	req.Header.Set("Content-Type", "application/ipp")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	hr, err := client.Do(req)
+	p.mu.Lock()
+	p.setHealthy(up, err == nil)
+	p.mu.Unlock()
+	if err != nil {
+		return resp, nil, &StatusError{Code: SERVICE_UNAVAILABLE, Message: err.Error()}
+	}
+	if hr.StatusCode != http.StatusOK {
+		hr.Body.Close()
+		return resp, nil, &StatusError{Code: DEVICE_ERROR, Message: "http " + hr.Status + " from " + up.uri}
+	}
+	br := bufio.NewReader(hr.Body)
+	resp, err = ReadMessage(br)
+	if err != nil {
+		hr.Body.Close()
+		return resp, nil, &StatusError{Code: DEVICE_ERROR, Message: err.Error()}
+	}
+	return resp, struct {
+		io.Reader
+		io.Closer
+	}{br, hr.Body}, nil
+}
+
+//	Get-Jobs: asks every healthy back-end and returns their job groups in one response
+func (p *Proxy) getJobs(m Message) Message {
+	p.mu.Lock()
+	ups := p.healthy()
+	p.mu.Unlock()
+	var merged Message
+	var err error = &StatusError{Code: SERVICE_UNAVAILABLE, Message: "no back-end printer is available"}
+	found := false
+	for _, up := range ups {
+		req := m
+		req.attributeGroups = copyGroups(m.attributeGroups)
+		for _, ag := range req.attributeGroups {
+			for i := range ag.attributes {
+				rewriteUris(&ag.attributes[i], p.front, up.uri)
+			}
+		}
+		resp, data, ferr := p.forward(up, req, nil)
+		if ferr != nil {
+			err = ferr
+			continue
+		}
+		data.Close()
+		if listsDoneJobs(m) && resp.StatusCode() < 0x100 {
+			p.forgetDone(up, resp)
+		}
+		p.rewriteResponse(up, &resp)
+		if !found {
+			merged, found = resp, true
+			continue
+		}
+		merged.attributeGroups = append(merged.attributeGroups, resp.GroupsOf(TAG_JOB)...)
+	}
+	if !found {
+		merged = newResponseTo(&m)
+		setStatusError(&merged, err)
+		return merged
+	}
+	if a, ok := m.Attribute(TAG_OPERATION, "limit"); ok && a.Int() > 0 {
+		var groups []attributeGroup
+		n := 0
+		for _, ag := range merged.attributeGroups {
+			if ag.beginAttributeGroupTag == TAG_JOB {
+				if n == a.Int() {
+					continue
+				}
+				n++
+			}
+			groups = append(groups, ag)
+		}
+		merged.attributeGroups = groups
+	}
+	return merged
+}
+
+//	Returns true for a Get-Jobs whose response lists every job of the back-end in a terminal state
+func listsDoneJobs(m Message) bool {
+	which, ok := m.Attribute(TAG_OPERATION, "which-jobs")
+	if !ok || (which.String() != "completed" && which.String() != "all") {
+		return false
+	}
+	if a, ok := m.Attribute(TAG_OPERATION, "my-jobs"); ok && a.Bool() {
+		return false
+	}
+	_, limited := m.Attribute(TAG_OPERATION, "limit")
+	return !limited
+}
+
+//	Forgets a front-end job-id
+func (p *Proxy) forget(id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if job, ok := p.jobs[id]; ok {
+		delete(p.backJobs, backJob{job.up, job.backId})
+		delete(p.jobs, id)
+	}
+}
+
+//	Forgets the done jobs of a back-end that are missing from its Get-Jobs response
+func (p *Proxy) forgetDone(up *upstream, resp Message) {
+	listed := make(map[int]bool)
+	for _, ag := range resp.GroupsOf(TAG_JOB) {
+		if a, ok := ag.Map()["job-id"]; ok {
+			listed[a.Int()] = true
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, job := range p.jobs {
+		if job.up == up && job.done && !listed[job.backId] {
+			delete(p.backJobs, backJob{up, job.backId})
+			delete(p.jobs, id)
+		}
+	}
+}
+
+//	Rewrites the job-ids, job-uris and printer uris of a back-end response for the front-end
+func (p *Proxy) rewriteResponse(up *upstream, resp *Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, ag := range resp.attributeGroups {
+		a := ag.Map()
+		backId := a["job-id"].Int()
+		if u, ok := a["job-uri"]; ok && backId == 0 {
+			s := u.String()
+			backId, _ = strconv.Atoi(s[strings.LastIndex(s, "/")+1:])
+		}
+		if backId == 0 || ag.beginAttributeGroupTag != TAG_JOB {
+			for i := range ag.attributes {
+				rewriteUris(&ag.attributes[i], up.uri, p.front)
+			}
+			continue
+		}
+		key := backJob{up, backId}
+		id, known := p.backJobs[key]
+		if !known {
+			id = p.nextJobId
+			p.nextJobId++
+			p.backJobs[key] = id
+			p.jobs[id] = &proxyJob{up: up, backId: key.id}
+		}
+		if u, ok := a["job-uri"]; ok {
+			p.jobs[id].uri = u.String()
+		} else if p.jobs[id].uri == "" {
+			p.jobs[id].uri = up.uri + "/" + strconv.Itoa(key.id)
+		}
+		if state, ok := a["job-state"]; ok {
+			p.jobs[id].done = state.Int() >= JOB_CANCELLED
+		}
+		for i := range ag.attributes {
+			at := &ag.attributes[i]
+			switch at.Name() {
+			case "job-id":
+				at.setValue(0, TAG_INTEGER, integer(id))
+			case "job-uri":
+				at.setValue(0, TAG_URI, uri(p.front+"/"+strconv.Itoa(id)))
+			default:
+				rewriteUris(at, up.uri, p.front)
+			}
+		}
+	}
+}
+
+//	Replaces the uri values of a that are from or start with from + "/"
+func rewriteUris(a *attribute, from, to string) {
+	for i, v := range a.values {
+		if v.valueTag != TAG_URI {
+			continue
+		}
+		s := v.str()
+		if s == from || strings.HasPrefix(s, from+"/") {
+			a.setValue(i, TAG_URI, uri(to+s[len(from):]))
+		}
+	}
+}
+
+//	Replaces the i-th value of an attribute, keeping its name
+func (i *attribute) setValue(n int, tag byte, value interface{}) {
+	v := &i.values[n]
+	v.valueTag = tag
+	v.value = value
+	v.refer()
+	v.valueLength = v.Length()
+}
+
+func copyGroups(ags []attributeGroup) []attributeGroup {
+	c := make([]attributeGroup, len(ags))
+	for i, ag := range ags {
+		c[i] = newAg(ag.beginAttributeGroupTag)
+		for _, a := range ag.attributes {
+			a.values = append([]attributeValue{}, a.values...)
+			c[i].attributes = append(c[i].attributes, a)
+		}
+	}
+	return c
+}
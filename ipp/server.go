@@ -0,0 +1,482 @@
+package ipp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//   An IPP Printer receives operation requests as HTTP POSTs with the content type
+//   application/ipp and answers each with an operation response (RFC 8010 section 4).
+//
+//   For every request the Printer checks (RFC 8011 section 4.1.8):
+//
+//      - the "version-number", 'server-error-version-not-supported' if it is not 1.x or 2.x,
+//      - the "request-id", 'client-error-bad-request' if it is 0,
+//      - that "attributes-charset" and "attributes-natural-language" are the first two
+//        operation attributes, 'client-error-bad-request' if not,
+//      - the "attributes-charset", 'client-error-charset-not-supported' if it is not utf-8,
+//      - the target, "printer-uri" or for Job operations "job-uri" or "printer-uri" and "job-id",
+//        'client-error-bad-request' if it is missing,
+//      - the "operation-id", 'server-error-operation-not-supported' for unknown operations.
+//
+//   The response copies the "version-number" and "request-id" of the request and starts with
+//   "attributes-charset" and "attributes-natural-language" in the operation attributes group.
+
+//	Printer implements the Printer and Job operations of a (virtual) printer.
+//	Each method adds its response attributes to resp, the operation attributes group with
+//	attributes-charset and attributes-natural-language has already been added. Returning a
+//	*StatusError sets the status-code and status-message of the response, any other error is
+//	reported as server-error-internal-error. A method may call resp.SetStatusCode itself to
+//	return one of the successful-ok* codes other than successful-ok.
+type Printer interface {
+	PrintJob(r *Request, resp *Message) error
+	ValidateJob(r *Request, resp *Message) error
+	CreateJob(r *Request, resp *Message) error
+	SendDocument(r *Request, resp *Message) error
+	CancelJob(r *Request, resp *Message) error
+	GetJobAttributes(r *Request, resp *Message) error
+	GetJobs(r *Request, resp *Message) error
+	GetPrinterAttributes(r *Request, resp *Message) error
+	HoldJob(r *Request, resp *Message) error
+	ReleaseJob(r *Request, resp *Message) error
+	RestartJob(r *Request, resp *Message) error
+}
+
+//	AdminPrinter is implemented by Printers that support the Printer administration operations
+//	Pause-Printer, Resume-Printer and Purge-Jobs, NewServer registers them when available.
+type AdminPrinter interface {
+	PausePrinter(r *Request, resp *Message) error
+	ResumePrinter(r *Request, resp *Message) error
+	PurgeJobs(r *Request, resp *Message) error
+}
+
+//	OperationFunc handles one operation-id, see Printer for the meaning of resp and the error
+type OperationFunc func(r *Request, resp *Message) error
+
+//	Request is a decoded operation request handed to the Printer
+type Request struct {
+	Message
+	Document io.Reader     // the document data following the end-of-attributes-tag
+	User     string        // the user the Authenticator verified, without one an unverified claim (see SetAuthenticator)
+	HTTP     *http.Request // the HTTP request the operation was received with
+
+	// the document-format sniffed from the first bytes of Document by Serve for Print-Job and
+	// Send-Document (see DetectFormat) and by the Printer after fetching the document of
+	// Print-URI and Send-URI, "" if there is no document data
+	FormatDetected string
+}
+
+//	Returns an operation attribute of the request
+func (r *Request) Operation(name string) (attribute, bool) {
+	return r.Attribute(TAG_OPERATION, name)
+}
+
+//	Returns the target printer-uri of the request
+func (r *Request) PrinterUri() string {
+	a, _ := r.Operation("printer-uri")
+	return a.String()
+}
+
+//	Returns the job-id of the target job, taken from "job-id" or the last segment of "job-uri"
+func (r *Request) JobId() int {
+	if a, ok := r.Operation("job-id"); ok {
+		return a.Int()
+	}
+	if a, ok := r.Operation("job-uri"); ok {
+		s := a.String()
+		id, _ := strconv.Atoi(s[strings.LastIndex(s, "/")+1:])
+		return id
+	}
+	return 0
+}
+
+//	Returns the values of "requested-attributes", nil if the client did not supply it
+func (r *Request) RequestedAttributes() []string {
+	a, _ := r.Operation("requested-attributes")
+	return a.Strings()
+}
+
+//	Server is an http.Handler that decodes IPP requests, dispatches them by operation-id and
+//	writes the responses
+type Server struct {
+	printer        Printer
+	ops            map[uint16]OperationFunc
+	authenticate   Authenticator
+	maxRequestSize int64
+}
+
+//	The largest HTTP request body, attributes and document, a Server reads unless
+//	SetMaxRequestSize was called
+const DefaultMaxRequestSize = 256 << 20
+
+//	Authenticator returns true if password is the password of user
+type Authenticator func(user, password string) bool
+
+//	Returns a Server dispatching the Printer and Job operations to p
+func NewServer(p Printer) *Server {
+	s := &Server{printer: p, ops: make(map[uint16]OperationFunc)}
+	s.Handle(PRINT_JOB, p.PrintJob)
+	s.Handle(VALIDATE_JOB, p.ValidateJob)
+	s.Handle(CREATE_JOB, p.CreateJob)
+	s.Handle(SEND_DOCUMENT, p.SendDocument)
+	s.Handle(CANCEL_JOB, p.CancelJob)
+	s.Handle(GET_JOB_ATTRIBUTES, p.GetJobAttributes)
+	s.Handle(GET_JOBS, p.GetJobs)
+	s.Handle(GET_PRINTER_ATTRIBUTES, p.GetPrinterAttributes)
+	s.Handle(HOLD_JOB, p.HoldJob)
+	s.Handle(RELEASE_JOB, p.ReleaseJob)
+	s.Handle(RESTART_JOB, p.RestartJob)
+	if a, ok := p.(AdminPrinter); ok {
+		s.Handle(PAUSE_PRINTER, a.PausePrinter)
+		s.Handle(RESUME_PRINTER, a.ResumePrinter)
+		s.Handle(PURGE_JOBS, a.PurgeJobs)
+	}
+	if d, ok := p.(DocumentPrinter); ok {
+		s.Handle(GET_DOCUMENT_ATTRIBUTES, d.GetDocumentAttributes)
+		s.Handle(GET_DOCUMENTS, d.GetDocuments)
+		s.Handle(CANCEL_DOCUMENT, d.CancelDocument)
+	}
+	if u, ok := p.(UriPrinter); ok {
+		s.Handle(PRINT_URI, u.PrintUri)
+		s.Handle(SEND_URI, u.SendUri)
+	}
+	if i, ok := p.(InfraPrinter); ok {
+		s.handleInfra(i)
+	}
+	return s
+}
+
+//	Registers (or replaces) the handler of an operation-id
+func (s *Server) Handle(operationId uint16, fn OperationFunc) {
+	s.ops[operationId] = fn
+}
+
+//	Requires HTTP Basic authentication of every request, credentials fn rejects are answered with
+//	401 Unauthorized and Request.User is the verified user. Without an Authenticator User is the
+//	HTTP Basic user or requesting-user-name as the client claims them, nothing checks that they
+//	are who they claim to be.
+func (s *Server) SetAuthenticator(fn Authenticator) {
+	s.authenticate = fn
+}
+
+//	Sets the largest HTTP request body in bytes, larger requests are answered with 413 Request
+//	Entity Too Large or, once the document is read, client-error-request-entity-too-large.
+//	The limit applies to the body as it is sent, after decoding its Content-Encoding and to the
+//	document after decompressing its compression. 0 is DefaultMaxRequestSize.
+func (s *Server) SetMaxRequestSize(n int64) {
+	s.maxRequestSize = n
+}
+
+func (s *Server) requestSize() int64 {
+	if s.maxRequestSize > 0 {
+		return s.maxRequestSize
+	}
+	return DefaultMaxRequestSize
+}
+
+//	Reports a body beyond the limit of http.MaxBytesReader as REQUEST_ENTITY and notes that the
+//	body, or its decoded data, was too large
+type bodyLimit struct {
+	r        io.Reader
+	exceeded bool
+}
+
+func (l *bodyLimit) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		err = &StatusError{Code: REQUEST_ENTITY, Message: "the request is larger than " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes"}
+	}
+	if se, ok := err.(*StatusError); ok && se.Code == REQUEST_ENTITY {
+		l.exceeded = true
+	}
+	return n, err
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, hr *http.Request) {
+	if hr.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "IPP requests must be POSTed", http.StatusMethodNotAllowed)
+		return
+	}
+	user := ""
+	if s.authenticate != nil {
+		u, password, ok := hr.BasicAuth()
+		if !ok || !s.authenticate(u, password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="IPP", charset="UTF-8"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		user = u
+	}
+	size := s.requestSize()
+	decoded, err := contentDecoder(http.MaxBytesReader(w, hr.Body, size), hr.Header.Get("Content-Encoding"), size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	limit := &bodyLimit{r: decoded}
+	body := bufio.NewReader(limit)
+	m, err := ReadMessage(body)
+	if err != nil && limit.exceeded {
+		http.Error(w, "the request is larger than "+strconv.FormatInt(size, 10)+" bytes", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r := &Request{Message: m, Document: body, HTTP: hr, User: user}
+	resp := s.Serve(r)
+	w.Header().Set("Content-Type", "application/ipp")
+	b := marshalResponse(&r.Message, resp)
+	// responses larger than a packet are compressed if the client accepts it
+	if len(b) > 1400 && strings.Contains(hr.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		z := gzip.NewWriter(w)
+		z.Write(b)
+		z.Close()
+		return
+	}
+	w.Write(b)
+}
+
+//	Validates and dispatches a decoded request and returns the response
+func (s *Server) Serve(r *Request) Message {
+	resp := newResponseTo(&r.Message)
+
+	if r.User == "" && r.HTTP != nil && s.authenticate == nil {
+		if u, _, ok := r.HTTP.BasicAuth(); ok {
+			r.User = u
+		}
+	}
+	if r.User == "" {
+		a, _ := r.Operation("requesting-user-name")
+		r.User = a.String()
+	}
+
+	err := s.validate(r)
+	if err == nil {
+		err = prepareDocument(r, s.requestSize())
+	}
+	if err == nil {
+		fn, ok := s.ops[r.OperationId()]
+		if !ok {
+			err = &StatusError{Code: OPERATION_NOT_SUPPORTED}
+		} else {
+			err = fn(r, &resp)
+		}
+	}
+	if err != nil {
+		setStatusError(&resp, err)
+	}
+	return resp
+}
+
+//	Checks the compression of the request and, for Print-Job and Send-Document, replaces Document
+//	with the decompressed data, at most limit bytes, and sets FormatDetected
+func prepareDocument(r *Request, limit int64) error {
+	op := r.OperationId()
+	if op != PRINT_JOB && op != SEND_DOCUMENT && op != PRINT_URI && op != SEND_URI && op != VALIDATE_JOB {
+		return nil
+	}
+	compression := "none"
+	if a, ok := r.Operation("compression"); ok {
+		compression = a.String()
+	}
+	supported := false
+	for _, c := range compressionSupported {
+		supported = supported || c == compression
+	}
+	if !supported {
+		return &StatusError{Code: COMPRESSION_NOT_SUPPORTED, Message: "compression " + compression + " is not supported"}
+	}
+	if op != PRINT_JOB && op != SEND_DOCUMENT || r.Document == nil {
+		return nil
+	}
+	return r.setDocument(r.Document, compression, limit)
+}
+
+//	Sets Document to the decompressed doc and FormatDetected to the format sniffed from it
+func (r *Request) setDocument(doc io.Reader, compression string, limit int64) error {
+	doc, err := decompressor(doc, compression, limit)
+	if err != nil {
+		return err
+	}
+	r.FormatDetected, r.Document, err = SniffFormat(doc)
+	if _, ok := err.(*StatusError); err != nil && !ok {
+		err = &StatusError{Code: DOCUMENT_ACCESS_ERROR, Message: err.Error()}
+	}
+	return err
+}
+
+//	Returns a successful-ok response to r with the version-number and request-id of r and the
+//	attributes-charset and attributes-natural-language operation attributes
+func newResponseTo(r *Message) Message {
+	resp := NewResponse(OK)
+	resp.majorVer, resp.minorVer = r.majorVer, r.minorVer
+	resp.requestId = r.requestId
+	resp.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	lang := "en"
+	if a, ok := r.Attribute(TAG_OPERATION, "attributes-natural-language"); ok && a.String() != "" {
+		lang = a.String()
+	}
+	resp.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage(lang))
+	return resp
+}
+
+//	Returns the encoded response, one that cannot be encoded is replaced by a
+//	server-error-internal-error response to req
+func marshalResponse(req *Message, resp Message) []byte {
+	b, err := resp.marshallMsg()
+	if err != nil {
+		resp = newResponseTo(req)
+		setStatusError(&resp, err)
+		b, _ = resp.marshallMsg()
+	}
+	return b
+}
+
+//	Sets the status-code and status-message of a response from err, an error other than a
+//	*StatusError is reported as server-error-internal-error
+func setStatusError(resp *Message, err error) {
+	se, ok := err.(*StatusError)
+	if !ok {
+		se = &StatusError{Code: INTERNAL_ERROR, Message: err.Error()}
+	}
+	if se.Code == VERSION_NOT_SUPPORTED {
+		resp.majorVer, resp.minorVer = 1, 1
+	}
+	resp.SetStatusCode(se.Code)
+	msg := se.Message
+	if msg == "" {
+		msg = StatusCodeString(se.Code)
+	}
+	var a attribute
+	a.addValue(TAG_TEXT, "status-message", textWithoutLanguage(msg))
+	resp.attributeGroups[0].attributes = append(resp.attributeGroups[0].attributes, a)
+}
+
+//	Operations whose target is a Job: "job-uri", or "printer-uri" and "job-id"
+var jobTargetOperations = map[uint16]bool{
+	SEND_DOCUMENT:           true,
+	SEND_URI:                true,
+	CANCEL_JOB:              true,
+	GET_JOB_ATTRIBUTES:      true,
+	HOLD_JOB:                true,
+	RELEASE_JOB:             true,
+	RESTART_JOB:             true,
+	SET_JOB_ATTRIBUTES:      true,
+	FETCH_JOB:               true,
+	ACKNOWLEDGE_JOB:         true,
+	FETCH_DOCUMENT:          true,
+	ACKNOWLEDGE_DOCUMENT:    true,
+	UPDATE_JOB_STATUS:       true,
+	UPDATE_DOCUMENT_STATUS:  true,
+	CANCEL_DOCUMENT:         true,
+	GET_DOCUMENT_ATTRIBUTES: true,
+	GET_DOCUMENTS:           true,
+	DELETE_DOCUMENT:         true,
+	SET_DOCUMENT_ATTRIBUTES: true,
+	RESUBMIT_JOB:            true,
+	CLOSE_JOB:               true,
+}
+
+//	Operations whose target is the Printer: "printer-uri"
+var printerTargetOperations = map[uint16]bool{
+	PRINT_JOB:                       true,
+	PRINT_URI:                       true,
+	VALIDATE_JOB:                    true,
+	CREATE_JOB:                      true,
+	GET_JOBS:                        true,
+	GET_PRINTER_ATTRIBUTES:          true,
+	PAUSE_PRINTER:                   true,
+	RESUME_PRINTER:                  true,
+	PURGE_JOBS:                      true,
+	SET_PRINTER_ATTRIBUTES:          true,
+	ENABLE_PRINTER:                  true,
+	DISABLE_PRINTER:                 true,
+	PAUSE_PRINTER_AFTER_CURRENT_JOB: true,
+	HOLD_NEW_JOBS:                   true,
+	RELEASE_HELD_NEW_JOBS:           true,
+	DEREGISTER_OUTPUT_DEVICE:        true,
+	UPDATE_OUTPUT_DEVICE_ATTRIBUTES: true,
+	UPDATE_ACTIVE_JOBS:              true,
+	CANCEL_JOBS:                     true,
+	CANCEL_MY_JOBS:                  true,
+	IDENTIFY_PRINTER:                true,
+}
+
+//	Operations whose target is the System: "system-uri"
+var systemTargetOperations = map[uint16]bool{
+	REGISTER_OUTPUT_DEVICE:               true,
+	CREATE_PRINTER:                       true,
+	DELETE_PRINTER:                       true,
+	GET_PRINTERS:                         true,
+	SHUTDOWN_ONE_PRINTER:                 true,
+	STARTUP_ONE_PRINTER:                  true,
+	DISABLE_ALL_PRINTERS:                 true,
+	ENABLE_ALL_PRINTERS:                  true,
+	GET_SYSTEM_ATTRIBUTES:                true,
+	GET_SYSTEM_SUPPORTED_VALUES:          true,
+	PAUSE_ALL_PRINTERS:                   true,
+	PAUSE_ALL_PRINTERS_AFTER_CURRENT_JOB: true,
+	RESTART_SYSTEM:                       true,
+	RESUME_ALL_PRINTERS:                  true,
+	SET_SYSTEM_ATTRIBUTES:                true,
+	SHUTDOWN_ALL_PRINTERS:                true,
+	STARTUP_ALL_PRINTERS:                 true,
+}
+
+//	Checks the parameters and attributes every request MUST supply
+func (s *Server) validate(r *Request) error {
+	if r.majorVer != 1 && r.majorVer != 2 {
+		return &StatusError{Code: VERSION_NOT_SUPPORTED}
+	}
+	if r.requestId == 0 {
+		return &StatusError{Code: BAD_REQUEST, Message: "request-id must not be 0"}
+	}
+	ag, ok := r.Group(TAG_OPERATION)
+	if !ok || len(ag.attributes) < 2 ||
+		ag.attributes[0].Name() != "attributes-charset" ||
+		ag.attributes[1].Name() != "attributes-natural-language" {
+		return &StatusError{Code: BAD_REQUEST, Message: "attributes-charset and attributes-natural-language must be the first operation attributes"}
+	}
+	if cs := strings.ToLower(ag.attributes[0].String()); cs != "utf-8" && cs != "us-ascii" {
+		return &StatusError{Code: CHARSET, Message: "attributes-charset " + cs + " is not supported"}
+	}
+	op := r.OperationId()
+	if printerTargetOperations[op] {
+		if _, ok := r.Operation("printer-uri"); !ok {
+			return &StatusError{Code: BAD_REQUEST, Message: "missing printer-uri"}
+		}
+	}
+	if jobTargetOperations[op] {
+		_, hasJobUri := r.Operation("job-uri")
+		_, hasPrinterUri := r.Operation("printer-uri")
+		_, hasJobId := r.Operation("job-id")
+		if !hasJobUri && !(hasPrinterUri && hasJobId) {
+			return &StatusError{Code: BAD_REQUEST, Message: "missing job-uri or printer-uri and job-id"}
+		}
+	}
+	if documentTargetOperations[op] {
+		if _, ok := r.Operation("document-number"); !ok {
+			return &StatusError{Code: BAD_REQUEST, Message: "missing document-number"}
+		}
+	}
+	if systemTargetOperations[op] {
+		if _, ok := r.Operation("system-uri"); !ok {
+			return &StatusError{Code: BAD_REQUEST, Message: "missing system-uri"}
+		}
+	}
+	if infraOperations[op] {
+		if _, ok := r.Operation("output-device-uuid"); !ok {
+			return &StatusError{Code: BAD_REQUEST, Message: "missing output-device-uuid"}
+		}
+	}
+	return nil
+}
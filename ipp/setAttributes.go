@@ -0,0 +1,72 @@
+package ipp
+
+import (
+
+)
+
+//   Set-Printer-Attributes (RFC 3380 section 4.1) and Set-Job-Attributes (RFC 3380 section 4.2)
+//
+//   The client supplies the attributes to modify in the printer-attributes (Set-Printer-Attributes)
+//   or job-attributes (Set-Job-Attributes) group. The operation is atomic: either all of the
+//   supplied attributes are set or none are.
+//
+//   To delete an attribute the client supplies it with the 'deleteAttribute' out-of-band value
+//   (TAG_DELETEATTR) as its only value. Deleting an attribute that does not exist is not an error.
+//
+//   If an attribute is not settable the Printer returns 'client-error-attributes-not-settable'
+//   and lists the attribute in the unsupported-attributes group with the 'not-settable'
+//   out-of-band value (TAG_NOTSETTABLE). Attributes or values that are not supported at all
+//   are returned with their value(s) or the 'unsupported' out-of-band value.
+
+//	Returns an attribute that deletes "name" when sent in a Set-Printer-Attributes or Set-Job-Attributes request
+func DeleteAttribute(name string) attribute {
+	a := NewAttribute()
+	a.AddValue(TAG_DELETEATTR, name, nil)
+	return a
+}
+
+//	Modifies the attributes of a printer (see PrinterUri), attributes created with
+//	DeleteAttribute are removed. The names of attributes the printer returned as not-settable
+//	are returned along with the error.
+func (c *CupsServer) SetPrinterAttributes(printer string, attrs ...attribute) ([]string, error) {
+	m := c.newRequest(SET_PRINTER_ATTRIBUTES, c.PrinterUri(printer), 0)
+	m.AddGroup(TAG_PRINTER)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_PRINTER, a)
+	}
+	r, err := c.doRequest(m, "/admin/")
+	return NotSettable(r), err
+}
+
+//	Modifies the attributes of a job, attributes created with DeleteAttribute are removed.
+//	The names of attributes the printer returned as not-settable are returned along with the error.
+func (c *CupsServer) SetJobAttributes(jobId int, attrs ...attribute) ([]string, error) {
+	m := c.newRequest(SET_JOB_ATTRIBUTES, "", jobId)
+	m.AddGroup(TAG_JOB)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_JOB, a)
+	}
+	r, err := c.doRequest(m, "/jobs/")
+	return NotSettable(r), err
+}
+
+//	Returns the attributes of the unsupported-attributes group of a response
+func (im *Message) Unsupported() []attribute {
+	var attrs []attribute
+	for _, ag := range im.GroupsOf(TAG_UNSUPPORTED_GROUP) {
+		attrs = append(attrs, ag.attributes...)
+	}
+	return attrs
+}
+
+//	Returns the names of the attributes a response lists in the unsupported-attributes group
+//	with the 'not-settable' out-of-band value
+func NotSettable(r Message) []string {
+	var names []string
+	for _, a := range r.Unsupported() {
+		if a.Tag() == TAG_NOTSETTABLE {
+			names = append(names, a.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,264 @@
+package ipp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//   PWG 5100.22 IPP System Service
+//
+//   The System object manages the Printers of a print server. Its operations target the
+//   "system-uri" (e.g. ipp://server/ipp/system) instead of a printer-uri; the System answers
+//   with its attributes in the system-attributes group (tag 0x0A). Printers are identified by
+//   their "printer-id" (integer(1:65535)).
+//
+//      Get-System-Attributes (0x005B)    returns the system-attributes group
+//      Set-System-Attributes (0x0062)    changes the attributes supplied in the system-attributes group
+//      Get-Printers (0x004F)             returns one printer-attributes group per Printer
+//      Create-Printer (0x004C)           creates a Printer, the response holds its printer-id
+//      Delete-Printer (0x004E)           deletes the Printer "printer-id"
+//      Startup-One-Printer (0x0051)      starts (Shutdown-One-Printer 0x0050 stops) the Printer "printer-id"
+//      Startup-All-Printers (0x0064)     starts (Shutdown-All-Printers 0x0063 stops) every Printer
+//
+//   Every request carries "system-uri" after attributes-natural-language and then
+//   "requesting-user-name".
+
+//	SystemClient sends System Service requests to the System system-uri
+type SystemClient struct {
+	systemUri      string
+	username       string
+	password       string
+	requestCounter int32
+}
+
+//	Returns a SystemClient for the System systemUri, e.g. "ipp://print-server/ipp/system"
+func NewSystemClient(systemUri string) *SystemClient {
+	return &SystemClient{systemUri: systemUri}
+}
+
+//	Sets the requesting-user-name and the credentials used for HTTP Basic authentication
+func (c *SystemClient) SetUser(username, password string) {
+	c.username = username
+	c.password = password
+}
+
+//	SystemAttributes are the system description and status attributes of a System
+type SystemAttributes struct {
+	Name                string      // system-name
+	Info                string      // system-info
+	Location            string      // system-location
+	MakeAndModel        string      // system-make-and-model
+	Uuid                string      // system-uuid
+	State               int         // system-state, PRINTER_IDLE, PRINTER_PROCESSING or PRINTER_STOPPED
+	StateReasons        []string    // system-state-reasons
+	StateMessage        string      // system-state-message
+	UpTime              int         // system-up-time in seconds
+	CurrentTime         time.Time   // system-current-time
+	ConfigChanges       int         // system-config-changes
+	ConfigChangeTime    int         // system-config-change-time, in system-up-time seconds
+	DefaultPrinterId    int         // system-default-printer-id, 0 if there is none
+	GeoLocation         string      // system-geo-location, a geo: uri
+	DnsSdName           string      // system-dns-sd-name
+	OperationsSupported []int       // operations-supported
+	Attributes          []attribute // every attribute of the system-attributes group
+}
+
+//	The system description and status attributes SystemAttributes is made of
+var systemAttributes = []string{
+	"system-name",
+	"system-info",
+	"system-location",
+	"system-make-and-model",
+	"system-uuid",
+	"system-state",
+	"system-state-reasons",
+	"system-state-message",
+	"system-up-time",
+	"system-current-time",
+	"system-config-changes",
+	"system-config-change-time",
+	"system-default-printer-id",
+	"system-geo-location",
+	"system-dns-sd-name",
+	"operations-supported",
+}
+
+func newSystemAttributes(ag attributeGroup) SystemAttributes {
+	a := ag.Map()
+	var s SystemAttributes
+	s.Name = a["system-name"].String()
+	s.Info = a["system-info"].String()
+	s.Location = a["system-location"].String()
+	s.MakeAndModel = a["system-make-and-model"].String()
+	s.Uuid = a["system-uuid"].String()
+	s.State = a["system-state"].Int()
+	s.StateReasons = a["system-state-reasons"].Strings()
+	s.StateMessage = a["system-state-message"].String()
+	s.UpTime = a["system-up-time"].Int()
+	s.CurrentTime = a["system-current-time"].Time()
+	s.ConfigChanges = a["system-config-changes"].Int()
+	s.ConfigChangeTime = a["system-config-change-time"].Int()
+	s.DefaultPrinterId = a["system-default-printer-id"].Int()
+	s.GeoLocation = a["system-geo-location"].String()
+	s.DnsSdName = a["system-dns-sd-name"].String()
+	s.OperationsSupported = a["operations-supported"].Ints()
+	s.Attributes = ag.attributes
+	return s
+}
+
+//	SystemPrinter is a Printer of a System as listed by Get-Printers
+type SystemPrinter struct {
+	Id          int    // printer-id
+	ServiceType string // printer-service-type, e.g. 'print', 'scan' or 'faxout'
+	Destination
+}
+
+//	PrinterSelection narrows Get-Printers, empty fields are left out of the request
+type PrinterSelection struct {
+	Ids         []int    // printer-ids
+	ServiceType []string // printer-service-types
+	Which       string   // which-printers, e.g. 'all', 'idle', 'stopped', 'shutdown' or 'accepting'
+	FirstIndex  int      // first-index, starting at 1
+	Limit       int      // limit
+}
+
+//	Get-System-Attributes: returns the system description and status attributes, requested
+//	names more attributes for SystemAttributes.Attributes, e.g. "system-firmware-version"
+func (c *SystemClient) GetSystemAttributes(requested ...string) (SystemAttributes, error) {
+	m := c.newRequest(GET_SYSTEM_ATTRIBUTES)
+	m.AppendAttribute(requestedAttributes(append(append([]string{}, systemAttributes...), requested...)))
+	r, err := c.do(m)
+	if err != nil {
+		return SystemAttributes{}, err
+	}
+	ag, _ := r.Group(TAG_SYSTEM)
+	return newSystemAttributes(ag), nil
+}
+
+//	Set-System-Attributes: changes system attributes, e.g. system-location or system-default-printer-id.
+//	Returns the names of the attributes the System did not set, see SetPrinterAttributes.
+func (c *SystemClient) SetSystemAttributes(attrs ...attribute) ([]string, error) {
+	m := c.newRequest(SET_SYSTEM_ATTRIBUTES)
+	m.AddGroup(TAG_SYSTEM)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_SYSTEM, a)
+	}
+	r, err := c.do(m)
+	return NotSettable(r), err
+}
+
+//	Get-Printers: returns the Printers of the System that match the selection
+func (c *SystemClient) GetPrinters(sel PrinterSelection) ([]SystemPrinter, error) {
+	m := c.newRequest(GET_PRINTERS)
+	m.AppendAttribute(requestedAttributes(append([]string{"printer-id", "printer-service-type"}, destinationAttributes...)))
+	if len(sel.Ids) > 0 {
+		a := NewAttribute()
+		for _, id := range sel.Ids {
+			a.AddValue(TAG_INTEGER, "printer-ids", integer(id))
+		}
+		m.AppendAttribute(a)
+	}
+	if len(sel.ServiceType) > 0 {
+		a := NewAttribute()
+		for _, t := range sel.ServiceType {
+			a.AddValue(TAG_KEYWORD, "printer-service-types", keyword(t))
+		}
+		m.AppendAttribute(a)
+	}
+	if sel.Which != "" {
+		m.AddAttribute(TAG_KEYWORD, "which-printers", keyword(sel.Which))
+	}
+	if sel.FirstIndex > 0 {
+		m.AddAttribute(TAG_INTEGER, "first-index", integer(sel.FirstIndex))
+	}
+	if sel.Limit > 0 {
+		m.AddAttribute(TAG_INTEGER, "limit", integer(sel.Limit))
+	}
+	r, err := c.do(m)
+	if err = listError(err); err != nil || !IsSuccessful(r.StatusCode()) {
+		return nil, err
+	}
+	var printers []SystemPrinter
+	for _, ag := range r.GroupsOf(TAG_PRINTER) {
+		a := ag.Map()
+		printers = append(printers, SystemPrinter{
+			Id:          a["printer-id"].Int(),
+			ServiceType: a["printer-service-type"].String(),
+			Destination: newDestination(ag),
+		})
+	}
+	return printers, nil
+}
+
+//	Create-Printer: creates a 'print' Printer named name and returns its printer-id.
+//	Info and Location of cfg are used, attrs are added to the printer-attributes group,
+//	e.g. printer-device-id or printer-geo-location.
+func (c *SystemClient) CreatePrinter(name string, cfg PrinterConfig, attrs ...attribute) (int, error) {
+	m := c.newRequest(CREATE_PRINTER)
+	m.AddAttribute(TAG_KEYWORD, "printer-service-type", keyword("print"))
+	m.AddGroup(TAG_PRINTER)
+	m.AddGroupAttribute(TAG_PRINTER, TAG_NAME, "printer-name", nameWithoutLanguage(name))
+	addPrinterInfo(&m, cfg)
+	for _, a := range attrs {
+		m.AppendGroupAttribute(TAG_PRINTER, a)
+	}
+	r, err := c.do(m)
+	if err != nil {
+		return 0, err
+	}
+	a, _ := r.Attribute(TAG_PRINTER, "printer-id")
+	return a.Int(), nil
+}
+
+//	Delete-Printer: deletes a Printer and its jobs
+func (c *SystemClient) DeletePrinter(printerId int) error {
+	return c.printerRequest(DELETE_PRINTER, printerId)
+}
+
+//	Startup-One-Printer: starts a Printer that was shut down
+func (c *SystemClient) StartupPrinter(printerId int) error {
+	return c.printerRequest(STARTUP_ONE_PRINTER, printerId)
+}
+
+//	Shutdown-One-Printer: shuts a Printer down, it stops accepting requests other than Startup-One-Printer
+func (c *SystemClient) ShutdownPrinter(printerId int) error {
+	return c.printerRequest(SHUTDOWN_ONE_PRINTER, printerId)
+}
+
+//	Startup-All-Printers: starts every Printer of the System
+func (c *SystemClient) StartupAllPrinters() error {
+	_, err := c.do(c.newRequest(STARTUP_ALL_PRINTERS))
+	return err
+}
+
+//	Shutdown-All-Printers: shuts every Printer of the System down
+func (c *SystemClient) ShutdownAllPrinters() error {
+	_, err := c.do(c.newRequest(SHUTDOWN_ALL_PRINTERS))
+	return err
+}
+
+func (c *SystemClient) printerRequest(operationId uint16, printerId int) error {
+	m := c.newRequest(operationId)
+	m.AddAttribute(TAG_INTEGER, "printer-id", integer(printerId))
+	_, err := c.do(m)
+	return err
+}
+
+//	Creates a request with attributes-charset, attributes-natural-language, system-uri and requesting-user-name
+func (c *SystemClient) newRequest(operationId uint16) Message {
+	m := newMessage(operationId)
+	m.AddAttribute(TAG_CHARSET, "attributes-charset", charset("utf-8"))
+	m.AddAttribute(TAG_LANGUAGE, "attributes-natural-language", naturalLanguage("en-us"))
+	m.AddAttribute(TAG_URI, "system-uri", uri(c.systemUri))
+	user := c.username
+	if user == "" {
+		user = "anonymous"
+	}
+	m.AddAttribute(TAG_NAME, "requesting-user-name", nameWithoutLanguage(user))
+	return m
+}
+
+func (c *SystemClient) do(m Message) (Message, error) {
+	m.requestId = atomic.AddInt32(&c.requestCounter, 1)
+	return postMessage(httpUrl(c.systemUri), m, c.username, c.password)
+}
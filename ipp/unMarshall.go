@@ -1,9 +1,11 @@
 package ipp
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/binary"
-	"log"
+	"errors"
+	"fmt"
+	"io"
 	utility "utils"
 )
 
@@ -50,75 +52,98 @@ type Message struct {
 */
 
 func ParseMessage(b []byte) (m Message, err error) {
-	err = nil
-	i, _ := binary.Varint(b[:1])
-	m.majorVer = int8(i) //	version-number            	2 bytes  	- required
-	i, _ = binary.Varint(b[1:2])
-	m.minorVer = int8(i)
-	log.Println(b[1:2])
+	if len(b) < 9 {
+		return m, errors.New("ipp: message shorter than its header")
+	}
+	m.majorVer = int8(b[0]) //	version-number            	2 bytes  	- required
+	m.minorVer = int8(b[1])
 
-	ii, _ := binary.Uvarint(b[3:4])
-	m.operationIdStatusCode = uint16(ii) //	operation-id (request)		2 bytes  	- required
-	m.IsResponse = typeCheck(uint16(ii)) //	or status-code (response)                   		
+	ii := binary.BigEndian.Uint16(b[2:4])
+	m.operationIdStatusCode = ii   //	operation-id (request)		2 bytes  	- required
+	m.IsResponse = typeCheck(ii)   //	or status-code (response)                   		
 
-	iii, _ := binary.Varint(b[4:8]) //	request-id 					4 bytes  	- required	
-	m.requestId = int32(iii)
-	//et := bytes.IndexByte(b[8:], TAG_END)
+	m.requestId = int32(binary.BigEndian.Uint32(b[4:8])) //	request-id 					4 bytes  	- required	
 
-	ags := splitAValues(b[8 : ]) //et+9]) //	attribute-group				n bytes 	- 0 or more
+	ags, data, err := splitAValues(b[8:]) //	attribute-group				n bytes 	- 0 or more
 	m.attributeGroups = ags
-
-	// m.endAttributeTag = b[et] //	end-of-attributes-tag		1 byte   	- required
-
-	//m.Data = b[et+8:] //	data q bytes  	- optional
-	//log.Println("Data: ", m.Data)
+	m.endAttributeTag = TAG_END //	end-of-attributes-tag		1 byte   	- required
+	m.Data = data               //	data 						q bytes  	- optional
 
 	return
 }
 
-func splitAValues(b []byte) (ags []attributeGroup) {
-	util := utility.NewIterator(b)
-	var vTag byte
-	var name string
-	// ====================================== begin-attribute-group-tag ================================================
-	bt, err := util.GetNextOne() // get the first byte which should be 0x01 start operation-attributes	operation-attributes-tag
-	if !err {
-		log.Println("87: ", bt) // if next byte is a dud
+//	Reads a message from r up to and including the end-of-attributes-tag. The "data" that follows
+//	is left unread in r so documents can be streamed instead of being held in memory.
+func ReadMessage(r *bufio.Reader) (Message, error) {
+	var b []byte
+	header := make([]byte, 8) //	version-number, operation-id or status-code, request-id
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Message{}, fmt.Errorf("ipp: reading header: %v", err)
 	}
-	if bt != 0x01 {
-		log.Println("malformed packet", bt) // if first byte is not operation-attributes-tag
+	b = append(b, header...)
+	for {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return Message{}, errors.New("ipp: missing end-of-attributes-tag")
+		}
+		b = append(b, tag)
+		if tag == TAG_END {
+			break
+		}
+		if tag < TAG_UNSUPPORTED_VALUE { // begin-attribute-group-tag
+			continue
+		}
+		for i := 0; i < 2; i++ { // name-length and name, then value-length and value
+			l := make([]byte, 2)
+			if _, err := io.ReadFull(r, l); err != nil {
+				return Message{}, errors.New("ipp: truncated attribute")
+			}
+			field := make([]byte, binary.BigEndian.Uint16(l))
+			if _, err := io.ReadFull(r, field); err != nil {
+				return Message{}, errors.New("ipp: truncated attribute")
+			}
+			b = append(b, l...)
+			b = append(b, field...)
+		}
 	}
+	return ParseMessage(b)
+}
+
+func splitAValues(b []byte) (ags []attributeGroup, data []byte, err error) {
+	util := utility.NewIterator(b)
 	var ag attributeGroup
-	ag.beginAttributeGroupTag = bt
+	var v attribute
+	inGroup := false
 	//   ----------------------------------------------------------
 	//   |           begin-attribute-group-tag         |  1 byte  |-
 	//   ----------------------------------------------------------
 	//   |                   attribute                 |  p bytes |- 0 or more
 	//   ----------------------------------------------------------
-	one := utility.Start(1)
-	var v attribute
-	// ===================================== attribute-with ====================================================
-	for vTag != TAG_END { // parse atribute groups (b []byte) until TAG_END is reached
-		
-		one.Plus()
-		log.Println("Loop number: ", one.Get(), ag.beginAttributeGroupTag, len(ags))
-		util.DeBug()
-		vTag, err = util.GetNextOne() // get value tag
+	for { // parse atribute groups (b []byte) until TAG_END is reached
+		vTag, ok := util.GetNextOne() // get value tag
+		if !ok {
+			return ags, nil, errors.New("ipp: missing end-of-attributes-tag")
+		}
 		_, isDelimitter := checkGroupTag(vTag)
-		
+
 		// ================================= New Group vTag is a Delimitter ====================================
-		if isDelimitter {
-			ag.attributes = append(ag.attributes, v) // add the attributes to the existing group
-			ags = append(ags, ag)                    // append the group to the groups
-			var agn attributeGroup 
-			ag = agn                   // start a new attribute Group
-			ag.beginAttributeGroupTag = vTag
+		if isDelimitter || vTag < TAG_UNSUPPORTED_VALUE {
+			if len(v.values) > 0 {
+				ag.attributes = append(ag.attributes, v) // add the attribute to the existing group
+				v = attribute{}
+			}
+			if inGroup {
+				ags = append(ags, ag) // append the group to the groups
+			}
+			if vTag == TAG_END {
+				return ags, util.Remaining(), nil
+			}
+			ag = attributeGroup{beginAttributeGroupTag: vTag} // start a new attribute Group
+			inGroup = true
 			continue
 		}
-
-		if !err {
-			log.Println("115: ", vTag) // if next byte is a dud 
-			break
+		if !inGroup {
+			return ags, nil, fmt.Errorf("ipp: value-tag 0x%02x before begin-attribute-group-tag", vTag)
 		}
 		//   Each "attribute-with-one-value" field is encoded as follows:
 		//   -----------------------------------------------
@@ -133,79 +158,129 @@ func splitAValues(b []byte) (ags []attributeGroup) {
 		//   |                     value                   |   v bytes
 		//   -----------------------------------------------
 		// =========================================== name length =========================================================
-		x, err := util.GetNextN(2) // name-length; if 0 then is additional attribute
-		if !err {
-			log.Println("142") // there was a problem fetching the name length bytes (2)
-			break
-		}
-		cnt := utility.Start(1) // value counter
-		var xx int16
-		buf := bytes.NewBuffer(x)
-		er := binary.Read(buf, binary.BigEndian, &xx)
-		if er != nil {
-			log.Println("150: ", er)
+		x, ok := util.GetNextN(2) // name-length; if 0 then is additional attribute
+		if !ok {
+			return ags, nil, errors.New("ipp: truncated name-length")
 		}
-		nLength := xx
+		nLength := binary.BigEndian.Uint16(x)
 		// ======================================== name if length not 0 ===================================================
-		if nLength != 0 { // attribute is an operation/status not an additional Value to current Attribute	
-			if cnt.GTEq(1) { // append v to ag.attributes if not the first time through
-				log.Println("158 - append v to ag.attributes: ", v)
-				ag.attributes = append(ag.attributes, v)
-				var nv attribute
-				v = nv // reset the attribute
+		var name []byte
+		if nLength != 0 {
+			name, ok = util.GetNextN(int(nLength))
+			if !ok {
+				return ags, nil, errors.New("ipp: truncated attribute name")
 			}
-			n, err := util.GetNextN(int(nLength)) // Start of new Attribute, if cnt > 1 then it is not the first run and we need to append 
-			name = string(n)
-			cnt.Plus() // increase av counter so we know that the next time nLength != 0 it is a new av and not the first one
-			if !err {
-				log.Println("166") // there was a problem getting the name bytes
-				break
+		}
+		x, ok = util.GetNextN(2) // value-length
+		if !ok {
+			return ags, nil, errors.New("ipp: truncated value-length")
+		}
+		vLength := binary.BigEndian.Uint16(x)
+		value, ok := util.GetNextN(int(vLength)) // value
+		if !ok {
+			return ags, nil, fmt.Errorf("ipp: truncated value of %q", name)
+		}
+		av, err := UnMarshallattribute(vTag, value) //returns attributeValue
+		if err != nil {
+			return ags, nil, err
+		}
+		if vTag == TAG_BEGIN_COLLECTION {
+			c, err := readCollection(&util, 1)
+			if err != nil {
+				return ags, nil, err
 			}
-			x, err = util.GetNextN(2) // value-length
-			buf := bytes.NewBuffer(x)
-			er := binary.Read(buf, binary.BigEndian, &xx)
-			vLength := xx
-			if er != nil {
-				log.Println("177: ", er)
-				break
+			av.value = c
+			av.refer()
+		} else if vTag == TAG_END_COLLECTION || vTag == TAG_MEMBERNAME {
+			return ags, nil, fmt.Errorf("ipp: value-tag 0x%02x outside of a collection", vTag)
+		}
+		if nLength != 0 { // attribute-with-one-value starts a new attribute
+			if len(v.values) > 0 {
+				ag.attributes = append(ag.attributes, v)
+				v = attribute{}
 			}
-			util.DeBug()
-			value, err := util.GetNextN(int(vLength)) // value
-			if !err {
-				log.Println("182 util.GetNextN(int(vLength)) vLength: ", vLength)
-				break
+			av.name = string(name)
+			av.nameLength = int16(nLength)
+		} else if len(v.values) == 0 { // additional-value without an attribute to add to
+			return ags, nil, errors.New("ipp: additional-value without attribute name")
+		}
+		v.appendValue(av)
+	}
+}
+
+//	The fields splitAValues reads a message from
+type fieldReader interface {
+	GetNextOne() (byte, bool)
+	GetNextN(n int) ([]byte, bool)
+}
+
+//	Collections nested deeper are rejected
+const maxCollectionDepth = 16
+
+//	Reads the members of a collection up to and including its endCollection field, the
+//	begCollection field has been read
+func readCollection(r fieldReader, depth int) (collection, error) {
+	if depth > maxCollectionDepth {
+		return nil, errors.New("ipp: collections nested too deep")
+	}
+	var c collection
+	var member attribute
+	name := ""
+	for {
+		vTag, ok := r.GetNextOne()
+		if !ok {
+			return nil, errors.New("ipp: missing endCollection")
+		}
+		// value-tag, name-length (0 in a collection), name, value-length, value
+		x, ok := r.GetNextN(2)
+		if !ok {
+			return nil, errors.New("ipp: truncated name-length")
+		}
+		if _, ok = r.GetNextN(int(binary.BigEndian.Uint16(x))); !ok {
+			return nil, errors.New("ipp: truncated attribute name")
+		}
+		if x, ok = r.GetNextN(2); !ok {
+			return nil, errors.New("ipp: truncated value-length")
+		}
+		value, ok := r.GetNextN(int(binary.BigEndian.Uint16(x)))
+		if !ok {
+			return nil, errors.New("ipp: truncated collection value")
+		}
+		switch vTag {
+		case TAG_END_COLLECTION, TAG_MEMBERNAME:
+			if len(member.values) > 0 {
+				c = append(c, member)
+				member = attribute{}
 			}
-			av, _ := UnMarshallattribute(vTag, value) //returns attributeValue
-			av.name = name
-			av.nameLength = nLength
-			v.appendValue(av)
-			continue
-		} else if nLength == 0 {
-			x, err = util.GetNextN(2)
-			buf := bytes.NewBuffer(x)
-			er := binary.Read(buf, binary.BigEndian, &xx)
-			vLength := xx
-			if er != nil {
-				log.Println("201: ", er)
-				break
+			if vTag == TAG_END_COLLECTION {
+				return c, nil
 			}
-			value, err := util.GetNextN(int(vLength)) // value
-
-			if !err {
-				log.Println("175")
-				break
+			if len(value) == 0 {
+				return nil, errors.New("ipp: memberAttrName without a name")
 			}
-			av, _ := UnMarshallattribute(vTag, value)
-			av.name = ""
-			v.appendValue(av)
+			name = string(value)
 			continue
 		}
-
-		ag.attributes = append(ag.attributes, v)
-		ags = append(ags, ag)
+		if name == "" {
+			return nil, errors.New("ipp: collection value without memberAttrName")
+		}
+		av, err := UnMarshallattribute(vTag, value)
+		if err != nil {
+			return nil, err
+		}
+		if vTag == TAG_BEGIN_COLLECTION {
+			if av.value, err = readCollection(r, depth+1); err != nil {
+				return nil, err
+			}
+			av.refer()
+		}
+		if len(member.values) == 0 {
+			// the first value carries the member name like the first value of an attribute
+			av.name = name
+			av.nameLength = int16(len(name))
+		}
+		member.appendValue(av)
 	}
-	ags = append(ags, ag)
-	return
 }
 
 // Attribute Group Tags - Delimitters 
@@ -240,110 +315,106 @@ func checkGroupTag(b byte) (status string, err bool) {
 	case 0x09:
 		status = "TAG_DOCUMENT_ATTRIBUTES" //
 		err = true
+	case 0x0a:
+		status = "TAG_SYSTEM" // "system-attributes-tag"
+		err = true
 	}
 	return status, err
 }
 
 // Attribute Syntaxes
+//
 //	bi = value tag as byte; bts = value as []byte
 func UnMarshallattribute(bi byte, bts []byte) (attributeValue, error) {
 	var a attributeValue
-	// a.value = bts
+	var err error
+	a.valueTag = bi
 	switch bi {
 	case 0x21:
-		a.valueTag = TAG_INTEGER // integer
-		a.valueTagStr = "TAG_INTEGER"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(integer); return b.MarshalIPP() })
-		a.UnMarshal = (func([]byte) error { b := a.value.(integer); return b.UnMarshalIPP(bts) })
-		a.Length = (func() uint16 { return uint16(4) })
+		a.valueTagStr = "TAG_INTEGER" // integer
+		var b integer
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x22:
-		a.valueTag = TAG_BOOLEAN // boolean
-		a.valueTagStr = "TAG_BOOLEAN"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(ippBoolean); return b.MarshalIPP() })
-		a.UnMarshal = (func(bts []byte) error { var b ippBoolean; b.UnMarshalIPP(bts); a.value = b; return nil})
-		a.Length = (func() uint16 {return uint16(1) })
-		a.String = (func() string {x := a.value.(ippBoolean); return x.String()})
+		a.valueTagStr = "TAG_BOOLEAN" // boolean
+		var b ippBoolean
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x23:
-		a.valueTag = TAG_ENUM // enum
-		a.valueTagStr = "TAG_ENUM"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(enum); return b.MarshalIPP()})
-		a.UnMarshal = (func(bts []byte) error { var b enum; b.UnMarshalIPP(bts); a.value = b; return nil})
-		a.String = (func() string {x := a.value.(enum); return x.String()})
-		a.Length = (func() uint16 {return uint16(4)})				
+		a.valueTagStr = "TAG_ENUM" // enum
+		var b enum
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x30:
-		a.valueTag = TAG_STRING // octetString with an  unspecified format
-		a.valueTagStr = "TAG_STRING"
-		a.Marshal = (func() ([]byte, error) {b := a.value.(octetString); return b.MarshalIPP()})
-		a.UnMarshal = (func([]byte) error {b := a.value.(octetString); return b.UnMarshalIPP(bts)})
-		a.Length = (func() uint16 {b := a.value.(octetString); return b.len()})
+		a.valueTagStr = "TAG_STRING" // octetString with an  unspecified format
+		var b octets
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x31:
-		a.valueTag = TAG_DATE // dateTime
-		a.valueTagStr = "TAG_DATE"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(dateTime); return b.MarshalIPP() })
-		a.Length = (func() uint16 { return uint16(9) })
-		a.UnMarshal = (func([]byte) error { b := a.value.(dateTime); return b.UnMarshalIPP(bts) })
+		a.valueTagStr = "TAG_DATE" // dateTime
+		var b dateTime
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x32:
-		a.valueTag = TAG_RESOLUTION // resolution
-		a.valueTagStr = "TAG_RESOLUTION"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(resolution); return b.MarshalIPP() })
-		a.UnMarshal = (func([]byte) error { b := a.value.(resolution); return b.UnMarshalIPP(bts) })
-		a.Length = (func() uint16 { return uint16(11) })
+		a.valueTagStr = "TAG_RESOLUTION" // resolution
+		var b resolution
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x33:
-		a.valueTag = TAG_RANGE // rangeOfInteger
-		a.valueTagStr = "TAG_RANGE"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(rangeOfInteger); return b.MarshalIPP() })
-		a.UnMarshal = (func(bts []byte) error { var b rangeOfInteger; b.UnMarshalIPP(bts); a.value = b; return nil})
-		a.String = (func() string {x := a.value.(rangeOfInteger); return x.String()})
-		a.Length = (func() uint16 { return uint16(8) })
-	// case 0x34:
-	//	a.valueTag = TAG_BEGIN_COLLECTION	// reserved for definition in a future IETF standards track document
+		a.valueTagStr = "TAG_RANGE" // rangeOfInteger
+		var b rangeOfInteger
+		err = b.UnMarshalIPP(bts)
+		a.value = b
+	case 0x34:
+		a.valueTagStr = "TAG_BEGIN_COLLECTION" // begCollection, splitAValues reads the members
+		a.value = collection(nil)
 	case 0x35:
-		a.valueTag = TAG_TEXTLANG // textWithLanguage
-		a.valueTagStr = "TAG_TEXTLANG"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(textWithLanguage); return b.MarshalIPP() })
-		a.UnMarshal = (func([]byte) error { b := a.value.(textWithLanguage); return b.UnMarshalIPP(bts) })
-		a.Length = (func() uint16 { b := a.value.(textWithLanguage); return b.length() })
+		a.valueTagStr = "TAG_TEXTLANG" // textWithLanguage
+		var b textWithLanguage
+		err = b.UnMarshalIPP(bts)
+		a.value = b
 	case 0x36:
-		a.valueTag = TAG_NAMELANG // nameWithLanguage
-		a.valueTagStr = "TAG_NAMELANG"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(nameWithLanguage); return b.MarshalIPP() })
-		a.UnMarshal = (func([]byte) error { b := a.value.(nameWithLanguage); return b.UnMarshalIPP(bts) })
-		a.Length = (func() uint16 { b := a.value.(nameWithLanguage); return b.length() })
+		a.valueTagStr = "TAG_NAMELANG" // nameWithLanguage
+		var b nameWithLanguage
+		err = b.UnMarshalIPP(bts)
+		a.value = b
+	case 0x41:
+		a.valueTagStr = "TAG_TEXT" // textWithoutLanguage
+		a.value = textWithoutLanguage(append([]byte{}, bts...))
+	case 0x42:
+		a.valueTagStr = "TAG_NAME" // nameWithoutLanguage
+		a.value = nameWithoutLanguage(append([]byte{}, bts...))
+	case 0x44:
+		a.valueTagStr = "TAG_KEYWORD" // keyword
+		a.value = keyword(append([]byte{}, bts...))
+	case 0x45:
+		a.valueTagStr = "TAG_URI" // uri
+		a.value = uri(append([]byte{}, bts...))
+	case 0x46:
+		a.valueTagStr = "TAG_URISCHEME" // uriScheme
+		a.value = uriScheme(append([]byte{}, bts...))
 	case 0x47:
-		a.valueTag = TAG_CHARSET
-		a.valueTagStr = "TAG_CHARSET"
-		a.Marshal = (func() ([]byte, error) {b := a.value.(charset); return b.MarshalIPP()})
-		a.UnMarshal = (func(bts []byte) error {var b charset; b.UnMarshalIPP(bts); a.value = b; return nil})
-		a.Length = (func() uint16 {b := a.value.(charset); return uint16(b.len())})
-		a.String = (func() string {x := a.value.(charset); return x.String()})
+		a.valueTagStr = "TAG_CHARSET" // charset
+		a.value = charset(append([]byte{}, bts...))
 	case 0x48:
-		a.valueTag = TAG_LANGUAGE // textWithLanguage
-		a.valueTagStr = "TAG_LANGUAGE"
-		a.Marshal = (func() ([]byte, error) {b := a.value.(naturalLanguage); return b.MarshalIPP()})
-		// a.UnMarshal = (func([]byte) error {b := a.value.(naturalLanguage); return b.UnMarshalIPP(bts)})
-		a.UnMarshal = (func(bts []byte) error {var b naturalLanguage; b.UnMarshalIPP(bts); a.value = b; return nil})
-		a.Length = (func() uint16 {b := a.value.(naturalLanguage); return uint16(b.len())})
-		a.String = (func() string {x := a.value.(naturalLanguage); return x.String()})
-	case 0x44:
-		a.valueTag = TAG_KEYWORD // textWithLanguage
-		a.valueTagStr = "TAG_KEYWORD"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(keyword); return b.MarshalIPP() })
-		a.UnMarshal = (func(bts []byte) error {var b keyword; b.UnMarshalIPP(bts); a.value = b; return nil })
-		a.Length = (func() uint16 { b := a.value.(keyword); return uint16(b.len()) })
-		a.String = (func() string {x := a.value.(keyword); return x.String()})
-		// case 0x37:
-		//	a.valueTag = TAG_END_COLLECTION     
-		// 0x24-0x2F         reserved for integer types for definition in future IETF standards track documents 
+		a.valueTagStr = "TAG_LANGUAGE" // naturalLanguage
+		a.value = naturalLanguage(append([]byte{}, bts...))
 	case 0x49:
-		a.valueTag = TAG_MIMETYPE // textWithLanguage
-		a.valueTagStr = "TAG_MIMETYPE"
-		a.Marshal = (func() ([]byte, error) { b := a.value.(mimeMediaType); return b.MarshalIPP() })
-		a.UnMarshal = (func(bts []byte) error {var b mimeMediaType; b.UnMarshalIPP(bts); a.value = b; return nil })
-		a.Length = (func() uint16 { b := a.value.(mimeMediaType); return uint16(b.len()) })
-		a.String = (func() string {x := a.value.(mimeMediaType); return x.String()})
-	
+		a.valueTagStr = "TAG_MIMETYPE" // mimeMediaType
+		a.value = mimeMediaType(append([]byte{}, bts...))
+	case 0x4a:
+		a.valueTagStr = "TAG_MEMBERNAME" // memberAttrName
+		a.value = memberName(append([]byte{}, bts...))
+	case 0x10, 0x11, 0x12, 0x13, 0x15, 0x16, 0x17:
+		a.valueTagStr = "TAG_OUT_OF_BAND" // "out-of-band" values carry no value
+	default:
+		a.valueTagStr = "TAG_UNKNOWN_SYNTAX"
+		a.value = octets(append([]byte{}, bts...))
 	}
-
-	a.UnMarshal(bts)
+	if err != nil {
+		return a, fmt.Errorf("ipp: value-tag 0x%02x: %v", bi, err)
+	}
+	a.refer()
+	a.valueLength = a.Length()
 	return a, nil
 }
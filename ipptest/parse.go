@@ -0,0 +1,639 @@
+package ipptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"ipp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//   ipptool test files
+//
+//   A test file is a sequence of tokens separated by white space, '#' starts a comment that
+//   runs to the end of the line. Tokens may be quoted with '"' or '\'', a '\' escapes the next
+//   character. Braces are tokens of their own, they need no white space around them. Values of
+//   ATTR and WITH-VALUE are separated by commas outside quotes. "$name" and "${name}" are
+//   replaced by the variable name, "$ENV[name]" by an environment variable and "$$" by '$'.
+//   Every test is a block in braces:
+//
+//      {
+//          NAME "Print a PDF"
+//          OPERATION Print-Job
+//          GROUP operation-attributes-tag
+//          ATTR charset attributes-charset utf-8
+//          ATTR naturalLanguage attributes-natural-language en
+//          ATTR uri printer-uri $uri
+//          ATTR name requesting-user-name $user
+//          ATTR mimeMediaType document-format application/pdf
+//          GROUP job-attributes-tag
+//          ATTR collection media-col { MEMBER keyword media-size-name iso_a4_210x297mm }
+//          FILE document.pdf
+//          STATUS successful-ok
+//          EXPECT job-id OF-TYPE integer WITH-VALUE >0
+//          EXPECT !unsupported-attributes
+//      }
+//
+//   Outside of tests the directives are DEFINE name value, DEFINE-DEFAULT name value (unless
+//   name is defined), INCLUDE "file" or <file> (relative to the including file),
+//   INCLUDE-IF-DEFINED and INCLUDE-IF-NOT-DEFINED name file, IGNORE-ERRORS yes|no and
+//   VERSION 1.1|2.0. Inside a test:
+//
+//      NAME text               the name of the test in the report
+//      OPERATION name          the operation, by name or number
+//      RESOURCE path           the HTTP resource, the path of the printer uri by default
+//      VERSION 1.1             the version-number of the request
+//      GROUP tag               starts an attribute group, ATTRs before the first GROUP are
+//                              operation attributes
+//      ATTR tag name values    adds an attribute, out-of-band tags have no values,
+//                              collections are { MEMBER tag name values ... }, separated by ','
+//      FILE filename           the document data
+//      STATUS status-code      the status-code expected, any of several, successful-ok* if none
+//      EXPECT [!|?]name        the response has (!: has not, ?: may have) the attribute
+//      DISPLAY name            reports the values of the attribute
+//      DELAY s[,repeat-s]      waits before sending the request and before repeating it
+//      IGNORE-ERRORS yes|no    goes on with the next test after this test failed
+//      SKIP-IF-DEFINED name, SKIP-IF-NOT-DEFINED name
+//      DEFINE name value
+//
+//   STATUS and EXPECT are followed by their modifiers: IF-DEFINED name, IF-NOT-DEFINED name,
+//   REPEAT-MATCH, REPEAT-NO-MATCH, REPEAT-LIMIT n, DEFINE-MATCH name and DEFINE-NO-MATCH name,
+//   EXPECT also by OF-TYPE tag[|tag...], IN-GROUP tag, WITH-VALUE value, WITH-ALL-VALUES value,
+//   COUNT n, SAME-COUNT-AS name and DEFINE-VALUE name.
+
+//	File is a parsed test file
+type File struct {
+	Name  string // the file name
+	Steps []Step
+}
+
+//	Step is a test or one of the other directives of a file, in the order they are run
+type Step struct {
+	Line      int
+	Test      *Test
+	Directive string   // DEFINE, DEFINE-DEFAULT, INCLUDE, INCLUDE-IF-DEFINED, ... if Test is nil
+	Args      []string // the arguments of Directive, e.g. the name and value of DEFINE
+	Include   *File    // the file of the INCLUDE directives
+}
+
+//	Test is a { ... } block
+type Test struct {
+	Line             int
+	Name             string
+	Operation        string
+	Resource         string
+	Version          string
+	Groups           []Group
+	Document         string // FILE
+	Statuses         []Status
+	Expects          []Expect
+	Display          []string
+	Delay            string
+	IgnoreErrors     string
+	SkipIfDefined    string
+	SkipIfNotDefined string
+	Defines          [][2]string // DEFINE name value
+}
+
+//	Group is an attribute group of a request
+type Group struct {
+	Tag   string // e.g. "operation-attributes-tag" or "job"
+	Attrs []Attr
+}
+
+//	Attr is an ATTR or a collection MEMBER
+type Attr struct {
+	Tag    string
+	Name   string
+	Values []Value
+}
+
+//	Value is a value of an Attr, the text of the value or the members of a collection
+type Value struct {
+	Text       string
+	Collection bool
+	Members    []Attr
+}
+
+//	Action holds the modifiers of STATUS and EXPECT that decide what happens after matching
+type Action struct {
+	IfDefined     string
+	IfNotDefined  string
+	RepeatMatch   bool
+	RepeatNoMatch bool
+	RepeatLimit   int // 0 is 1000
+	DefineMatch   string
+	DefineNoMatch string
+}
+
+//	Status is a STATUS directive
+type Status struct {
+	Code string
+	Action
+}
+
+//	Expect is an EXPECT directive
+type Expect struct {
+	Name        string
+	Absent      bool // !name
+	Optional    bool // ?name
+	OfType      string
+	InGroup     string
+	WithValue   string
+	AllValues   bool // WITH-ALL-VALUES
+	Count       int  // 0 if there is no COUNT
+	SameCountAs string
+	DefineValue string
+	Action
+}
+
+//	How deep INCLUDEs may be nested
+const maxIncludeDepth = 10
+
+//	Parses the test file at path
+func ParseFile(path string) (*File, error) {
+	return parseFile(path, 0)
+}
+
+func parseFile(path string, depth int) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f, path, depth)
+}
+
+//	Parses a test file read from r, name is the file name of INCLUDEs are relative to
+func Parse(r io.Reader, name string) (*File, error) {
+	return parse(r, name, 0)
+}
+
+func parse(r io.Reader, name string, depth int) (*File, error) {
+	p := &parser{lex: lexer{r: bufio.NewReader(r), line: 1}, name: name, depth: depth}
+	f := &File{Name: name}
+	for {
+		tok, err := p.next()
+		if err == io.EOF {
+			return f, nil
+		} else if err != nil {
+			return nil, err
+		}
+		step := Step{Line: tok.line}
+		if tok.delim && tok.text == "{" {
+			if step.Test, err = p.test(tok.line); err != nil {
+				return nil, err
+			}
+			f.Steps = append(f.Steps, step)
+			continue
+		}
+		step.Directive = strings.ToUpper(tok.text)
+		switch step.Directive {
+		case "DEFINE", "DEFINE-DEFAULT":
+			step.Args, err = p.args(2)
+		case "IGNORE-ERRORS", "VERSION":
+			step.Args, err = p.args(1)
+		case "INCLUDE", "INCLUDE-IF-DEFINED", "INCLUDE-IF-NOT-DEFINED":
+			n := 2
+			if step.Directive == "INCLUDE" {
+				n = 1
+			}
+			if step.Args, err = p.args(n); err == nil {
+				step.Include, err = p.include(step.Line, step.Args[n-1])
+			}
+		default:
+			return nil, p.errorf(tok.line, "unknown directive %s", tok.text)
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.Steps = append(f.Steps, step)
+	}
+}
+
+type parser struct {
+	lex   lexer
+	name  string
+	depth int
+}
+
+func (p *parser) errorf(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) next() (token, error) {
+	tok, err := p.lex.next()
+	if err != nil && err != io.EOF {
+		return tok, p.errorf(p.lex.line, "%v", err)
+	}
+	return tok, err
+}
+
+//	Reads the n arguments of a directive
+func (p *parser) args(n int) ([]string, error) {
+	var args []string
+	for len(args) < n {
+		tok, err := p.next()
+		if err == io.EOF || tok.delim {
+			return nil, p.errorf(p.lex.line, "missing argument")
+		} else if err != nil {
+			return nil, err
+		}
+		args = append(args, tok.text)
+	}
+	return args, nil
+}
+
+//	Reads one argument and splits it at the commas
+func (p *parser) list() ([]string, error) {
+	tok, err := p.next()
+	if err == io.EOF || tok.delim {
+		return nil, p.errorf(p.lex.line, "missing argument")
+	}
+	return tok.parts, err
+}
+
+//	Parses an included file, "<file>" and "file" are both relative to the including file
+func (p *parser) include(line int, name string) (*File, error) {
+	if p.depth >= maxIncludeDepth {
+		return nil, p.errorf(line, "INCLUDEs are nested too deep")
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "<"), ">")
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(filepath.Dir(p.name), name)
+	}
+	f, err := parseFile(name, p.depth+1)
+	if _, ok := err.(*os.PathError); ok {
+		return nil, p.errorf(line, "INCLUDE: %v", err)
+	}
+	return f, err
+}
+
+//	Parses a test after its '{'
+func (p *parser) test(line int) (*Test, error) {
+	t := &Test{Line: line}
+	var group *Group
+	var action *Action // the STATUS or EXPECT that modifiers apply to
+	var expect *Expect
+	for {
+		tok, err := p.next()
+		if err == io.EOF {
+			return nil, p.errorf(line, "missing '}'")
+		} else if err != nil {
+			return nil, err
+		}
+		if tok.delim {
+			if tok.text == "}" {
+				return t, nil
+			}
+			return nil, p.errorf(tok.line, "unexpected '%s'", tok.text)
+		}
+		var arg []string
+		switch directive := strings.ToUpper(tok.text); directive {
+		case "NAME", "OPERATION", "RESOURCE", "VERSION", "FILE", "DELAY", "IGNORE-ERRORS", "SKIP-IF-DEFINED", "SKIP-IF-NOT-DEFINED", "DISPLAY":
+			if arg, err = p.args(1); err != nil {
+				return nil, err
+			}
+			switch directive {
+			case "NAME":
+				t.Name = arg[0]
+			case "OPERATION":
+				t.Operation = arg[0]
+			case "RESOURCE":
+				t.Resource = arg[0]
+			case "VERSION":
+				t.Version = arg[0]
+			case "FILE":
+				t.Document = arg[0]
+			case "DELAY":
+				t.Delay = arg[0]
+			case "IGNORE-ERRORS":
+				t.IgnoreErrors = arg[0]
+			case "SKIP-IF-DEFINED":
+				t.SkipIfDefined = arg[0]
+			case "SKIP-IF-NOT-DEFINED":
+				t.SkipIfNotDefined = arg[0]
+			case "DISPLAY":
+				t.Display = append(t.Display, arg[0])
+			}
+		case "DEFINE":
+			if arg, err = p.args(2); err != nil {
+				return nil, err
+			}
+			t.Defines = append(t.Defines, [2]string{arg[0], arg[1]})
+		case "GROUP":
+			if arg, err = p.args(1); err != nil {
+				return nil, err
+			}
+			if _, ok := ipp.ParseTag(arg[0]); !ok {
+				return nil, p.errorf(tok.line, "unknown group %s", arg[0])
+			}
+			t.Groups = append(t.Groups, Group{Tag: arg[0]})
+			group = &t.Groups[len(t.Groups)-1]
+		case "ATTR":
+			if group == nil {
+				t.Groups = append(t.Groups, Group{Tag: "operation-attributes-tag"})
+				group = &t.Groups[len(t.Groups)-1]
+			}
+			a, err := p.attr(tok.line)
+			if err != nil {
+				return nil, err
+			}
+			group.Attrs = append(group.Attrs, a)
+		case "STATUS":
+			if arg, err = p.args(1); err != nil {
+				return nil, err
+			}
+			if _, ok := ipp.ParseStatusCode(arg[0]); !ok {
+				return nil, p.errorf(tok.line, "unknown status-code %s", arg[0])
+			}
+			t.Statuses = append(t.Statuses, Status{Code: arg[0]})
+			action, expect = &t.Statuses[len(t.Statuses)-1].Action, nil
+		case "EXPECT":
+			if arg, err = p.args(1); err != nil {
+				return nil, err
+			}
+			e := Expect{Name: arg[0]}
+			switch {
+			case strings.HasPrefix(e.Name, "!"):
+				e.Name, e.Absent = e.Name[1:], true
+			case strings.HasPrefix(e.Name, "?"):
+				e.Name, e.Optional = e.Name[1:], true
+			}
+			t.Expects = append(t.Expects, e)
+			expect = &t.Expects[len(t.Expects)-1]
+			action = &expect.Action
+		default:
+			if err := p.modifier(tok, action, expect); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+//	Parses a modifier of the last STATUS or EXPECT
+func (p *parser) modifier(tok token, action *Action, expect *Expect) error {
+	modifier := strings.ToUpper(tok.text)
+	if action == nil {
+		return p.errorf(tok.line, "unknown directive %s", tok.text)
+	}
+	var err error
+	var arg []string
+	switch modifier {
+	case "REPEAT-MATCH":
+		action.RepeatMatch = true
+		return nil
+	case "REPEAT-NO-MATCH":
+		action.RepeatNoMatch = true
+		return nil
+	case "IF-DEFINED", "IF-NOT-DEFINED", "DEFINE-MATCH", "DEFINE-NO-MATCH", "REPEAT-LIMIT":
+		if arg, err = p.args(1); err != nil {
+			return err
+		}
+		switch modifier {
+		case "IF-DEFINED":
+			action.IfDefined = arg[0]
+		case "IF-NOT-DEFINED":
+			action.IfNotDefined = arg[0]
+		case "DEFINE-MATCH":
+			action.DefineMatch = arg[0]
+		case "DEFINE-NO-MATCH":
+			action.DefineNoMatch = arg[0]
+		case "REPEAT-LIMIT":
+			if action.RepeatLimit, err = strconv.Atoi(arg[0]); err != nil || action.RepeatLimit < 1 {
+				return p.errorf(tok.line, "invalid REPEAT-LIMIT %s", arg[0])
+			}
+		}
+		return nil
+	}
+	if expect == nil {
+		return p.errorf(tok.line, "unknown directive %s", tok.text)
+	}
+	switch modifier {
+	case "WITH-VALUE", "WITH-ALL-VALUES":
+		// regular expressions may contain commas, the value is not split
+		if arg, err = p.args(1); err != nil {
+			return err
+		}
+		expect.WithValue, expect.AllValues = arg[0], modifier == "WITH-ALL-VALUES"
+	case "OF-TYPE", "IN-GROUP", "COUNT", "SAME-COUNT-AS", "DEFINE-VALUE":
+		if arg, err = p.args(1); err != nil {
+			return err
+		}
+		switch modifier {
+		case "OF-TYPE":
+			for _, name := range strings.Split(arg[0], "|") {
+				if _, ok := ipp.ParseTag(name); !ok {
+					return p.errorf(tok.line, "unknown tag %s", name)
+				}
+			}
+			expect.OfType = arg[0]
+		case "IN-GROUP":
+			if _, ok := ipp.ParseTag(arg[0]); !ok {
+				return p.errorf(tok.line, "unknown group %s", arg[0])
+			}
+			expect.InGroup = arg[0]
+		case "COUNT":
+			if expect.Count, err = strconv.Atoi(arg[0]); err != nil || expect.Count < 1 {
+				return p.errorf(tok.line, "invalid COUNT %s", arg[0])
+			}
+		case "SAME-COUNT-AS":
+			expect.SameCountAs = arg[0]
+		case "DEFINE-VALUE":
+			expect.DefineValue = arg[0]
+		}
+	default:
+		return p.errorf(tok.line, "unknown directive %s", tok.text)
+	}
+	return nil
+}
+
+//	Parses the tag, name and values of an ATTR or MEMBER
+func (p *parser) attr(line int) (Attr, error) {
+	args, err := p.args(2)
+	if err != nil {
+		return Attr{}, err
+	}
+	a := Attr{Tag: args[0], Name: args[1]}
+	tag, ok := ipp.ParseTag(a.Tag)
+	switch {
+	case !ok:
+		return a, p.errorf(line, "unknown tag %s", a.Tag)
+	case tag == ipp.TAG_BEGIN_COLLECTION:
+		return a, p.collections(&a)
+	case tag >= ipp.TAG_UNSUPPORTED_VALUE && tag < ipp.TAG_INTEGER:
+		// out-of-band values have no value
+		return a, nil
+	}
+	values, err := p.list()
+	for _, v := range values {
+		a.Values = append(a.Values, Value{Text: v})
+	}
+	return a, err
+}
+
+//	Parses the collection values of a, { MEMBER ... } separated by ','
+func (p *parser) collections(a *Attr) error {
+	for {
+		tok, err := p.next()
+		if err != nil || !tok.delim || tok.text != "{" {
+			return p.errorf(p.lex.line, "missing '{' of collection %s", a.Name)
+		}
+		v := Value{Collection: true}
+		for {
+			tok, err = p.next()
+			if err != nil {
+				return p.errorf(p.lex.line, "missing '}' of collection %s", a.Name)
+			}
+			if tok.delim && tok.text == "}" {
+				break
+			}
+			if strings.ToUpper(tok.text) != "MEMBER" {
+				return p.errorf(tok.line, "expected MEMBER in collection %s, got %s", a.Name, tok.text)
+			}
+			m, err := p.attr(tok.line)
+			if err != nil {
+				return err
+			}
+			v.Members = append(v.Members, m)
+		}
+		a.Values = append(a.Values, v)
+		if !p.lex.comma() {
+			return nil
+		}
+	}
+}
+
+// ========== lexer ==========
+
+type token struct {
+	text  string   // the token without quotes and escapes
+	parts []string // text split at the commas outside quotes
+	delim bool     // '{' or '}'
+	line  int
+}
+
+type lexer struct {
+	r    *bufio.Reader
+	line int
+}
+
+//	Returns the next token, io.EOF after the last
+func (l *lexer) next() (token, error) {
+	c, err := l.skip()
+	if err != nil {
+		return token{}, err
+	}
+	tok := token{line: l.line}
+	if c == '{' || c == '}' {
+		tok.text, tok.delim = string(c), true
+		return tok, nil
+	}
+	var text, part strings.Builder
+	variable := false
+	for {
+		switch {
+		case c == '"' || c == '\'':
+			quote := c
+			for {
+				if c, err = l.read(); err != nil {
+					return tok, errors.New("unterminated string")
+				}
+				if c == quote {
+					break
+				}
+				if c == '\\' {
+					if c, err = l.read(); err != nil {
+						return tok, errors.New("unterminated string")
+					}
+				}
+				text.WriteRune(c)
+				part.WriteRune(c)
+			}
+		case c == '\\':
+			if c, err = l.read(); err != nil {
+				return tok, errors.New("'\\' at the end of the file")
+			}
+			text.WriteRune(c)
+			part.WriteRune(c)
+		case c == ',':
+			text.WriteRune(c)
+			tok.parts = append(tok.parts, part.String())
+			part.Reset()
+		default:
+			text.WriteRune(c)
+			part.WriteRune(c)
+		}
+		prev := c
+		c, err = l.read()
+		// a brace ends the token and is the next one, e.g. in {GROUP job-attributes-tag}, but
+		// for those of "${name}"
+		delim := false
+		switch {
+		case err != nil:
+		case c == '{' && prev == '$':
+			variable = true
+		case c == '}' && variable:
+			variable = false
+		case c == '{' || c == '}':
+			delim = true
+			l.r.UnreadRune()
+		}
+		if err == io.EOF || err == nil && (isSpace(c) || delim) {
+			tok.text = text.String()
+			tok.parts = append(tok.parts, part.String())
+			return tok, nil
+		} else if err != nil {
+			return tok, err
+		}
+	}
+}
+
+//	Skips white space and comments and returns the first character of the next token
+func (l *lexer) skip() (rune, error) {
+	for {
+		c, err := l.read()
+		if err != nil {
+			return 0, err
+		}
+		if c == '#' {
+			for c != '\n' {
+				if c, err = l.read(); err != nil {
+					return 0, err
+				}
+			}
+		}
+		if !isSpace(c) {
+			return c, nil
+		}
+	}
+}
+
+//	Reads a ',' following a collection value, returns false if the next token is no ','
+func (l *lexer) comma() bool {
+	c, err := l.skip()
+	if err != nil {
+		return false
+	}
+	if c != ',' {
+		l.r.UnreadRune()
+		return false
+	}
+	return true
+}
+
+func (l *lexer) read() (rune, error) {
+	c, _, err := l.r.ReadRune()
+	if c == '\n' {
+		l.line++
+	}
+	return c, err
+}
+
+func isSpace(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,177 @@
+package ipptest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//	Result is the outcome of a test
+type Result struct {
+	File      string // the file given to Runner.Run, also for the tests of included files
+	Line      int
+	Name      string // NAME, the operation if there is none
+	Operation string
+	Status    string   // the status-code of the response, "" if no response was received
+	Skipped   bool     // SKIP-IF-DEFINED or SKIP-IF-NOT-DEFINED
+	Failures  []string // why the test failed, e.g. "EXPECTED: job-id"
+	Displayed []string // the DISPLAY attributes, e.g. "printer-state (enum) = 3"
+	Duration  time.Duration
+}
+
+//	Returns true if the test ran and everything matched
+func (r Result) Passed() bool {
+	return !r.Skipped && len(r.Failures) == 0
+}
+
+//	Report collects the results of test files for WriteText or WriteJUnit
+type Report struct {
+	Results []Result
+}
+
+//	Adds the results of a file, see Runner.Run
+func (rep *Report) Add(results ...Result) {
+	rep.Results = append(rep.Results, results...)
+}
+
+//	Returns the number of tests that passed, failed and were skipped
+func (rep *Report) Counts() (passed, failed, skipped int) {
+	for _, r := range rep.Results {
+		switch {
+		case r.Skipped:
+			skipped++
+		case r.Passed():
+			passed++
+		default:
+			failed++
+		}
+	}
+	return
+}
+
+//	Writes the results like ipptool: the quoted name of each file, a line per test that ends
+//	in [PASS], [FAIL] or [SKIP] followed by the failures and DISPLAY attributes, and a summary
+func (rep *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	file := ""
+	for i, r := range rep.Results {
+		if i == 0 || r.File != file {
+			file = r.File
+			fmt.Fprintf(&b, "%q:\n", file)
+		}
+		result := "FAIL"
+		switch {
+		case r.Skipped:
+			result = "SKIP"
+		case r.Passed():
+			result = "PASS"
+		}
+		fmt.Fprintf(&b, "    %-68.68s [%s]\n", r.Name, result)
+		if !r.Passed() && r.Status != "" {
+			fmt.Fprintf(&b, "        RECEIVED: status-code = %s\n", r.Status)
+		}
+		for _, f := range r.Failures {
+			fmt.Fprintf(&b, "        %s\n", f)
+		}
+		for _, d := range r.Displayed {
+			fmt.Fprintf(&b, "        %s\n", d)
+		}
+	}
+	passed, failed, skipped := rep.Counts()
+	fmt.Fprintf(&b, "Summary: %d tests, %d passed, %d failed, %d skipped.\n", len(rep.Results), passed, failed, skipped)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ========== JUnit XML ==========
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+//	Writes the results as JUnit XML for CI systems, a testsuite per file with a testcase per test
+func (rep *Report) WriteJUnit(w io.Writer) error {
+	var suites junitSuites
+	var total time.Duration
+	index := make(map[string]int)
+	durations := make(map[string]time.Duration)
+	for _, r := range rep.Results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(suites.Suites)
+			index[r.File] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: r.File})
+		}
+		suite := &suites.Suites[i]
+		c := junitCase{Name: r.Name, Classname: r.File, Time: seconds(r.Duration)}
+		switch {
+		case r.Skipped:
+			c.Skipped = &struct{}{}
+			suite.Skipped++
+		case !r.Passed():
+			text := r.Failures
+			if r.Status != "" {
+				text = append([]string{"RECEIVED: status-code = " + r.Status}, text...)
+			}
+			c.Failure = &junitFailure{Message: r.Failures[0], Text: strings.Join(text, "\n")}
+			suite.Failures++
+		}
+		c.SystemOut = strings.Join(r.Displayed, "\n")
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+		durations[r.File] += r.Duration
+		total += r.Duration
+	}
+	for i := range suites.Suites {
+		s := &suites.Suites[i]
+		s.Time = seconds(durations[s.Name])
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Skipped += s.Skipped
+	}
+	suites.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,631 @@
+package ipptest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"ipp"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//	How often a test is repeated by REPEAT-MATCH and REPEAT-NO-MATCH unless REPEAT-LIMIT is given
+const defaultRepeatLimit = 1000
+
+//	Runner runs test files against a printer with an ipp.CupsServer client
+type Runner struct {
+	Uri          string            // the printer uri, $uri in the test files
+	Vars         map[string]string // variables defined for every file, e.g. "filename"
+	IgnoreErrors bool              // runs the tests after a failed test, unless a file says otherwise
+	client       ipp.CupsServer
+	resource     string // the path of Uri
+}
+
+//	Returns a Runner for the printer at printerUri, e.g. "ipp://localhost:8631/ipp/print".
+//	Credentials in the uri are used for HTTP Basic authentication.
+func NewRunner(printerUri string) (*Runner, error) {
+	u, err := url.Parse(printerUri)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("ipptest: invalid printer uri %q", printerUri)
+	}
+	if u.Scheme != "ipp" && u.Scheme != "http" {
+		return nil, fmt.Errorf("ipptest: %s uris are not supported", u.Scheme)
+	}
+	r := &Runner{Uri: printerUri, Vars: make(map[string]string), resource: u.Path}
+	if r.resource == "" {
+		r.resource = "/"
+	}
+	r.client.SetServer(u.Host)
+	if u.User != nil {
+		password, _ := u.User.Password()
+		r.client.SetUser(u.User.Username(), password)
+	}
+	return r, nil
+}
+
+//	The variables of a file while its tests run
+type state struct {
+	file         string // the name of the file the results are reported for
+	vars         map[string]string
+	ignoreErrors bool
+	version      string
+	start        time.Time
+}
+
+//	Runs the tests of f and returns their results. A failed test ends the file unless its
+//	errors are ignored (IGNORE-ERRORS, Runner.IgnoreErrors).
+func (r *Runner) Run(f *File) []Result {
+	s := &state{file: f.Name, vars: r.variables(), ignoreErrors: r.IgnoreErrors, start: time.Now()}
+	var results []Result
+	r.run(f, s, &results)
+	return results
+}
+
+//	Returns the predefined variables
+func (r *Runner) variables() map[string]string {
+	vars := map[string]string{"uri": r.Uri, "resource": r.resource}
+	if u, err := url.Parse(r.Uri); err == nil {
+		vars["scheme"] = u.Scheme
+		vars["hostname"] = u.Hostname()
+		vars["port"] = u.Port()
+		if vars["port"] == "" {
+			vars["port"] = strconv.Itoa(ipp.PORT)
+		}
+		if u.User != nil {
+			vars["uriuser"] = u.User.Username()
+		}
+	}
+	vars["user"] = vars["uriuser"]
+	if vars["user"] == "" {
+		vars["user"] = "anonymous"
+		if u, err := user.Current(); err == nil {
+			vars["user"] = u.Username
+		}
+	}
+	for name, value := range r.Vars {
+		vars[name] = value
+	}
+	if name := vars["filename"]; name != "" {
+		vars["basename"] = filepath.Base(name)
+		if b, err := ioutil.ReadFile(name); err == nil && vars["filetype"] == "" {
+			vars["filetype"] = ipp.DetectFormat(b)
+		}
+	}
+	return vars
+}
+
+//	Runs the steps of f, returns false if a failed test ends the file
+func (r *Runner) run(f *File, s *state, results *[]Result) bool {
+	for _, step := range f.Steps {
+		if step.Test != nil {
+			res := r.test(f, step.Test, s)
+			*results = append(*results, res)
+			ignore := s.ignoreErrors
+			if step.Test.IgnoreErrors != "" {
+				ignore = yes(s.expand(step.Test.IgnoreErrors))
+			}
+			if !res.Passed() && !res.Skipped && !ignore {
+				return false
+			}
+			continue
+		}
+		args := make([]string, len(step.Args))
+		for i, a := range step.Args {
+			args[i] = s.expand(a)
+		}
+		switch step.Directive {
+		case "DEFINE":
+			s.vars[args[0]] = args[1]
+		case "DEFINE-DEFAULT":
+			if _, ok := s.vars[args[0]]; !ok {
+				s.vars[args[0]] = args[1]
+			}
+		case "IGNORE-ERRORS":
+			s.ignoreErrors = yes(args[0])
+		case "VERSION":
+			s.version = args[0]
+		case "INCLUDE":
+			if !r.run(step.Include, s, results) {
+				return false
+			}
+		case "INCLUDE-IF-DEFINED", "INCLUDE-IF-NOT-DEFINED":
+			_, defined := s.vars[args[0]]
+			if defined == (step.Directive == "INCLUDE-IF-DEFINED") && !r.run(step.Include, s, results) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func yes(s string) bool {
+	return strings.EqualFold(s, "yes") || strings.EqualFold(s, "true")
+}
+
+//	Runs a test, repeating it as long as a REPEAT-MATCH or REPEAT-NO-MATCH asks for it
+func (r *Runner) test(f *File, t *Test, s *state) (res Result) {
+	for _, d := range t.Defines {
+		s.vars[d[0]] = s.expand(d[1])
+	}
+	res = Result{File: s.file, Line: t.Line, Name: s.expand(t.Name), Operation: s.expand(t.Operation)}
+	if res.Name == "" {
+		res.Name = res.Operation
+	}
+	if t.SkipIfDefined != "" && s.defined(t.SkipIfDefined) || t.SkipIfNotDefined != "" && !s.defined(t.SkipIfNotDefined) {
+		res.Skipped = true
+		return res
+	}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+	m, resource, err := r.request(f, t, s)
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+		return res
+	}
+	delay, repeatDelay, err := parseDelay(s.expand(t.Delay))
+	if err != nil {
+		res.Failures = append(res.Failures, err.Error())
+		return res
+	}
+	time.Sleep(delay)
+	for n := 1; ; n++ {
+		resp, err := r.client.DoRequestTo(m, resource)
+		if _, ok := err.(*ipp.StatusError); err != nil && !ok {
+			res.Failures = append(res.Failures, "request failed: "+err.Error())
+			return res
+		}
+		res.Status = ipp.StatusCodeString(resp.StatusCode())
+		s.responseVariables(resp)
+		failures, limit := s.check(t, resp)
+		if limit > n {
+			time.Sleep(repeatDelay)
+			continue
+		}
+		res.Failures = failures
+		for _, name := range t.Display {
+			if a := lookup(resp, s.expand(name)); a.found {
+				res.Displayed = append(res.Displayed, a.display())
+			}
+		}
+		return res
+	}
+}
+
+//	Parses DELAY seconds[,repeat-seconds], the repeat delay defaults to the delay
+func parseDelay(s string) (time.Duration, time.Duration, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	var d [2]time.Duration
+	for i, f := range strings.SplitN(s, ",", 2) {
+		seconds, err := strconv.ParseFloat(f, 64)
+		if err != nil || seconds < 0 {
+			return 0, 0, fmt.Errorf("invalid DELAY %s", s)
+		}
+		d[i] = time.Duration(seconds * float64(time.Second))
+	}
+	if !strings.Contains(s, ",") {
+		d[1] = d[0]
+	}
+	return d[0], d[1], nil
+}
+
+//	Builds the request of a test and returns it with the resource it is sent to
+func (r *Runner) request(f *File, t *Test, s *state) (ipp.Message, string, error) {
+	op, ok := ipp.ParseOperation(s.expand(t.Operation))
+	if t.Operation == "" || !ok {
+		return ipp.Message{}, "", fmt.Errorf("missing or unknown OPERATION %s", t.Operation)
+	}
+	m := ipp.NewRequest(op)
+	version := s.version
+	if t.Version != "" {
+		version = s.expand(t.Version)
+	}
+	if version != "" {
+		var major, minor int8
+		if _, err := fmt.Sscanf(version, "%d.%d", &major, &minor); err != nil {
+			return m, "", fmt.Errorf("invalid VERSION %s", version)
+		}
+		m.SetVersion(major, minor)
+	}
+	for _, g := range t.Groups {
+		tag, _ := ipp.ParseTag(g.Tag)
+		m.AddGroup(tag)
+		for _, attr := range g.Attrs {
+			a := ipp.NewAttribute()
+			if err := s.addValues(a.AddValue, attr); err != nil {
+				return m, "", err
+			}
+			m.AppendGroupAttribute(tag, a)
+		}
+	}
+	if t.Document != "" {
+		name := s.expand(t.Document)
+		// FILE is relative to the test file, $filename to the working directory
+		if rel := filepath.Join(filepath.Dir(f.Name), name); !filepath.IsAbs(name) && exists(rel) {
+			name = rel
+		}
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return m, "", err
+		}
+		m.Data = data
+	}
+	resource := r.resource
+	if t.Resource != "" {
+		resource = s.expand(t.Resource)
+	}
+	return m, resource, nil
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+//	Adds the values of attr with add, the AddValue of an attribute or collection member
+func (s *state) addValues(add func(byte, string, interface{}), attr Attr) error {
+	tag, _ := ipp.ParseTag(attr.Tag)
+	if len(attr.Values) == 0 {
+		add(tag, attr.Name, nil)
+		return nil
+	}
+	for _, v := range attr.Values {
+		if e, ok := ipp.ParseEnum(attr.Name, s.expand(v.Text)); ok && tag == ipp.TAG_ENUM && !v.Collection {
+			add(tag, attr.Name, ipp.Enum(e))
+			continue
+		}
+		value, err := s.value(tag, v)
+		if err != nil {
+			return fmt.Errorf("ATTR %s %s: %v", attr.Tag, attr.Name, err)
+		}
+		add(tag, attr.Name, value)
+	}
+	return nil
+}
+
+func (s *state) value(tag byte, v Value) (interface{}, error) {
+	if !v.Collection {
+		return ipp.ParseValue(tag, s.expand(v.Text))
+	}
+	c := ipp.Collection()
+	for _, m := range v.Members {
+		member := ipp.NewAttribute()
+		if err := s.addValues(member.AddValue, m); err != nil {
+			return nil, err
+		}
+		c = append(c, member)
+	}
+	return c, nil
+}
+
+//	Remembers the job-id, job-uri and notify-subscription-id of a response like ipptool
+func (s *state) responseVariables(resp ipp.Message) {
+	for _, name := range []string{"job-id", "job-uri", "notify-subscription-id"} {
+		if a := lookup(resp, name); a.found && len(a.values) > 0 {
+			s.vars[name] = a.values[0]
+		}
+	}
+}
+
+func (s *state) defined(name string) bool {
+	_, ok := s.vars[s.expand(name)]
+	return ok
+}
+
+//	Returns text with the variables replaced by their values
+func (s *state) expand(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '$' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		rest := text[i+1:]
+		var name string
+		switch {
+		case rest[0] == '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case strings.HasPrefix(rest, "ENV[") && strings.IndexByte(rest, ']') > 0:
+			end := strings.IndexByte(rest, ']')
+			b.WriteString(os.Getenv(rest[4:end]))
+			i += end + 1
+			continue
+		case rest[0] == '{' && strings.IndexByte(rest, '}') > 0:
+			end := strings.IndexByte(rest, '}')
+			name = rest[1:end]
+			i += end + 1
+		default:
+			n := 0
+			for n < len(rest) && (isAlnum(rest[n]) || rest[n] == '-' || rest[n] == '_') {
+				n++
+			}
+			name = rest[:n]
+			i += n
+		}
+		b.WriteString(s.variable(name))
+	}
+	return b.String()
+}
+
+func isAlnum(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func (s *state) variable(name string) string {
+	switch name {
+	case "date-current":
+		return time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	case "date-start":
+		return s.start.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return s.vars[name]
+}
+
+// ========== expectations ==========
+
+//	attr is an attribute of a response as far as expectations look at it
+type attr struct {
+	found  bool
+	name   string
+	group  byte
+	tags   []byte
+	values []string
+}
+
+//	Returns the first attribute of a response named name
+func lookup(m ipp.Message, name string) attr {
+	for _, g := range m.Groups() {
+		for _, a := range g.Attributes() {
+			if a.Name() == name {
+				return attr{found: true, name: name, group: g.Tag(), tags: a.Tags(), values: a.Strings()}
+			}
+		}
+	}
+	return attr{name: name}
+}
+
+//	Returns the attribute as reported for DISPLAY, e.g. "sides-supported (1setOf keyword) = one-sided,two-sided-long-edge"
+func (a attr) display() string {
+	syntax := ipp.TagName(a.tags[0])
+	if len(a.values) > 1 {
+		syntax = "1setOf " + syntax
+	}
+	return a.name + " (" + syntax + ") = " + strings.Join(a.values, ",")
+}
+
+//	Returns true if the STATUS or EXPECT applies, see IF-DEFINED and IF-NOT-DEFINED
+func (s *state) applies(a Action) bool {
+	return (a.IfDefined == "" || s.defined(a.IfDefined)) && (a.IfNotDefined == "" || !s.defined(a.IfNotDefined))
+}
+
+//	Sets the variables of DEFINE-MATCH and DEFINE-NO-MATCH, returns the repeat limit if the
+//	test is to be repeated and 0 if not
+func (s *state) matched(a Action, ok bool) int {
+	if ok && a.DefineMatch != "" {
+		s.vars[s.expand(a.DefineMatch)] = "1"
+	}
+	if !ok && a.DefineNoMatch != "" {
+		s.vars[s.expand(a.DefineNoMatch)] = "1"
+	}
+	if ok && a.RepeatMatch || !ok && a.RepeatNoMatch {
+		if a.RepeatLimit > 0 {
+			return a.RepeatLimit
+		}
+		return defaultRepeatLimit
+	}
+	return 0
+}
+
+//	Returns true if a failed STATUS or EXPECT only defines a variable and does not fail the test
+func (a Action) defines() bool {
+	return a.DefineMatch != "" || a.DefineNoMatch != ""
+}
+
+//	Checks the response against the STATUS and EXPECT directives of a test. Returns why the
+//	test failed and, when a directive asks to repeat the test, how often it may run.
+func (s *state) check(t *Test, resp ipp.Message) ([]string, int) {
+	var failures []string
+	limit := 0
+	repeat := func(n int) {
+		if n > limit {
+			limit = n
+		}
+	}
+	code := resp.StatusCode()
+	applicable, required, matched := 0, 0, false
+	var want []string
+	for _, st := range t.Statuses {
+		if !s.applies(st.Action) {
+			continue
+		}
+		applicable++
+		c, _ := ipp.ParseStatusCode(s.expand(st.Code))
+		repeat(s.matched(st.Action, c == code))
+		if !st.defines() {
+			required++
+			want = append(want, st.Code)
+			matched = matched || c == code
+		}
+	}
+	switch {
+	case applicable == 0 && !ipp.IsSuccessful(code):
+		failures = append(failures, "EXPECTED: STATUS successful-ok (got "+ipp.StatusCodeString(code)+")")
+	case required > 0 && !matched:
+		failures = append(failures, "EXPECTED: STATUS "+strings.Join(want, " or ")+" (got "+ipp.StatusCodeString(code)+")")
+	}
+	for _, e := range t.Expects {
+		if !s.applies(e.Action) {
+			continue
+		}
+		a := lookup(resp, s.expand(e.Name))
+		failure := s.expect(e, a, resp)
+		repeat(s.matched(e.Action, failure == ""))
+		if failure == "" && e.DefineValue != "" {
+			s.vars[s.expand(e.DefineValue)] = strings.Join(a.values, ",")
+		}
+		if failure != "" && !e.defines() && e.DefineValue == "" {
+			failures = append(failures, failure)
+		}
+	}
+	return failures, limit
+}
+
+//	Checks an EXPECT against the attribute a of the response, returns "" if it matches and
+//	otherwise why not
+func (s *state) expect(e Expect, a attr, resp ipp.Message) string {
+	switch {
+	case e.Absent && a.found:
+		return "UNEXPECTED: " + a.name
+	case e.Absent || e.Optional && !a.found:
+		return ""
+	case !a.found:
+		return "EXPECTED: " + a.name
+	}
+	if e.OfType != "" {
+		for _, tag := range a.tags {
+			if !ofType(tag, e.OfType) {
+				return "EXPECTED: " + a.name + " OF-TYPE " + e.OfType + " (got " + ipp.TagName(tag) + ")"
+			}
+		}
+	}
+	if e.InGroup != "" {
+		if group, _ := ipp.ParseTag(e.InGroup); group != a.group {
+			return "EXPECTED: " + a.name + " IN-GROUP " + e.InGroup + " (got " + ipp.TagName(a.group) + ")"
+		}
+	}
+	if e.Count > 0 && len(a.values) != e.Count {
+		return fmt.Sprintf("EXPECTED: %s COUNT %d (got %d)", a.name, e.Count, len(a.values))
+	}
+	if e.SameCountAs != "" {
+		other := lookup(resp, s.expand(e.SameCountAs))
+		if !other.found || len(other.values) != len(a.values) {
+			return fmt.Sprintf("EXPECTED: %s (%d values) SAME-COUNT-AS %s (%d values)", a.name, len(a.values), other.name, len(other.values))
+		}
+	}
+	if e.WithValue != "" {
+		want := s.expand(e.WithValue)
+		n := 0
+		for i, v := range a.values {
+			ok, err := matches(a.name, a.tags[i], v, want)
+			if err != nil {
+				return "EXPECTED: " + a.name + " WITH-VALUE " + want + ": " + err.Error()
+			}
+			if ok {
+				n++
+			}
+		}
+		if e.AllValues && n < len(a.values) || n == 0 {
+			directive := "WITH-VALUE"
+			if e.AllValues {
+				directive = "WITH-ALL-VALUES"
+			}
+			return "EXPECTED: " + a.name + " " + directive + " " + want + " (got " + strings.Join(a.values, ",") + ")"
+		}
+	}
+	return ""
+}
+
+//	Returns true if tag is one of the tags in types, "tag|tag...". "text" and "name" match the
+//	WithLanguage variants too.
+func ofType(tag byte, types string) bool {
+	for _, name := range strings.Split(types, "|") {
+		want, _ := ipp.ParseTag(name)
+		if tag == want || want == ipp.TAG_TEXT && tag == ipp.TAG_TEXTLANG || want == ipp.TAG_NAME && tag == ipp.TAG_NAMELANG {
+			return true
+		}
+	}
+	return false
+}
+
+//	Returns true if a value of an attribute matches a WITH-VALUE. "/regex/" is a regular
+//	expression. Integers and enums are compared with comma separated alternatives of n, =n,
+//	<n, <=n, >n, >=n and ranges min-max, enums also by the keywords of the attribute name (see
+//	ipp.ParseEnum). rangeOfInteger values match min-max or a number they contain, resolutions
+//	are written 600dpi or 600x300dpi. Other values must be equal.
+func matches(name string, tag byte, value, want string) (bool, error) {
+	if len(want) > 1 && strings.HasPrefix(want, "/") && strings.HasSuffix(want, "/") {
+		return regexp.MatchString(want[1:len(want)-1], value)
+	}
+	switch tag {
+	case ipp.TAG_INTEGER, ipp.TAG_ENUM:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false, nil
+		}
+		for _, alt := range strings.Split(want, ",") {
+			if e, ok := ipp.ParseEnum(name, alt); ok && tag == ipp.TAG_ENUM && !isNumber(alt) {
+				alt = strconv.Itoa(e)
+			}
+			if compare(n, alt) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ipp.TAG_RANGE:
+		var lower, upper int
+		if _, err := fmt.Sscanf(value, "%d to %d", &lower, &upper); err != nil {
+			return false, nil
+		}
+		for _, alt := range strings.Split(want, ",") {
+			if alt == fmt.Sprintf("%d-%d", lower, upper) {
+				return true, nil
+			}
+			if n, err := strconv.Atoi(alt); err == nil && lower <= n && n <= upper {
+				return true, nil
+			}
+		}
+		return false, nil
+	case ipp.TAG_RESOLUTION:
+		if !strings.Contains(want, "x") {
+			if i := strings.Index(want, "dp"); i > 0 {
+				want = want[:i] + "x" + want
+			}
+		}
+	}
+	return value == want, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseInt(s, 0, 64)
+	return err == nil
+}
+
+//	Compares n with an alternative of WITH-VALUE: n, =n, <n, <=n, >n, >=n or min-max
+func compare(n int, alt string) bool {
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(alt, op) {
+			m, err := strconv.ParseInt(alt[len(op):], 0, 64)
+			if err != nil {
+				return false
+			}
+			switch op {
+			case "<=":
+				return int64(n) <= m
+			case ">=":
+				return int64(n) >= m
+			case "<":
+				return int64(n) < m
+			case ">":
+				return int64(n) > m
+			}
+			return int64(n) == m
+		}
+	}
+	// the '-' of min-max follows a digit, a '-' first is the sign of min
+	if i := strings.Index(strings.TrimPrefix(alt, "-"), "-"); i >= 0 {
+		i += len(alt) - len(strings.TrimPrefix(alt, "-"))
+		lower, err1 := strconv.Atoi(alt[:i])
+		upper, err2 := strconv.Atoi(alt[i+1:])
+		return err1 == nil && err2 == nil && lower <= n && n <= upper
+	}
+	m, err := strconv.ParseInt(alt, 0, 64)
+	return err == nil && int64(n) == m
+}
@@ -0,0 +1,143 @@
+package ipptest
+
+import (
+	"ipp"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const memoryTests = `
+{NAME "Get-Printer-Attributes"
+	OPERATION Get-Printer-Attributes
+	GROUP operation-attributes-tag
+	ATTR charset attributes-charset utf-8
+	ATTR naturalLanguage attributes-natural-language en
+	ATTR uri printer-uri ${uri}
+	STATUS successful-ok
+	EXPECT printer-state OF-TYPE enum WITH-VALUE idle,processing
+	EXPECT operations-supported WITH-VALUE Print-Job
+	EXPECT operations-supported WITH-VALUE 0x000b
+	DISPLAY printer-state}
+{
+	NAME "Print-Job"
+	OPERATION Print-Job
+	GROUP operation-attributes-tag
+	ATTR charset attributes-charset utf-8
+	ATTR naturalLanguage attributes-natural-language en
+	ATTR uri printer-uri $uri
+	ATTR name requesting-user-name tester
+	ATTR mimeMediaType document-format application/pdf
+	GROUP job-attributes-tag
+	ATTR enum finishings staple,punch
+	STATUS successful-ok
+	STATUS successful-ok-ignored-or-substituted-attributes
+	EXPECT job-id OF-TYPE integer WITH-VALUE >0 DEFINE-VALUE job-id
+}
+{
+	NAME "Job completes"
+	OPERATION Get-Job-Attributes
+	GROUP operation-attributes-tag
+	ATTR charset attributes-charset utf-8
+	ATTR naturalLanguage attributes-natural-language en
+	ATTR uri printer-uri $uri
+	ATTR integer job-id $job-id
+	STATUS successful-ok
+	EXPECT job-state WITH-VALUE completed REPEAT-NO-MATCH REPEAT-LIMIT 50
+	EXPECT finishings WITH-ALL-VALUES staple,punch COUNT 2
+}
+{
+	NAME "Wrong state"
+	OPERATION Get-Printer-Attributes
+	GROUP operation-attributes-tag
+	ATTR charset attributes-charset utf-8
+	ATTR naturalLanguage attributes-natural-language en
+	ATTR uri printer-uri $uri
+	EXPECT printer-state WITH-VALUE stopped
+}
+`
+
+func TestRunMemoryPrinter(t *testing.T) {
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Start()
+	defer ts.Close()
+	uri := "ipp://" + strings.TrimPrefix(ts.URL, "http://") + "/ipp/print"
+	ts.Config.Handler = ipp.NewServer(ipp.NewMemoryPrinter("ipptest", uri))
+
+	f, err := Parse(strings.NewReader(memoryTests), "memory.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRunner(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.IgnoreErrors = true
+	results := r.Run(f)
+	want := []struct {
+		name    string
+		failure string
+	}{
+		{"Get-Printer-Attributes", ""},
+		{"Print-Job", ""},
+		{"Job completes", ""},
+		{"Wrong state", "EXPECTED: printer-state WITH-VALUE stopped (got 3)"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("%d results, want %d: %+v", len(results), len(want), results)
+	}
+	for i, w := range want {
+		res := results[i]
+		if res.Name != w.name || res.Status != "successful-ok" {
+			t.Errorf("result %d: %s %s, want %s successful-ok", i, res.Name, res.Status, w.name)
+		}
+		failure := strings.Join(res.Failures, "; ")
+		if failure != w.failure {
+			t.Errorf("%s: failures %q, want %q", w.name, failure, w.failure)
+		}
+	}
+	if d := results[0].Displayed; len(d) != 1 || d[0] != "printer-state (enum) = 3" {
+		t.Errorf("DISPLAY printer-state: %q", d)
+	}
+}
+
+func TestParseBraces(t *testing.T) {
+	f, err := Parse(strings.NewReader(`{NAME "a"}{OPERATION Get-Jobs
+		GROUP operation-attributes-tag ATTR uri printer-uri ${uri}/x
+		ATTR collection media-col {MEMBER keyword media-size-name iso_a4_210x297mm},{MEMBER integer media-top-margin 0}}`), "braces.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Steps) != 2 || f.Steps[0].Test.Name != "a" || f.Steps[1].Test.Operation != "Get-Jobs" {
+		t.Fatalf("steps %+v", f.Steps)
+	}
+	attrs := f.Steps[1].Test.Groups[0].Attrs
+	if len(attrs) != 2 || attrs[0].Values[0].Text != "${uri}/x" {
+		t.Fatalf("attributes %+v", attrs)
+	}
+	if v := attrs[1].Values; len(v) != 2 || !v[0].Collection || v[1].Members[0].Name != "media-top-margin" {
+		t.Errorf("media-col %+v", v)
+	}
+}
+
+func TestMatchesEnum(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+		match             bool
+	}{
+		{"job-state", "9", "completed", true},
+		{"job-state", "9", "pending,Completed", true},
+		{"job-state", "5", "completed", false},
+		{"printer-state", "3", "idle", true},
+		{"finishings-supported", "4", "staple", true},
+		{"operations-supported", "2", "Print-Job", true},
+		{"operations-supported", "2", "Cancel-Job", false},
+		{"job-state", "9", "7-9", true},
+		{"job-state", "9", "unknown", false},
+	}
+	for _, test := range tests {
+		if ok, err := matches(test.name, ipp.TAG_ENUM, test.value, test.want); err != nil || ok != test.match {
+			t.Errorf("%s %s WITH-VALUE %s: %v %v, want %v", test.name, test.value, test.want, ok, err, test.match)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+//	ipptool runs CUPS ipptool test files against a printer and reports which tests passed:
+//
+//	ipptool [-c] [-d name=value] [-f filename] [-junit file] printer-uri file.test ...
+//	ipptool -embedded file.test ...
+//
+//	-embedded runs the tests against an ipp.MemoryPrinter served on a local port, which
+//	checks the test files themselves. The exit status is 1 if a test failed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"ipp"
+	"ipptest"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+//	The -d flags
+type defines map[string]string
+
+func (d defines) String() string {
+	var s []string
+	for name, value := range d {
+		s = append(s, name+"="+value)
+	}
+	return strings.Join(s, " ")
+}
+
+func (d defines) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i <= 0 {
+		return fmt.Errorf("%q is not name=value", s)
+	}
+	d[s[:i]] = s[i+1:]
+	return nil
+}
+
+func main() {
+	vars := make(defines)
+	flag.Var(vars, "d", "defines the variable `name=value`, may be repeated")
+	filename := flag.String("f", "", "sets $filename, the document of the tests")
+	ignoreErrors := flag.Bool("c", false, "runs the tests after a failed test")
+	junit := flag.String("junit", "", "writes a JUnit XML report to `file`, - is standard output")
+	embedded := flag.Bool("embedded", false, "runs the tests against an embedded MemoryPrinter instead of printer-uri")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ipptool [options] printer-uri file.test ...")
+		fmt.Fprintln(os.Stderr, "       ipptool [options] -embedded file.test ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+
+	var printerUri string
+	switch {
+	case *embedded:
+		uri, err := serve()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ipptool:", err)
+			os.Exit(2)
+		}
+		printerUri = uri
+	case len(args) > 0:
+		printerUri, args = args[0], args[1:]
+	}
+	if printerUri == "" || len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	runner, err := ipptest.NewRunner(printerUri)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ipptool:", err)
+		os.Exit(2)
+	}
+	for name, value := range vars {
+		runner.Vars[name] = value
+	}
+	if *filename != "" {
+		runner.Vars["filename"] = *filename
+	}
+	runner.IgnoreErrors = *ignoreErrors
+
+	var report ipptest.Report
+	for _, name := range args {
+		f, err := ipptest.ParseFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ipptool:", err)
+			os.Exit(2)
+		}
+		report.Add(runner.Run(f)...)
+	}
+
+	out := os.Stdout
+	if *junit == "-" {
+		out = os.Stderr
+		err = report.WriteJUnit(os.Stdout)
+	} else if *junit != "" {
+		var w *os.File
+		if w, err = os.Create(*junit); err == nil {
+			err = report.WriteJUnit(w)
+			if cerr := w.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ipptool:", err)
+		os.Exit(2)
+	}
+	report.WriteText(out)
+	if _, failed, _ := report.Counts(); failed > 0 {
+		os.Exit(1)
+	}
+}
+
+//	Serves a MemoryPrinter on a free local port and returns its printer-uri
+func serve() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	uri := "ipp://" + l.Addr().String() + "/ipp/print"
+	p := ipp.NewMemoryPrinter("ipptool", uri)
+	go http.Serve(l, ipp.NewServer(p))
+	return uri, nil
+}
@@ -6,6 +6,6 @@ import "ipp"
 func main() {
 	var c ipp.CupsServer
 	c.SetServer("192.168.1.8")
-	c.GetPrinterAttributes()
+	c.GetPrinterAttributes("ipp://192.168.1.8:631/ipp/print", "printer-state")
 
 }
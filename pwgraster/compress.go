@@ -0,0 +1,196 @@
+package pwgraster
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+//   Compressed line data (PWG 5102.4 section 4.3)
+//
+//   Every group of identical lines starts with a line repeat count n, 0 to 255, the line is
+//   used n+1 times. The line follows as runs of pixels, or of bytes for less than 8 bits per
+//   pixel:
+//
+//      0 to 127        the next pixel is repeated n+1 times
+//      128             the rest of the line is white
+//      129 to 255      257-n literal pixels follow
+//
+//   White is 0xff for RGB, SGray, SRGB and AdobeRGB and 0x00 for Black, CMYK and Device1-15.
+
+var errCorrupt = errors.New("pwgraster: corrupt line data")
+
+//	Encodes the lines of a page, len(data) is Height*BytesPerLine
+func compress(w io.Writer, h *Header, data []byte) error {
+	return EncodeLines(w, data, int(h.BytesPerLine), bytesPerPixel(h), whiteByte(h.ColorSpace))
+}
+
+//	Encodes lines of bytesPerLine bytes with the run-length compression above, pixels are of
+//	bytesPerPixel bytes (1 for less than 8 bits per pixel) and white is the white byte.
+//	Apple Raster (URF) lines use the same compression.
+func EncodeLines(w io.Writer, data []byte, bytesPerLine, bytesPerPixel int, white byte) error {
+	if bytesPerLine <= 0 || bytesPerPixel <= 0 || len(data)%bytesPerLine != 0 {
+		return errCorrupt
+	}
+	height := len(data) / bytesPerLine
+	var buf []byte
+	for y := 0; y < height; {
+		line := data[y*bytesPerLine : (y+1)*bytesPerLine]
+		repeat := 1
+		for y+repeat < height && repeat < 256 && bytes.Equal(line, data[(y+repeat)*bytesPerLine:(y+repeat+1)*bytesPerLine]) {
+			repeat++
+		}
+		buf = append(buf[:0], byte(repeat-1))
+		buf = compressLine(buf, line, bytesPerPixel, white)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+		y += repeat
+	}
+	return nil
+}
+
+//	Appends the runs of one line
+func compressLine(buf, line []byte, unit int, white byte) []byte {
+	n := len(line) / unit
+	pixel := func(i int) []byte { return line[i*unit : (i+1)*unit] }
+	for i := 0; i < n; {
+		if isWhite(line[i*unit:], white) {
+			return append(buf, 128)
+		}
+		run := 1
+		for i+run < n && run < 128 && bytes.Equal(pixel(i+run), pixel(i)) {
+			run++
+		}
+		if run > 1 {
+			buf = append(buf, byte(run-1))
+			buf = append(buf, pixel(i)...)
+			i += run
+			continue
+		}
+		// literal pixels up to the next pair of identical pixels
+		j := i + 1
+		for j < n && j-i < 128 && !(j+1 < n && bytes.Equal(pixel(j), pixel(j+1))) {
+			j++
+		}
+		if j-i == 1 {
+			buf = append(buf, 0)
+		} else {
+			buf = append(buf, byte(257-(j-i)))
+		}
+		buf = append(buf, line[i*unit:j*unit]...)
+		i = j
+	}
+	return buf
+}
+
+func isWhite(b []byte, white byte) bool {
+	for _, c := range b {
+		if c != white {
+			return false
+		}
+	}
+	return true
+}
+
+//	Returns the unit of the runs, a pixel or a byte for less than 8 bits per pixel
+func bytesPerPixel(h *Header) int {
+	if h.BitsPerPixel < 8 {
+		return 1
+	}
+	return int(h.BitsPerPixel / 8)
+}
+
+func whiteByte(cs ColorSpace) byte {
+	if cs.additive() {
+		return 0xff
+	}
+	return 0
+}
+
+//	Decodes the lines of a page
+func decompress(r io.ByteReader, h *Header) ([]byte, error) {
+	return DecodeLines(r, int(h.Height), int(h.BytesPerLine), bytesPerPixel(h), whiteByte(h.ColorSpace))
+}
+
+//	Decodes height lines encoded by EncodeLines. More than MaxPageSize bytes are ErrPageSize,
+//	the lines are allocated as they are decoded.
+func DecodeLines(r io.ByteReader, height, bytesPerLine, bytesPerPixel int, white byte) ([]byte, error) {
+	if height < 0 || bytesPerLine <= 0 || bytesPerPixel <= 0 {
+		return nil, errCorrupt
+	}
+	if height > 0 && int64(bytesPerLine) > MaxPageSize/int64(height) {
+		return nil, ErrPageSize
+	}
+	size := height * bytesPerLine
+	if size > 1<<20 {
+		size = 1 << 20
+	}
+	data := make([]byte, 0, size)
+	line := make([]byte, 0, bytesPerLine)
+	for y := 0; y < height; {
+		repeat, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		line = line[:0]
+		for len(line) < bytesPerLine {
+			c, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			switch {
+			case c == 128:
+				for len(line) < bytesPerLine {
+					line = append(line, white)
+				}
+			case c < 128:
+				pixel, err := readBytes(r, bytesPerPixel)
+				if err != nil {
+					return nil, err
+				}
+				if len(line)+(int(c)+1)*bytesPerPixel > bytesPerLine {
+					return nil, errCorrupt
+				}
+				for i := 0; i <= int(c); i++ {
+					line = append(line, pixel...)
+				}
+			default:
+				n := (257 - int(c)) * bytesPerPixel
+				if len(line)+n > bytesPerLine {
+					return nil, errCorrupt
+				}
+				pixels, err := readBytes(r, n)
+				if err != nil {
+					return nil, err
+				}
+				line = append(line, pixels...)
+			}
+		}
+		for i := 0; i <= int(repeat) && y < height; i++ {
+			data = append(data, line...)
+			y++
+		}
+	}
+	return data, nil
+}
+
+func readBytes(r io.ByteReader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	for i := range b {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		b[i] = c
+	}
+	return b, nil
+}
+
+//	A page that ends early is io.ErrUnexpectedEOF
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
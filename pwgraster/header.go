@@ -0,0 +1,340 @@
+package pwgraster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+//   PWG Raster Format (PWG 5102.4)
+//
+//   A PWG Raster stream is the sync word "RaS2" followed by the pages. Each page is a 1796 byte
+//   header, big-endian, and the compressed lines of the page (see compress.go):
+//
+//      offset  length  field
+//      0       64      PwgRaster, the C string "PwgRaster"
+//      64      64      MediaColor                  media-color, e.g. "white"
+//      128     64      MediaType                   media-type, e.g. "stationery"
+//      192     64      PrintContentOptimize        print-content-optimize, e.g. "photo"
+//      256     12      reserved
+//      268     4       CutMedia                    0 never, 1 after the document, 2 job, 3 set, 4 page
+//      272     4       Duplex                      1 if printed on both sides
+//      276     8       HWResolution                cross feed and feed resolution in dpi
+//      284     16      reserved
+//      300     4       InsertSheet
+//      304     4       Jog
+//      308     4       LeadingEdge                 0 short edge first, 1 long edge first
+//      312     12      reserved
+//      324     4       MediaPosition               media-source as enum
+//      328     4       MediaWeightMetric           grams per square metre
+//      332     8       reserved
+//      340     4       NumCopies                   0 or the copies the printer makes
+//      344     4       Orientation                 0 portrait, 1 landscape, 2 reverse-portrait, 3 reverse-landscape
+//      348     4       reserved
+//      352     8       PageSize                    width and length of the media in points
+//      360     8       reserved
+//      368     4       Tumble                      1 if the back side is rotated (two-sided-short-edge)
+//      372     4       Width                       pixels per line
+//      376     4       Height                      lines of the page
+//      380     4       reserved
+//      384     4       BitsPerColor                1, 2, 4, 8 or 16
+//      388     4       BitsPerPixel
+//      392     4       BytesPerLine
+//      396     4       ColorOrder                  0, chunky pixels
+//      400     4       ColorSpace
+//      404     16      reserved
+//      420     4       NumColors
+//      424     28      reserved
+//      452     4       TotalPageCount              0 if unknown
+//      456     4       CrossFeedTransform          1 or -1, how the back side is mirrored
+//      460     4       FeedTransform               1 or -1
+//      464     16      ImageBoxLeft, ImageBoxTop, ImageBoxRight, ImageBoxBottom, in pixels
+//      480     4       AlternatePrimary            sRGB color of the alternate primary
+//      484     4       PrintQuality                print-quality, 3 draft, 4 normal, 5 high, 0 default
+//      488     20      reserved
+//      508     4       VendorIdentifier            the USB vendor id
+//      512     4       VendorLength
+//      516     1088    VendorData
+//      1604    64      reserved
+//      1668    64      RenderingIntent             print-rendering-intent, e.g. "perceptual"
+//      1732    64      PageSizeName                the PWG media name, e.g. "iso_a4_210x297mm"
+
+//	The sync word at the start of a PWG Raster stream
+const SyncWord = "RaS2"
+
+//	The length of a page header
+const HeaderLength = 1796
+
+//	ColorSpace of the pixels of a page
+type ColorSpace uint32
+
+const (
+	RGB      ColorSpace = 1  // device RGB
+	Black    ColorSpace = 3  // device black, 0 is white
+	CMYK     ColorSpace = 6  // device CMYK
+	SGray    ColorSpace = 18 // sRGB gray, 0 is black
+	SRGB     ColorSpace = 19 // sRGB
+	AdobeRGB ColorSpace = 20 // Adobe RGB (1998)
+	Device1  ColorSpace = 48 // Device1 to Device15 (48 to 62) have 1 to 15 device colorants
+	Device15 ColorSpace = 62
+)
+
+//	Returns the colorants of a pixel
+func (cs ColorSpace) Colors() int {
+	switch {
+	case cs == Black || cs == SGray:
+		return 1
+	case cs == RGB || cs == SRGB || cs == AdobeRGB:
+		return 3
+	case cs == CMYK:
+		return 4
+	case cs >= Device1 && cs <= Device15:
+		return int(cs-Device1) + 1
+	}
+	return 0
+}
+
+//	Returns true if a colorant value of 0 is white (no ink), as for Black, CMYK and Device1-15
+func (cs ColorSpace) additive() bool {
+	return cs == RGB || cs == SGray || cs == SRGB || cs == AdobeRGB
+}
+
+//	Header is the page header of a PWG Raster page
+type Header struct {
+	MediaColor           string
+	MediaType            string
+	PrintContentOptimize string
+	CutMedia             uint32
+	Duplex               bool
+	HWResolution         [2]uint32 // dpi, cross feed and feed direction
+	InsertSheet          uint32
+	Jog                  uint32
+	LeadingEdge          uint32
+	MediaPosition        uint32
+	MediaWeightMetric    uint32
+	NumCopies            uint32
+	Orientation          uint32
+	PageSize             [2]uint32 // points, width and length
+	Tumble               bool
+	Width                uint32 // pixels
+	Height               uint32 // lines
+	BitsPerColor         uint32
+	BitsPerPixel         uint32
+	BytesPerLine         uint32
+	ColorOrder           uint32
+	ColorSpace           ColorSpace
+	NumColors            uint32
+	TotalPageCount       uint32
+	CrossFeedTransform   int32
+	FeedTransform        int32
+	ImageBox             [4]uint32 // left, top, right and bottom in pixels
+	AlternatePrimary     uint32
+	PrintQuality         uint32
+	VendorIdentifier     uint32
+	VendorData           []byte // at most 1088 bytes
+	RenderingIntent      string
+	PageSizeName         string
+}
+
+//	Returns the header of a page of width x length hundredths of millimetres (the units of
+//	ipp.Media) at dpi in the color space, 8 bits per color, e.g.
+//
+//	h := NewHeader(21000, 29700, 300, pwgraster.SGray)
+//	h.PageSizeName = "iso_a4_210x297mm"
+func NewHeader(width, length, dpi int, cs ColorSpace) Header {
+	h := Header{
+		HWResolution:       [2]uint32{uint32(dpi), uint32(dpi)},
+		PageSize:           [2]uint32{points(width), points(length)},
+		Width:              uint32(math.Round(float64(width) * float64(dpi) / 2540)),
+		Height:             uint32(math.Round(float64(length) * float64(dpi) / 2540)),
+		BitsPerColor:       8,
+		ColorSpace:         cs,
+		CrossFeedTransform: 1,
+		FeedTransform:      1,
+	}
+	h.ImageBox = [4]uint32{0, 0, h.Width, h.Height}
+	h.setLayout()
+	return h
+}
+
+//	Clips ImageBox to Width x Height, an empty box covers the whole page
+func (h *Header) clampImageBox() {
+	box := h.ImageBox
+	for i, limit := range [4]uint32{h.Width, h.Height, h.Width, h.Height} {
+		if box[i] > limit {
+			box[i] = limit
+		}
+	}
+	if box[0] >= box[2] || box[1] >= box[3] {
+		box = [4]uint32{0, 0, h.Width, h.Height}
+	}
+	h.ImageBox = box
+}
+
+//	Converts hundredths of millimetres to points
+func points(hmm int) uint32 {
+	return uint32(math.Round(float64(hmm) * 72 / 2540))
+}
+
+//	Sets NumColors, BitsPerPixel and BytesPerLine from ColorSpace, BitsPerColor and Width
+func (h *Header) setLayout() {
+	h.NumColors = uint32(h.ColorSpace.Colors())
+	h.BitsPerPixel = h.BitsPerColor * h.NumColors
+	h.BytesPerLine = (h.Width*h.BitsPerPixel + 7) / 8
+}
+
+var (
+	ErrSyncWord = errors.New("pwgraster: not a PWG Raster stream")
+	ErrHeader   = errors.New("pwgraster: invalid page header")
+	ErrPageSize = errors.New("pwgraster: page is larger than MaxPageSize")
+)
+
+//	The largest page in bytes, Height*BytesPerLine, a Reader decodes. The headers of a stream
+//	are not to be trusted, this bounds the memory a page takes.
+var MaxPageSize int64 = 1 << 30
+
+//	Checks that the header describes a page that can be encoded
+func (h *Header) validate() error {
+	switch h.BitsPerColor {
+	case 1, 2, 4, 8, 16:
+	default:
+		return ErrHeader
+	}
+	n := uint32(h.ColorSpace.Colors())
+	if n == 0 || h.NumColors != n || h.BitsPerPixel != h.BitsPerColor*n || h.ColorOrder != 0 ||
+		h.BitsPerPixel > 8 && h.BitsPerPixel%8 != 0 ||
+		uint64(h.BytesPerLine) != (uint64(h.Width)*uint64(h.BitsPerPixel)+7)/8 || h.Width == 0 || h.Height == 0 ||
+		len(h.VendorData) > 1088 {
+		return ErrHeader
+	}
+	if b := h.ImageBox; b[0] > b[2] || b[1] > b[3] || b[2] > h.Width || b[3] > h.Height {
+		return ErrHeader
+	}
+	if uint64(h.Height)*uint64(h.BytesPerLine) > uint64(MaxPageSize) {
+		return ErrPageSize
+	}
+	return nil
+}
+
+//	Returns the 1796 bytes of the header
+func (h *Header) MarshalBinary() ([]byte, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	b := make([]byte, HeaderLength)
+	putString(b[0:], "PwgRaster")
+	putString(b[64:], h.MediaColor)
+	putString(b[128:], h.MediaType)
+	putString(b[192:], h.PrintContentOptimize)
+	put := func(off int, v uint32) { binary.BigEndian.PutUint32(b[off:], v) }
+	put(268, h.CutMedia)
+	put(272, boolean(h.Duplex))
+	put(276, h.HWResolution[0])
+	put(280, h.HWResolution[1])
+	put(300, h.InsertSheet)
+	put(304, h.Jog)
+	put(308, h.LeadingEdge)
+	put(324, h.MediaPosition)
+	put(328, h.MediaWeightMetric)
+	put(340, h.NumCopies)
+	put(344, h.Orientation)
+	put(352, h.PageSize[0])
+	put(356, h.PageSize[1])
+	put(368, boolean(h.Tumble))
+	put(372, h.Width)
+	put(376, h.Height)
+	put(384, h.BitsPerColor)
+	put(388, h.BitsPerPixel)
+	put(392, h.BytesPerLine)
+	put(396, h.ColorOrder)
+	put(400, uint32(h.ColorSpace))
+	put(420, h.NumColors)
+	put(452, h.TotalPageCount)
+	put(456, uint32(h.CrossFeedTransform))
+	put(460, uint32(h.FeedTransform))
+	for i, v := range h.ImageBox {
+		put(464+4*i, v)
+	}
+	put(480, h.AlternatePrimary)
+	put(484, h.PrintQuality)
+	put(508, h.VendorIdentifier)
+	put(512, uint32(len(h.VendorData)))
+	copy(b[516:1604], h.VendorData)
+	putString(b[1668:], h.RenderingIntent)
+	putString(b[1732:], h.PageSizeName)
+	return b, nil
+}
+
+//	Decodes a header of 1796 bytes
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLength || getString(b[0:]) != "PwgRaster" {
+		return ErrHeader
+	}
+	get := func(off int) uint32 { return binary.BigEndian.Uint32(b[off:]) }
+	*h = Header{
+		MediaColor:           getString(b[64:]),
+		MediaType:            getString(b[128:]),
+		PrintContentOptimize: getString(b[192:]),
+		CutMedia:             get(268),
+		Duplex:               get(272) != 0,
+		HWResolution:         [2]uint32{get(276), get(280)},
+		InsertSheet:          get(300),
+		Jog:                  get(304),
+		LeadingEdge:          get(308),
+		MediaPosition:        get(324),
+		MediaWeightMetric:    get(328),
+		NumCopies:            get(340),
+		Orientation:          get(344),
+		PageSize:             [2]uint32{get(352), get(356)},
+		Tumble:               get(368) != 0,
+		Width:                get(372),
+		Height:               get(376),
+		BitsPerColor:         get(384),
+		BitsPerPixel:         get(388),
+		BytesPerLine:         get(392),
+		ColorOrder:           get(396),
+		ColorSpace:           ColorSpace(get(400)),
+		NumColors:            get(420),
+		TotalPageCount:       get(452),
+		CrossFeedTransform:   int32(get(456)),
+		FeedTransform:        int32(get(460)),
+		ImageBox:             [4]uint32{get(464), get(468), get(472), get(476)},
+		AlternatePrimary:     get(480),
+		PrintQuality:         get(484),
+		VendorIdentifier:     get(508),
+		RenderingIntent:      getString(b[1668:]),
+		PageSizeName:         getString(b[1732:]),
+	}
+	if n := get(512); n > 0 {
+		if n > 1088 {
+			return ErrHeader
+		}
+		h.VendorData = append([]byte(nil), b[516:516+n]...)
+	}
+	return h.validate()
+}
+
+//	Writes s as a C string into a 64 byte field
+func putString(b []byte, s string) {
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	copy(b[:64], s)
+}
+
+//	Returns the C string of a 64 byte field
+func getString(b []byte) string {
+	b = b[:64]
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func boolean(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,216 @@
+package pwgraster
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+//	Page is a PWG Raster page, its header and the uncompressed lines
+type Page struct {
+	Header Header
+	Data   []byte // Height lines of BytesPerLine bytes
+}
+
+var ErrColorSpace = errors.New("pwgraster: color space and bits per color not supported for images")
+
+//	Writer encodes pages to a PWG Raster stream
+type Writer struct {
+	w       io.Writer
+	started bool
+}
+
+//	Returns a Writer to w, the sync word is written with the first page
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+//	Writes a page of img, which is scaled to neither the page nor the resolution: one pixel of
+//	img is one pixel of the page. Width, Height and the layout fields of h are set from the
+//	bounds of img, ColorSpace and BitsPerColor (8 if 0). ImageBox is clipped to the image, a
+//	box that does not overlap it covers the whole image. Supported are SGray 1, 8 and 16 bits,
+//	Black 1 and 8, RGB, SRGB and AdobeRGB 8 and 16 and CMYK 8.
+func (w *Writer) WritePage(h Header, img image.Image) error {
+	if h.BitsPerColor == 0 {
+		h.BitsPerColor = 8
+	}
+	b := img.Bounds()
+	h.Width, h.Height = uint32(b.Dx()), uint32(b.Dy())
+	h.clampImageBox()
+	h.setLayout()
+	data, err := encodeImage(&h, img)
+	if err != nil {
+		return err
+	}
+	return w.WriteRaw(&Page{Header: h, Data: data})
+}
+
+//	Writes a page of uncompressed lines
+func (w *Writer) WriteRaw(p *Page) error {
+	hb, err := p.Header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if int64(len(p.Data)) != int64(p.Header.Height)*int64(p.Header.BytesPerLine) {
+		return ErrHeader
+	}
+	bw := bufio.NewWriter(w.w)
+	if !w.started {
+		bw.WriteString(SyncWord)
+		w.started = true
+	}
+	bw.Write(hb)
+	if err := compress(bw, &p.Header, p.Data); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+//	Reader decodes the pages of a PWG Raster stream
+type Reader struct {
+	r *bufio.Reader
+}
+
+//	Reads the sync word and returns a Reader of the pages
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	sync := make([]byte, len(SyncWord))
+	if _, err := io.ReadFull(br, sync); err != nil || string(sync) != SyncWord {
+		return nil, ErrSyncWord
+	}
+	return &Reader{r: br}, nil
+}
+
+//	Returns the next page, io.EOF after the last one
+func (r *Reader) ReadPage() (*Page, error) {
+	hb := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(r.r, hb); err != nil {
+		return nil, err
+	}
+	var p Page
+	if err := p.Header.UnmarshalBinary(hb); err != nil {
+		return nil, err
+	}
+	data, err := decompress(r.r, &p.Header)
+	if err != nil {
+		return nil, err
+	}
+	p.Data = data
+	return &p, nil
+}
+
+//	Returns the page as an image: *image.Gray or *image.Gray16 for SGray and Black (ink is
+//	dark), *image.RGBA or *image.RGBA64 for RGB, SRGB and AdobeRGB, *image.CMYK for CMYK
+func (p *Page) Image() (image.Image, error) {
+	h := &p.Header
+	w, ht := int(h.Width), int(h.Height)
+	rect := image.Rect(0, 0, w, ht)
+	line := func(y int) []byte { return p.Data[y*int(h.BytesPerLine) : (y+1)*int(h.BytesPerLine)] }
+	switch {
+	case (h.ColorSpace == SGray || h.ColorSpace == Black) && h.BitsPerColor == 1:
+		img := image.NewGray(rect)
+		for y := 0; y < ht; y++ {
+			l := line(y)
+			for x := 0; x < w; x++ {
+				bit := l[x/8]>>(7-uint(x%8))&1 == 1
+				if bit == (h.ColorSpace == SGray) {
+					img.Pix[y*img.Stride+x] = 0xff
+				}
+			}
+		}
+		return img, nil
+	case (h.ColorSpace == SGray || h.ColorSpace == Black) && h.BitsPerColor == 8:
+		img := image.NewGray(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		if h.ColorSpace == Black {
+			invert(img.Pix)
+		}
+		return img, nil
+	case h.ColorSpace == SGray && h.BitsPerColor == 16:
+		img := image.NewGray16(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		return img, nil
+	case h.ColorSpace.Colors() == 3 && h.ColorSpace.additive() && h.BitsPerColor == 8:
+		img := image.NewRGBA(rect)
+		for y := 0; y < ht; y++ {
+			l := line(y)
+			for x := 0; x < w; x++ {
+				copy(img.Pix[y*img.Stride+4*x:], l[3*x:3*x+3])
+				img.Pix[y*img.Stride+4*x+3] = 0xff
+			}
+		}
+		return img, nil
+	case h.ColorSpace.Colors() == 3 && h.ColorSpace.additive() && h.BitsPerColor == 16:
+		img := image.NewRGBA64(rect)
+		for y := 0; y < ht; y++ {
+			l := line(y)
+			for x := 0; x < w; x++ {
+				copy(img.Pix[y*img.Stride+8*x:], l[6*x:6*x+6])
+				img.Pix[y*img.Stride+8*x+6] = 0xff
+				img.Pix[y*img.Stride+8*x+7] = 0xff
+			}
+		}
+		return img, nil
+	case h.ColorSpace == CMYK && h.BitsPerColor == 8:
+		img := image.NewCMYK(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		return img, nil
+	}
+	return nil, ErrColorSpace
+}
+
+//	Returns the lines of img in the layout of h
+func encodeImage(h *Header, img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, ht, bpl := int(h.Width), int(h.Height), int(h.BytesPerLine)
+	data := make([]byte, ht*bpl)
+	for y := 0; y < ht; y++ {
+		l := data[y*bpl : (y+1)*bpl]
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch {
+			case (h.ColorSpace == SGray || h.ColorSpace == Black) && h.BitsPerColor == 1:
+				light := color.GrayModel.Convert(c).(color.Gray).Y >= 0x80
+				if light == (h.ColorSpace == SGray) {
+					l[x/8] |= 0x80 >> uint(x%8)
+				}
+			case (h.ColorSpace == SGray || h.ColorSpace == Black) && h.BitsPerColor == 8:
+				l[x] = color.GrayModel.Convert(c).(color.Gray).Y
+				if h.ColorSpace == Black {
+					l[x] = ^l[x]
+				}
+			case h.ColorSpace == SGray && h.BitsPerColor == 16:
+				g := color.Gray16Model.Convert(c).(color.Gray16).Y
+				l[2*x], l[2*x+1] = byte(g>>8), byte(g)
+			case h.ColorSpace.Colors() == 3 && h.ColorSpace.additive() && h.BitsPerColor == 8:
+				rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+				l[3*x], l[3*x+1], l[3*x+2] = rgba.R, rgba.G, rgba.B
+			case h.ColorSpace.Colors() == 3 && h.ColorSpace.additive() && h.BitsPerColor == 16:
+				rgba := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+				for i, v := range []uint16{rgba.R, rgba.G, rgba.B} {
+					l[6*x+2*i], l[6*x+2*i+1] = byte(v>>8), byte(v)
+				}
+			case h.ColorSpace == CMYK && h.BitsPerColor == 8:
+				cmyk := color.CMYKModel.Convert(c).(color.CMYK)
+				l[4*x], l[4*x+1], l[4*x+2], l[4*x+3] = cmyk.C, cmyk.M, cmyk.Y, cmyk.K
+			default:
+				return nil, ErrColorSpace
+			}
+		}
+	}
+	return data, nil
+}
+
+func invert(b []byte) {
+	for i := range b {
+		b[i] = ^b[i]
+	}
+}
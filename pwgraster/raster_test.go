@@ -0,0 +1,134 @@
+package pwgraster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func testImage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 37, 11))
+	for y := 0; y < 11; y++ {
+		for x := 0; x < 37; x++ {
+			c := color.RGBA{255, 255, 255, 255} // white runs
+			if x > y && x < 2*y {
+				c = color.RGBA{uint8(x * 7), uint8(y * 23), 128, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, h Header, img image.Image) []byte {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	if err := w.WritePage(h, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	img := testImage()
+	for _, cs := range []ColorSpace{SRGB, SGray} {
+		h := NewHeader(21000, 29700, 300, cs)
+		h.PageSizeName = "iso_a4_210x297mm"
+		r, err := NewReader(bytes.NewReader(encode(t, h, img)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := r.ReadPage()
+		if err != nil {
+			t.Fatal(cs, err)
+		}
+		if p.Header.Width != 37 || p.Header.Height != 11 || p.Header.PageSizeName != h.PageSizeName {
+			t.Errorf("%v: header %+v", cs, p.Header)
+		}
+		// the A4 ImageBox of NewHeader is clipped to the image
+		if p.Header.ImageBox != [4]uint32{0, 0, 37, 11} {
+			t.Errorf("%v: ImageBox %v", cs, p.Header.ImageBox)
+		}
+		got, err := p.Image()
+		if err != nil {
+			t.Fatal(cs, err)
+		}
+		for y := 0; y < 11; y++ {
+			for x := 0; x < 37; x++ {
+				want := img.At(x, y)
+				if cs == SGray {
+					want = color.GrayModel.Convert(want)
+				}
+				if !sameColor(got.At(x, y), want) {
+					t.Fatalf("%v: pixel %d,%d is %v, want %v", cs, x, y, got.At(x, y), want)
+				}
+			}
+		}
+		if _, err := r.ReadPage(); err != io.EOF {
+			t.Errorf("%v: after the last page %v, want io.EOF", cs, err)
+		}
+	}
+}
+
+func sameColor(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1>>8 == r2>>8 && g1>>8 == g2>>8 && b1>>8 == b2>>8 && a1>>8 == a2>>8
+}
+
+func TestMalformedHeader(t *testing.T) {
+	good := encode(t, NewHeader(21000, 29700, 300, SGray), testImage())
+	put := func(off int, v uint32) func([]byte) {
+		return func(b []byte) { binary.BigEndian.PutUint32(b[len(SyncWord)+off:], v) }
+	}
+	tests := []struct {
+		name  string
+		patch func([]byte)
+		want  error
+	}{
+		{"huge height", put(376, 0xFFFFFFFF), ErrPageSize},
+		{"huge line", func(b []byte) { put(372, 0x40000000)(b); put(392, 0x40000000)(b) }, ErrPageSize},
+		// Height*BytesPerLine overflows int64
+		{"huge page", func(b []byte) { put(372, 0xFFFFFFFF)(b); put(376, 0xFFFFFFFF)(b); put(392, 0xFFFFFFFF)(b) }, ErrPageSize},
+		// Width*BitsPerPixel overflows 32 bits to BytesPerLine
+		{"overflowing width", func(b []byte) { put(372, 0x20000001)(b); put(392, 1)(b) }, ErrHeader},
+		{"bits per pixel", put(388, 24), ErrHeader},
+		{"vendor data", put(512, 2000), ErrHeader},
+		{"image box right", put(472, 38), ErrHeader},
+		{"image box bottom above top", func(b []byte) { put(468, 5)(b); put(476, 4)(b) }, ErrHeader},
+	}
+	for _, test := range tests {
+		b := append([]byte(nil), good...)
+		test.patch(b)
+		r, err := NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.ReadPage(); err != test.want {
+			t.Errorf("%s: %v, want %v", test.name, err, test.want)
+		}
+	}
+
+	r, err := NewReader(bytes.NewReader(good[:len(good)-3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadPage(); err == nil {
+		t.Error("truncated page: no error")
+	}
+	if _, err := NewReader(bytes.NewReader([]byte("RaS3"))); err != ErrSyncWord {
+		t.Errorf("sync word: %v, want %v", err, ErrSyncWord)
+	}
+}
+
+func TestDecodeLinesLimit(t *testing.T) {
+	if _, err := DecodeLines(bytes.NewReader(nil), 1<<30, 1<<30, 1, 0xFF); err != ErrPageSize {
+		t.Errorf("DecodeLines: %v, want %v", err, ErrPageSize)
+	}
+	if _, err := DecodeLines(bytes.NewReader(nil), -1, 8, 1, 0xFF); err == nil {
+		t.Error("DecodeLines: negative height accepted")
+	}
+}
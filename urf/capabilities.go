@@ -0,0 +1,203 @@
+package urf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//   URF capability strings
+//
+//   AirPrint printers list their Apple Raster capabilities in the "URF" TXT key and the
+//   urf-supported printer attribute, comma separated keywords of a name and a value, lists of
+//   numbers separated by '-':
+//
+//      V1.4            version
+//      W8, W16         sGray with 8 or 16 bits per pixel
+//      SRGB24, SRGB48  sRGB, ADOBERGB24 and ADOBERGB48 Adobe RGB
+//      DEVW8, DEVRGB24, DEVCMYK32 (and 16 bits per color)  device color spaces
+//      CP255           copies, the maximum the printer makes itself
+//      DM1             duplex, how the back side is transformed: 1 normal, 2 flipped,
+//                      3 rotated, 4 manual tumble
+//      FN3-7           finishings enums
+//      IS1-4           input slots
+//      MT1-2-3         media types, indexes into MediaTypes
+//      OB9             output bins
+//      PQ3-4-5         print qualities
+//      RS300-600       resolutions in dpi
+//      IFU0, OFU0      input and output face up
+
+//	Format is a color space and the bits per pixel of a URF capability, e.g. SRGB24
+type Format struct {
+	ColorSpace   ColorSpace
+	BitsPerPixel int
+}
+
+//	The names of the color spaces in capability strings
+var colorSpaceNames = []struct {
+	name string
+	cs   ColorSpace
+}{
+	{"W", SGray},
+	{"SRGB", SRGB},
+	{"ADOBERGB", AdobeRGB},
+	{"DEVW", Gray},
+	{"DEVRGB", RGB},
+	{"DEVCMYK", CMYK},
+}
+
+//	Capabilities are the parsed keywords of a URF capability string
+type Capabilities struct {
+	Version      string
+	Formats      []Format
+	Copies       int   // CP
+	Duplex       []int // DM
+	Finishings   []int // FN
+	InputSlots   []int // IS
+	MediaTypes   []int // MT
+	OutputBins   []int // OB
+	Qualities    []int // PQ
+	Resolutions  []int // RS
+	InputFaceUp  bool  // IFU1
+	OutputFaceUp bool  // OFU1
+	Other        []string
+}
+
+//	Parses a URF capability string, e.g. "W8,SRGB24,CP1,RS300-600,DM1", or the values of
+//	urf-supported joined by commas. Unknown keywords are kept in Other.
+func ParseCapabilities(s string) (Capabilities, error) {
+	var c Capabilities
+	for _, kw := range strings.Split(s, ",") {
+		kw = strings.TrimSpace(kw)
+		if kw == "" || kw == "none" {
+			continue
+		}
+		i := strings.IndexAny(kw, "0123456789")
+		if i <= 0 {
+			c.Other = append(c.Other, kw)
+			continue
+		}
+		name, value := strings.ToUpper(kw[:i]), kw[i:]
+		if name == "V" {
+			c.Version = value
+			continue
+		}
+		numbers, err := numberList(value)
+		if err != nil {
+			return c, errors.New("urf: invalid capability " + kw)
+		}
+		switch name {
+		case "CP":
+			c.Copies = numbers[0]
+		case "DM":
+			c.Duplex = append(c.Duplex, numbers...)
+		case "FN":
+			c.Finishings = append(c.Finishings, numbers...)
+		case "IS":
+			c.InputSlots = append(c.InputSlots, numbers...)
+		case "MT":
+			c.MediaTypes = append(c.MediaTypes, numbers...)
+		case "OB":
+			c.OutputBins = append(c.OutputBins, numbers...)
+		case "PQ":
+			c.Qualities = append(c.Qualities, numbers...)
+		case "RS":
+			c.Resolutions = append(c.Resolutions, numbers...)
+		case "IFU":
+			c.InputFaceUp = numbers[0] != 0
+		case "OFU":
+			c.OutputFaceUp = numbers[0] != 0
+		default:
+			found := false
+			for _, n := range colorSpaceNames {
+				if n.name == name {
+					c.Formats = append(c.Formats, Format{n.cs, numbers[0]})
+					found = true
+				}
+			}
+			if !found {
+				c.Other = append(c.Other, kw)
+			}
+		}
+	}
+	return c, nil
+}
+
+//	Parses numbers separated by '-'
+func numberList(s string) ([]int, error) {
+	var numbers []int
+	for _, f := range strings.Split(s, "-") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+//	Returns true if the printer accepts pages of the color space and bits per pixel
+func (c Capabilities) Supports(cs ColorSpace, bitsPerPixel int) bool {
+	for _, f := range c.Formats {
+		if f.ColorSpace == cs && f.BitsPerPixel == bitsPerPixel {
+			return true
+		}
+	}
+	return false
+}
+
+//	Returns true if the printer prints at dpi
+func (c Capabilities) SupportsResolution(dpi int) bool {
+	for _, r := range c.Resolutions {
+		if r == dpi {
+			return true
+		}
+	}
+	return false
+}
+
+//	Returns the capability string, e.g. "V1.4,W8,SRGB24,CP1,DM1,RS300-600"
+func (c Capabilities) String() string {
+	var kws []string
+	if c.Version != "" {
+		kws = append(kws, "V"+c.Version)
+	}
+	for _, f := range c.Formats {
+		for _, n := range colorSpaceNames {
+			if n.cs == f.ColorSpace {
+				kws = append(kws, n.name+strconv.Itoa(f.BitsPerPixel))
+			}
+		}
+	}
+	if c.Copies > 0 {
+		kws = append(kws, "CP"+strconv.Itoa(c.Copies))
+	}
+	for _, l := range []struct {
+		name    string
+		numbers []int
+	}{
+		{"DM", c.Duplex},
+		{"FN", c.Finishings},
+		{"IS", c.InputSlots},
+		{"MT", c.MediaTypes},
+		{"OB", c.OutputBins},
+		{"PQ", c.Qualities},
+		{"RS", c.Resolutions},
+	} {
+		if len(l.numbers) > 0 {
+			s := make([]string, len(l.numbers))
+			for i, n := range l.numbers {
+				s[i] = strconv.Itoa(n)
+			}
+			kws = append(kws, l.name+strings.Join(s, "-"))
+		}
+	}
+	if c.InputFaceUp {
+		kws = append(kws, "IFU1")
+	}
+	if c.OutputFaceUp {
+		kws = append(kws, "OFU1")
+	}
+	kws = append(kws, c.Other...)
+	return strings.Join(kws, ",")
+}
@@ -0,0 +1,337 @@
+package urf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"pwgraster"
+)
+
+//   Apple Raster (URF, image/urf)
+//
+//   An Apple Raster stream is the 8 byte sync word "UNIRAST\0" and the number of pages
+//   (uint32, big-endian), then the pages. Each page is a 32 byte header and the lines of the
+//   page, compressed like PWG Raster lines (pwgraster.EncodeLines) with pixels as the unit:
+//
+//      offset  length  field
+//      0       1       bits per pixel, 8, 24, 32, 48 or 64
+//      1       1       color space, see ColorSpace
+//      2       1       duplex, 1 one-sided, 2 two-sided-short-edge, 3 two-sided-long-edge
+//      3       1       print quality, 3 draft, 4 normal, 5 high
+//      4       1       media type, an index into MediaTypes
+//      5       1       input slot (media-source)
+//      6       6       reserved
+//      12      4       width in pixels
+//      16      4       height in lines
+//      20      4       resolution in dpi
+//      24      8       reserved
+
+//	The sync word at the start of an Apple Raster stream
+const SyncWord = "UNIRAST\x00"
+
+//	The length of a page header
+const HeaderLength = 32
+
+//	ColorSpace of the pixels of a page
+type ColorSpace uint8
+
+const (
+	SGray    ColorSpace = 0 // sRGB gray, W8 or W16
+	SRGB     ColorSpace = 1 // SRGB24 or SRGB48
+	CIELab   ColorSpace = 2
+	AdobeRGB ColorSpace = 3 // ADOBERGB24 or ADOBERGB48
+	Gray     ColorSpace = 4 // device gray, DEVW8 or DEVW16
+	RGB      ColorSpace = 5 // device RGB, DEVRGB24 or DEVRGB48
+	CMYK     ColorSpace = 6 // device CMYK, DEVCMYK32 or DEVCMYK64
+)
+
+//	Returns the colorants of a pixel
+func (cs ColorSpace) Colors() int {
+	switch cs {
+	case SGray, Gray:
+		return 1
+	case SRGB, CIELab, AdobeRGB, RGB:
+		return 3
+	case CMYK:
+		return 4
+	}
+	return 0
+}
+
+//	Duplex modes of the page header
+const (
+	OneSided          = 1
+	TwoSidedShortEdge = 2
+	TwoSidedLongEdge  = 3
+)
+
+//	The media-type keywords of the media type byte
+var MediaTypes = []string{
+	"auto", "stationery", "transparency", "envelope", "cardstock", "labels", "stationery-letterhead",
+	"disc", "photographic-matte", "photographic-satin", "photographic-semi-gloss", "photographic-glossy",
+	"photographic-high-gloss", "other",
+}
+
+//	Header is the page header of an Apple Raster page
+type Header struct {
+	BitsPerPixel uint8
+	ColorSpace   ColorSpace
+	Duplex       uint8 // OneSided, TwoSidedShortEdge or TwoSidedLongEdge
+	Quality      uint8 // print-quality
+	MediaType    uint8 // index into MediaTypes
+	InputSlot    uint8
+	Width        uint32
+	Height       uint32
+	Resolution   uint32 // dpi
+}
+
+var (
+	ErrSyncWord   = errors.New("urf: not an Apple Raster stream")
+	ErrHeader     = errors.New("urf: invalid page header")
+	ErrColorSpace = errors.New("urf: color space and bits per pixel not supported for images")
+	ErrPageSize   = errors.New("urf: page is larger than pwgraster.MaxPageSize")
+)
+
+//	Returns the bytes of a line
+func (h *Header) BytesPerLine() int {
+	return int(h.Width) * int(h.BitsPerPixel) / 8
+}
+
+//	Checks that the header describes a page that can be encoded
+func (h *Header) validate() error {
+	n := h.ColorSpace.Colors()
+	if n == 0 || h.BitsPerPixel%8 != 0 || (int(h.BitsPerPixel) != 8*n && int(h.BitsPerPixel) != 16*n) ||
+		h.Width == 0 || h.Height == 0 {
+		return ErrHeader
+	}
+	// the header is untrusted, ReadPage allocates Height*BytesPerLine bytes
+	if line := uint64(h.Width) * uint64(h.BitsPerPixel/8); line > uint64(pwgraster.MaxPageSize)/uint64(h.Height) {
+		return ErrPageSize
+	}
+	return nil
+}
+
+//	Returns the 32 bytes of the header
+func (h *Header) MarshalBinary() ([]byte, error) {
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	b := make([]byte, HeaderLength)
+	b[0], b[1], b[2], b[3], b[4], b[5] = h.BitsPerPixel, byte(h.ColorSpace), h.Duplex, h.Quality, h.MediaType, h.InputSlot
+	binary.BigEndian.PutUint32(b[12:], h.Width)
+	binary.BigEndian.PutUint32(b[16:], h.Height)
+	binary.BigEndian.PutUint32(b[20:], h.Resolution)
+	return b, nil
+}
+
+//	Decodes a header of 32 bytes
+func (h *Header) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLength {
+		return ErrHeader
+	}
+	*h = Header{
+		BitsPerPixel: b[0],
+		ColorSpace:   ColorSpace(b[1]),
+		Duplex:       b[2],
+		Quality:      b[3],
+		MediaType:    b[4],
+		InputSlot:    b[5],
+		Width:        binary.BigEndian.Uint32(b[12:]),
+		Height:       binary.BigEndian.Uint32(b[16:]),
+		Resolution:   binary.BigEndian.Uint32(b[20:]),
+	}
+	return h.validate()
+}
+
+//	Returns the white byte, 0 for CMYK (no ink)
+func (h *Header) white() byte {
+	if h.ColorSpace == CMYK {
+		return 0
+	}
+	return 0xff
+}
+
+//	Page is an Apple Raster page, its header and the uncompressed lines
+type Page struct {
+	Header Header
+	Data   []byte // Height lines of BytesPerLine bytes
+}
+
+//	Writer encodes pages to an Apple Raster stream
+type Writer struct {
+	w       io.Writer
+	pages   uint32
+	started bool
+}
+
+//	Returns a Writer to w of a stream of pages, the stream header is written with the first page
+func NewWriter(w io.Writer, pages int) *Writer {
+	return &Writer{w: w, pages: uint32(pages)}
+}
+
+//	Writes a page of img, one pixel of img is one pixel of the page. Width and Height are set
+//	from the bounds of img, BitsPerPixel from ColorSpace (8 bits per color if 0). Supported are
+//	SGray and Gray, SRGB, AdobeRGB and RGB with 8 or 16 bits per color and CMYK with 8.
+func (w *Writer) WritePage(h Header, img image.Image) error {
+	if h.BitsPerPixel == 0 {
+		h.BitsPerPixel = uint8(8 * h.ColorSpace.Colors())
+	}
+	b := img.Bounds()
+	h.Width, h.Height = uint32(b.Dx()), uint32(b.Dy())
+	if err := h.validate(); err != nil {
+		return err
+	}
+	data, err := encodeImage(&h, img)
+	if err != nil {
+		return err
+	}
+	return w.WriteRaw(&Page{Header: h, Data: data})
+}
+
+//	Writes a page of uncompressed lines
+func (w *Writer) WriteRaw(p *Page) error {
+	hb, err := p.Header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(p.Data) != int(p.Header.Height)*p.Header.BytesPerLine() {
+		return ErrHeader
+	}
+	bw := bufio.NewWriter(w.w)
+	if !w.started {
+		bw.WriteString(SyncWord)
+		binary.Write(bw, binary.BigEndian, w.pages)
+		w.started = true
+	}
+	bw.Write(hb)
+	err = pwgraster.EncodeLines(bw, p.Data, p.Header.BytesPerLine(), int(p.Header.BitsPerPixel/8), p.Header.white())
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+//	Reader decodes the pages of an Apple Raster stream
+type Reader struct {
+	r     *bufio.Reader
+	Pages int // the page count of the stream header
+}
+
+//	Reads the stream header and returns a Reader of the pages
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	b := make([]byte, len(SyncWord)+4)
+	if _, err := io.ReadFull(br, b); err != nil || string(b[:len(SyncWord)]) != SyncWord {
+		return nil, ErrSyncWord
+	}
+	return &Reader{r: br, Pages: int(binary.BigEndian.Uint32(b[len(SyncWord):]))}, nil
+}
+
+//	Returns the next page, io.EOF after the last one
+func (r *Reader) ReadPage() (*Page, error) {
+	hb := make([]byte, HeaderLength)
+	if _, err := io.ReadFull(r.r, hb); err != nil {
+		return nil, err
+	}
+	var p Page
+	if err := p.Header.UnmarshalBinary(hb); err != nil {
+		return nil, err
+	}
+	data, err := pwgraster.DecodeLines(r.r, int(p.Header.Height), p.Header.BytesPerLine(), int(p.Header.BitsPerPixel/8), p.Header.white())
+	if err != nil {
+		return nil, err
+	}
+	p.Data = data
+	return &p, nil
+}
+
+//	Returns the page as an image: *image.Gray or *image.Gray16 for SGray and Gray,
+//	*image.RGBA or *image.RGBA64 for SRGB, AdobeRGB and RGB, *image.CMYK for CMYK
+func (p *Page) Image() (image.Image, error) {
+	h := &p.Header
+	w, ht, bpl := int(h.Width), int(h.Height), h.BytesPerLine()
+	rect := image.Rect(0, 0, w, ht)
+	line := func(y int) []byte { return p.Data[y*bpl : (y+1)*bpl] }
+	switch bits := int(h.BitsPerPixel) / h.ColorSpace.Colors(); {
+	case h.ColorSpace.Colors() == 1 && bits == 8:
+		img := image.NewGray(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		return img, nil
+	case h.ColorSpace.Colors() == 1 && bits == 16:
+		img := image.NewGray16(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		return img, nil
+	case h.ColorSpace.Colors() == 3 && h.ColorSpace != CIELab && bits == 8:
+		img := image.NewRGBA(rect)
+		for y := 0; y < ht; y++ {
+			l := line(y)
+			for x := 0; x < w; x++ {
+				copy(img.Pix[y*img.Stride+4*x:], l[3*x:3*x+3])
+				img.Pix[y*img.Stride+4*x+3] = 0xff
+			}
+		}
+		return img, nil
+	case h.ColorSpace.Colors() == 3 && h.ColorSpace != CIELab && bits == 16:
+		img := image.NewRGBA64(rect)
+		for y := 0; y < ht; y++ {
+			l := line(y)
+			for x := 0; x < w; x++ {
+				copy(img.Pix[y*img.Stride+8*x:], l[6*x:6*x+6])
+				img.Pix[y*img.Stride+8*x+6] = 0xff
+				img.Pix[y*img.Stride+8*x+7] = 0xff
+			}
+		}
+		return img, nil
+	case h.ColorSpace == CMYK && bits == 8:
+		img := image.NewCMYK(rect)
+		for y := 0; y < ht; y++ {
+			copy(img.Pix[y*img.Stride:], line(y))
+		}
+		return img, nil
+	}
+	return nil, ErrColorSpace
+}
+
+//	Returns the lines of img in the layout of h
+func encodeImage(h *Header, img image.Image) ([]byte, error) {
+	b := img.Bounds()
+	w, ht, bpl := int(h.Width), int(h.Height), h.BytesPerLine()
+	bits := int(h.BitsPerPixel) / h.ColorSpace.Colors()
+	n := h.ColorSpace.Colors()
+	if h.ColorSpace == CIELab || n == 4 && bits != 8 {
+		return nil, ErrColorSpace
+	}
+	data := make([]byte, ht*bpl)
+	for y := 0; y < ht; y++ {
+		l := data[y*bpl : (y+1)*bpl]
+		for x := 0; x < w; x++ {
+			c := img.At(b.Min.X+x, b.Min.Y+y)
+			switch {
+			case n == 1 && bits == 8:
+				l[x] = color.GrayModel.Convert(c).(color.Gray).Y
+			case n == 1 && bits == 16:
+				g := color.Gray16Model.Convert(c).(color.Gray16).Y
+				l[2*x], l[2*x+1] = byte(g>>8), byte(g)
+			case n == 3 && bits == 8:
+				rgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+				l[3*x], l[3*x+1], l[3*x+2] = rgba.R, rgba.G, rgba.B
+			case n == 3 && bits == 16:
+				rgba := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+				for i, v := range []uint16{rgba.R, rgba.G, rgba.B} {
+					l[6*x+2*i], l[6*x+2*i+1] = byte(v>>8), byte(v)
+				}
+			case n == 4:
+				cmyk := color.CMYKModel.Convert(c).(color.CMYK)
+				l[4*x], l[4*x+1], l[4*x+2], l[4*x+3] = cmyk.C, cmyk.M, cmyk.Y, cmyk.K
+			}
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,161 @@
+package urf
+
+import (
+	"bytes"
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestParseCapabilities(t *testing.T) {
+	c, err := ParseCapabilities("V1.4,W8,SRGB24,DEVCMYK32,CP1,RS300-600,DM1,IS1-4,MT1-2-3,PQ3-4-5,OFU0,IFU1,XY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Capabilities{
+		Version:     "1.4",
+		Formats:     []Format{{SGray, 8}, {SRGB, 24}, {CMYK, 32}},
+		Copies:      1,
+		Duplex:      []int{1},
+		InputSlots:  []int{1, 4},
+		MediaTypes:  []int{1, 2, 3},
+		Qualities:   []int{3, 4, 5},
+		Resolutions: []int{300, 600},
+		InputFaceUp: true,
+		Other:       []string{"XY"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v\nwant %+v", c, want)
+	}
+	if !c.Supports(SRGB, 24) || c.Supports(SRGB, 48) || !c.SupportsResolution(600) || c.SupportsResolution(1200) {
+		t.Error("Supports or SupportsResolution disagrees with the capabilities")
+	}
+	if s := c.String(); s != "V1.4,W8,SRGB24,DEVCMYK32,CP1,DM1,IS1-4,MT1-2-3,PQ3-4-5,RS300-600,IFU1,XY" {
+		t.Errorf("String: %s", s)
+	}
+
+	// String writes the keywords in its own order, parsing it again gives the same capabilities
+	c, err = ParseCapabilities("W8,SRGB24,CP1,RS300-600,DM1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.String(); s != "W8,SRGB24,CP1,DM1,RS300-600" {
+		t.Errorf("String: %s", s)
+	}
+	if again, err := ParseCapabilities(c.String()); err != nil || !reflect.DeepEqual(again, c) {
+		t.Errorf("parsing %s again: %+v %v", c, again, err)
+	}
+	if c, err := ParseCapabilities("none"); err != nil || c.String() != "" {
+		t.Errorf("none: %q %v", c, err)
+	}
+	if _, err := ParseCapabilities("W8,RS300-x"); err == nil {
+		t.Error("RS300-x: no error")
+	}
+}
+
+func TestHeaderLayout(t *testing.T) {
+	h := Header{
+		BitsPerPixel: 24,
+		ColorSpace:   SRGB,
+		Duplex:       TwoSidedLongEdge,
+		Quality:      5,
+		MediaType:    3,
+		InputSlot:    2,
+		Width:        0x0102,
+		Height:       0x0304,
+		Resolution:   600,
+	}
+	b, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		24, 1, 3, 5, 3, 2, 0, 0, 0, 0, 0, 0,
+		0, 0, 0x01, 0x02, // width
+		0, 0, 0x03, 0x04, // height
+		0, 0, 0x02, 0x58, // resolution
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("header\n% x\nwant\n% x", b, want)
+	}
+	var got Header
+	if err := got.UnmarshalBinary(b); err != nil || got != h {
+		t.Errorf("UnmarshalBinary: %+v %v", got, err)
+	}
+
+	tests := []struct {
+		name  string
+		patch func([]byte)
+		want  error
+	}{
+		{"bits per pixel of another color space", func(b []byte) { b[0] = 32 }, ErrHeader},
+		{"unknown color space", func(b []byte) { b[1] = byte(CMYK) + 1 }, ErrHeader},
+		{"zero height", func(b []byte) { b[18], b[19] = 0, 0 }, ErrHeader},
+		{"huge page", func(b []byte) { copy(b[12:], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) }, ErrPageSize},
+	}
+	for _, test := range tests {
+		p := append([]byte(nil), b...)
+		test.patch(p)
+		if err := new(Header).UnmarshalBinary(p); err != test.want {
+			t.Errorf("%s: %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestWhitePage(t *testing.T) {
+	for _, test := range []struct {
+		cs    ColorSpace
+		img   image.Image
+		white byte
+	}{
+		{SRGB, white(image.NewRGBA(image.Rect(0, 0, 29, 13))), 0xff},
+		{SGray, white(image.NewRGBA(image.Rect(0, 0, 29, 13))), 0xff},
+		// no ink is white, the zero CMYK image
+		{CMYK, image.NewCMYK(image.Rect(0, 0, 29, 13)), 0},
+	} {
+		var b bytes.Buffer
+		w := NewWriter(&b, 2)
+		duplex := []uint8{TwoSidedShortEdge, OneSided}
+		for _, d := range duplex {
+			if err := w.WritePage(Header{ColorSpace: test.cs, Duplex: d, Resolution: 300}, test.img); err != nil {
+				t.Fatal(test.cs, err)
+			}
+		}
+		// a page of 13 white lines is one line repeated 12 more times, the rest of it white
+		page := []byte{12, 0x80}
+		data := b.Bytes()[len(SyncWord)+4:]
+		if len(data) != 2*(HeaderLength+len(page)) || !bytes.Equal(data[HeaderLength:HeaderLength+2], page) {
+			t.Fatalf("%v: pages % x", test.cs, data)
+		}
+
+		r, err := NewReader(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Pages != 2 {
+			t.Errorf("%v: Pages is %d, want 2", test.cs, r.Pages)
+		}
+		for _, d := range duplex {
+			p, err := r.ReadPage()
+			if err != nil {
+				t.Fatal(test.cs, err)
+			}
+			if p.Header.Duplex != d || p.Header.Width != 29 || p.Header.Height != 13 {
+				t.Errorf("%v: header %+v", test.cs, p.Header)
+			}
+			for i, v := range p.Data {
+				if v != test.white {
+					t.Fatalf("%v: byte %d is %#x, want %#x", test.cs, i, v, test.white)
+				}
+			}
+		}
+	}
+}
+
+func white(img *image.RGBA) *image.RGBA {
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
@@ -45,9 +45,6 @@ func (i *iterator) GetNextOne() (byte, bool) {
 		f := i.srcIn[i.mark]
 		i.mark += 1
 		i.bRemaining -= 1
-		var ff []byte
-		ff = append(ff, f)
-		fmt.Println(hex.Dump(ff))
 		return f, true
 	} else if i.mark+1 > i.markEnd {
 		log.Println("exceeds markEnd")
@@ -64,9 +61,6 @@ func (i *iterator) GetNextN(it int) ([]byte, bool) {
 		markIn := i.mark
 		i.mark += it
 		i.bRemaining -= it	
-		
-		fmt.Println(hex.Dump(i.srcIn[markIn:i.mark]))
-			
 		return i.srcIn[markIn:i.mark], true
 	} else {
 		if i.mark+it > i.markEnd {
@@ -76,11 +70,13 @@ func (i *iterator) GetNextN(it int) ([]byte, bool) {
 			markIn := i.mark
 			i.mark += it
 			i.bRemaining -= it	
-			
-			fmt.Println(hex.Dump(i.srcIn[markIn:i.mark]))
-					
 			return i.srcIn[markIn:i.mark], true
 		}
 	}
 	return nil, false
-}
\ No newline at end of file
+}
+
+// Remaining returns the bytes that have not been consumed yet.
+func (i *iterator) Remaining() []byte {
+	return i.srcIn[i.mark:]
+}